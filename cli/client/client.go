@@ -0,0 +1,631 @@
+// Package client is a Go client for the MIST HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "http://localhost:3000"
+
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrServer       = errors.New("server error")
+)
+
+// APIError is returned when the API answers with a non-2xx status. It matches
+// the sentinel error of its status code with errors.Is.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("api error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("api error: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// Job mirrors the job record returned by the API.
+type Job struct {
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	Payload       map[string]interface{} `json:"payload"`
+	Retries       int                    `json:"retries"`
+	Created       time.Time              `json:"created"`
+	RequiredGPU   string                 `json:"required_gpu,omitempty"`
+	Owner         string                 `json:"owner,omitempty"`
+	Priority      string                 `json:"priority,omitempty"`
+	RunAfter      *time.Time             `json:"run_after,omitempty"`
+	DependsOn     []string               `json:"depends_on,omitempty"`
+	WorkflowID    string                 `json:"workflow_id,omitempty"`
+	JobState      string                 `json:"job_state"`
+	ConsumerID    *string                `json:"consumer_id,omitempty"`
+	TimeAssigned  *time.Time             `json:"time_assigned,omitempty"`
+	TimeStarted   *time.Time             `json:"time_started,omitempty"`
+	TimeCompleted *time.Time             `json:"time_completed,omitempty"`
+	Result        map[string]interface{} `json:"result,omitempty"`
+	Error         *string                `json:"error,omitempty"`
+}
+
+// Supervisor mirrors the supervisor status returned by the API.
+type Supervisor struct {
+	ConsumerID string    `json:"consumer_id"`
+	GPUType    string    `json:"gpu_type"`
+	Status     string    `json:"status"`
+	LastSeen   time.Time `json:"last_seen"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+type SubmitJobRequest struct {
+	Type        string                 `json:"type"`
+	Payload     map[string]interface{} `json:"payload"`
+	RequiredGPU string                 `json:"gpu,omitempty"`
+	Priority    string                 `json:"priority,omitempty"` // low, normal or high
+	RunAfter    *time.Time             `json:"run_after,omitempty"`
+	Delay       string                 `json:"delay,omitempty"` // e.g. "8h"
+	DependsOn   []string               `json:"depends_on,omitempty"`
+
+	// IdempotencyKey makes retries of the submission return the same job.
+	// SubmitJob generates one if it is empty.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// CreateScheduleRequest is the body of POST /schedules.
+type CreateScheduleRequest struct {
+	Name       string           `json:"name,omitempty"`
+	Cron       string           `json:"cron"`
+	Timezone   string           `json:"timezone,omitempty"`
+	Job        SubmitJobRequest `json:"job"`
+	MissedRuns string           `json:"missed_runs,omitempty"` // skip or run_once
+}
+
+// Schedule mirrors a recurring job returned by the API.
+type Schedule struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name,omitempty"`
+	Cron         string           `json:"cron"`
+	Timezone     string           `json:"timezone,omitempty"`
+	Job          SubmitJobRequest `json:"job"`
+	MissedRuns   string           `json:"missed_runs"`
+	Owner        string           `json:"owner,omitempty"`
+	Paused       bool             `json:"paused"`
+	Created      time.Time        `json:"created"`
+	NextRun      *time.Time       `json:"next_run,omitempty"`
+	LastRun      *time.Time       `json:"last_run,omitempty"`
+	LastJobID    string           `json:"last_job_id,omitempty"`
+	LastJobState string           `json:"last_job_state,omitempty"`
+	LastError    string           `json:"last_error,omitempty"`
+	Missed       int              `json:"missed"`
+}
+
+// ListJobsOptions filters GET /jobs. Zero values are left out.
+type ListJobsOptions struct {
+	States    []string
+	Type      string
+	GPU       string
+	Owner     string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Cursor    string
+	Ascending bool
+}
+
+func (o ListJobsOptions) query() url.Values {
+	q := url.Values{}
+	if len(o.States) > 0 {
+		q.Set("state", strings.Join(o.States, ","))
+	}
+	if o.Type != "" {
+		q.Set("type", o.Type)
+	}
+	if o.GPU != "" {
+		q.Set("gpu", o.GPU)
+	}
+	if o.Owner != "" {
+		q.Set("owner", o.Owner)
+	}
+	if !o.Since.IsZero() {
+		q.Set("since", o.Since.Format(time.RFC3339))
+	}
+	if !o.Until.IsZero() {
+		q.Set("until", o.Until.Format(time.RFC3339))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Ascending {
+		q.Set("order", "asc")
+	}
+	return q
+}
+
+// JobPage is one page of GET /jobs. NextCursor is empty on the last page.
+type JobPage struct {
+	Jobs       []Job  `json:"jobs"`
+	Count      int    `json:"count"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// TokenPair is returned by Login and Refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type Client struct {
+	BaseURL     string
+	AccessToken string
+	HTTPClient  *http.Client
+
+	// RefreshToken, when set, is used to get a new access token once a
+	// request is rejected as unauthorized. OnRefresh is called with the new
+	// tokens so they can be saved.
+	RefreshToken string
+	OnRefresh    func(*TokenPair)
+}
+
+// New returns a client for the API at baseURL, authenticating with
+// accessToken when it is not empty.
+func New(baseURL, accessToken string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		AccessToken: accessToken,
+		HTTPClient:  httpClient,
+	}
+}
+
+// Login exchanges a username and password for a token pair. The client
+// uses the new tokens for later requests.
+func (c *Client) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	var tokens TokenPair
+	body := map[string]string{"username": username, "password": password}
+	if err := c.send(ctx, http.MethodPost, "/auth/login", body, &tokens); err != nil {
+		return nil, err
+	}
+	c.setTokens(&tokens)
+	return &tokens, nil
+}
+
+// Refresh exchanges the client's refresh token for a new token pair. The
+// old refresh token stops working.
+func (c *Client) Refresh(ctx context.Context) (*TokenPair, error) {
+	var tokens TokenPair
+	body := map[string]string{"refresh_token": c.RefreshToken}
+	if err := c.send(ctx, http.MethodPost, "/auth/refresh", body, &tokens); err != nil {
+		return nil, err
+	}
+	c.setTokens(&tokens)
+	return &tokens, nil
+}
+
+func (c *Client) setTokens(tokens *TokenPair) {
+	c.AccessToken = tokens.AccessToken
+	c.RefreshToken = tokens.RefreshToken
+	if c.OnRefresh != nil {
+		c.OnRefresh(tokens)
+	}
+}
+
+// SubmitJob enqueues a job and returns its ID.
+func (c *Client) SubmitJob(ctx context.Context, req SubmitJobRequest) (string, error) {
+	if req.IdempotencyKey == "" {
+		key, err := newIdempotencyKey()
+		if err != nil {
+			return "", err
+		}
+		req.IdempotencyKey = key
+	}
+
+	var resp struct {
+		JobID string `json:"job_id"`
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.do(ctx, http.MethodPost, "/jobs", req, &resp)
+		if err == nil || attempt == SubmitAttempts || !retryable(err) {
+			break
+		}
+		// the same key makes sure a submission that did get through is not
+		// booked twice
+		select {
+		case <-time.After(SubmitRetryDelay * time.Duration(attempt)):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.JobID, nil
+}
+
+// SubmitAttempts and SubmitRetryDelay control how often SubmitJob tries
+// again after a network or server error, waiting longer after every attempt.
+var (
+	SubmitAttempts   = 3
+	SubmitRetryDelay = 500 * time.Millisecond
+)
+
+// retryable reports whether a request that failed with err may succeed if
+// sent again.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return errors.Is(apiErr, ErrServer)
+	}
+	return true
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetJob returns the record of the job with the given ID.
+func (c *Client) GetJob(ctx context.Context, jobID string) (*Job, error) {
+	var job Job
+	path := "/jobs/status?" + url.Values{"id": {jobID}}.Encode()
+	if err := c.do(ctx, http.MethodGet, path, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns one page of jobs matching opts.
+func (c *Client) ListJobs(ctx context.Context, opts ListJobsOptions) (*JobPage, error) {
+	var page JobPage
+	path := "/jobs"
+	if q := opts.query(); len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// CancelJob cancels a job that has not finished yet.
+func (c *Client) CancelJob(ctx context.Context, jobID string) error {
+	return c.do(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(jobID), nil, nil)
+}
+
+// JobLogs writes the output of a job to w. With follow it keeps writing new
+// output as the job produces it, until the job finishes or ctx is done.
+func (c *Client) JobLogs(ctx context.Context, jobID string, follow bool, w io.Writer) error {
+	path := "/jobs/" + url.PathEscape(jobID) + "/logs"
+	if !follow {
+		return c.do(ctx, http.MethodGet, path, nil, w)
+	}
+	return c.withoutTimeout().do(ctx, http.MethodGet, path+"?follow=true", nil, w)
+}
+
+// Artifact is a file a job left in its output directory.
+type Artifact struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// ListArtifacts returns the artifacts a job left behind.
+func (c *Client) ListArtifacts(ctx context.Context, jobID string) ([]Artifact, error) {
+	var resp struct {
+		Artifacts []Artifact `json:"artifacts"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(jobID)+"/artifacts", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Artifacts, nil
+}
+
+// DownloadArtifacts writes the artifacts of a job to w as a gzipped tar
+// archive or, if path is not empty, just the artifact at path.
+func (c *Client) DownloadArtifacts(ctx context.Context, jobID, path string, w io.Writer) error {
+	p := "/jobs/" + url.PathEscape(jobID) + "/artifacts/download"
+	if path != "" {
+		p += "?" + url.Values{"path": {path}}.Encode()
+	}
+	return c.withoutTimeout().do(ctx, http.MethodGet, p, nil, w)
+}
+
+// JobInput is an uploaded file a job gets in /data.
+type JobInput struct {
+	Path   string `json:"path"` // relative to /data
+	SHA256 string `json:"sha256"`
+}
+
+// Upload mirrors a file stored by POST /uploads.
+type Upload struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// UploadFile uploads the file at path for jobs to use as input, unless the
+// server already has a file with the same content.
+func (c *Client) UploadFile(ctx context.Context, path string) (*Upload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	var upload Upload
+	err = c.do(ctx, http.MethodGet, "/uploads/"+digest, nil, &upload)
+	if err == nil {
+		return &upload, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	query := url.Values{"sha256": {digest}}.Encode()
+	if err := c.withoutTimeout().do(ctx, http.MethodPost, "/uploads?"+query, rawBody(data), &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// withoutTimeout returns a copy of c for long downloads and streams, which
+// the HTTP client's timeout would cut short.
+func (c *Client) withoutTimeout() *Client {
+	streaming := *c
+	httpClient := *c.HTTPClient
+	httpClient.Timeout = 0
+	streaming.HTTPClient = &httpClient
+	return &streaming
+}
+
+type QuotaPolicy struct {
+	MaxConcurrentJobs int     `json:"max_concurrent_jobs"`
+	MaxQueuedJobs     int     `json:"max_queued_jobs"`
+	GPUHoursPerWeek   float64 `json:"gpu_hours_per_week"`
+}
+
+type QuotaUsage struct {
+	QueuedJobs  int     `json:"queued_jobs"`
+	RunningJobs int     `json:"running_jobs"`
+	GPUHours    float64 `json:"gpu_hours"`
+}
+
+// Quota is the policy and usage of a user or team.
+type Quota struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Policy QuotaPolicy `json:"policy"`
+	Usage  *QuotaUsage `json:"usage,omitempty"`
+}
+
+// GetQuotas returns the quotas of the caller and their team. Admins can pass
+// a user or team to look at someone else's.
+func (c *Client) GetQuotas(ctx context.Context, user, team string) ([]Quota, error) {
+	var resp struct {
+		Quotas []Quota `json:"quotas"`
+	}
+	path := "/quotas"
+	q := url.Values{}
+	if user != "" {
+		q.Set("user", user)
+	}
+	if team != "" {
+		q.Set("team", team)
+	}
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Quotas, nil
+}
+
+// SetQuota sets the policy of a user or team (kind "user" or "team"). An
+// empty name sets the default policy of the kind. Requires the admin role.
+func (c *Client) SetQuota(ctx context.Context, kind, name string, policy QuotaPolicy) error {
+	path := "/quotas/" + url.PathEscape(kind)
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return c.do(ctx, http.MethodPut, path, policy, nil)
+}
+
+// CreateSchedule creates a recurring job and returns it with its first run.
+func (c *Client) CreateSchedule(ctx context.Context, req CreateScheduleRequest) (*Schedule, error) {
+	var sched Schedule
+	if err := c.do(ctx, http.MethodPost, "/schedules", req, &sched); err != nil {
+		return nil, err
+	}
+	return &sched, nil
+}
+
+// ListSchedules returns the caller's schedules, or every schedule for admins.
+func (c *Client) ListSchedules(ctx context.Context) ([]Schedule, error) {
+	var resp struct {
+		Schedules []Schedule `json:"schedules"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/schedules", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Schedules, nil
+}
+
+// GetSchedule returns a schedule along with the state of its last job.
+func (c *Client) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	var sched Schedule
+	if err := c.do(ctx, http.MethodGet, "/schedules/"+url.PathEscape(id), nil, &sched); err != nil {
+		return nil, err
+	}
+	return &sched, nil
+}
+
+// PauseSchedule stops a schedule from submitting jobs until it is resumed.
+func (c *Client) PauseSchedule(ctx context.Context, id string) (*Schedule, error) {
+	var sched Schedule
+	if err := c.do(ctx, http.MethodPost, "/schedules/"+url.PathEscape(id)+"/pause", nil, &sched); err != nil {
+		return nil, err
+	}
+	return &sched, nil
+}
+
+// ResumeSchedule resumes a paused schedule from its next run.
+func (c *Client) ResumeSchedule(ctx context.Context, id string) (*Schedule, error) {
+	var sched Schedule
+	if err := c.do(ctx, http.MethodPost, "/schedules/"+url.PathEscape(id)+"/resume", nil, &sched); err != nil {
+		return nil, err
+	}
+	return &sched, nil
+}
+
+// DeleteSchedule removes a schedule. Jobs it already submitted are kept.
+func (c *Client) DeleteSchedule(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/schedules/"+url.PathEscape(id), nil, nil)
+}
+
+// ListSupervisors returns the registered supervisors, only the active ones
+// if activeOnly is set.
+func (c *Client) ListSupervisors(ctx context.Context, activeOnly bool) ([]Supervisor, error) {
+	var resp struct {
+		Supervisors []Supervisor `json:"supervisors"`
+	}
+	path := "/supervisors"
+	if activeOnly {
+		path += "?active=true"
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Supervisors, nil
+}
+
+// GetSupervisor returns the status of a single supervisor.
+func (c *Client) GetSupervisor(ctx context.Context, consumerID string) (*Supervisor, error) {
+	var supervisor Supervisor
+	if err := c.do(ctx, http.MethodGet, "/supervisors/status/"+url.PathEscape(consumerID), nil, &supervisor); err != nil {
+		return nil, err
+	}
+	return &supervisor, nil
+}
+
+// do sends an authenticated request, refreshing the access token and
+// retrying once if it has expired.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	err := c.send(ctx, method, path, body, out)
+	if !errors.Is(err, ErrUnauthorized) || c.RefreshToken == "" {
+		return err
+	}
+	if _, refreshErr := c.Refresh(ctx); refreshErr != nil {
+		return err
+	}
+	return c.send(ctx, method, path, body, out)
+}
+
+// rawBody is a request body sent as it is instead of encoded as JSON.
+type rawBody []byte
+
+// send sends a request with body encoded as JSON and decodes the response
+// into out, if not nil, or copies it there if out is an io.Writer. Non-2xx
+// responses are returned as *APIError.
+func (c *Client) send(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	contentType := "application/json"
+	if raw, ok := body.(rawBody); ok {
+		reader = bytes.NewReader(raw)
+		contentType = "application/octet-stream"
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if w, ok := out.(io.Writer); ok {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
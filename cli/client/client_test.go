@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL, "token123", server.Client())
+}
+
+func TestSubmitJobSendsTokenAndBody(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token123" {
+			t.Errorf("expected bearer token, got %q", got)
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/jobs" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_1"}`))
+	})
+
+	id, err := c.SubmitJob(context.Background(), SubmitJobRequest{Type: "train", RequiredGPU: "AMD"})
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+	if id != "job_1" {
+		t.Errorf("expected job_1, got %s", id)
+	}
+}
+
+func TestListJobsQuery(t *testing.T) {
+	since := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		want := "cursor=abc&gpu=TT&limit=5&order=asc&since=2025-01-02T03%3A04%3A05Z&state=Scheduled%2CInProgress"
+		if r.URL.RawQuery != want {
+			t.Errorf("expected query %q, got %q", want, r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"jobs":[{"id":"job_1","job_state":"Scheduled"}],"count":1,"next_cursor":"def"}`))
+	})
+
+	page, err := c.ListJobs(context.Background(), ListJobsOptions{
+		States:    []string{"Scheduled", "InProgress"},
+		GPU:       "TT",
+		Since:     since,
+		Limit:     5,
+		Cursor:    "abc",
+		Ascending: true,
+	})
+	if err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	if len(page.Jobs) != 1 || page.Jobs[0].ID != "job_1" || page.NextCursor != "def" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	cases := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusInternalServerError, ErrServer},
+	}
+	for _, tc := range cases {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", tc.status)
+		})
+		err := c.CancelJob(context.Background(), "job_1")
+		if !errors.Is(err, tc.want) {
+			t.Errorf("status %d: expected %v, got %v", tc.status, tc.want, err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Message != "nope" {
+			t.Errorf("status %d: expected APIError with message, got %v", tc.status, err)
+		}
+	}
+}
+
+func TestExpiredAccessTokenIsRefreshed(t *testing.T) {
+	var saved *TokenPair
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/auth/refresh":
+			w.Write([]byte(`{"access_token":"fresh","refresh_token":"r2","token_type":"Bearer","expires_in":900}`))
+		case r.Header.Get("Authorization") != "Bearer fresh":
+			http.Error(w, "expired", http.StatusUnauthorized)
+		default:
+			w.Write([]byte(`{"id":"job_1","job_state":"Scheduled"}`))
+		}
+	})
+	c.RefreshToken = "r1"
+	c.OnRefresh = func(tokens *TokenPair) { saved = tokens }
+
+	job, err := c.GetJob(context.Background(), "job_1")
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if job.ID != "job_1" {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if saved == nil || saved.RefreshToken != "r2" || c.AccessToken != "fresh" {
+		t.Errorf("expected the rotated tokens to be kept, got %+v", saved)
+	}
+}
+
+func TestSubmitJobRetriesWithSameKey(t *testing.T) {
+	defer func(delay time.Duration) { SubmitRetryDelay = delay }(SubmitRetryDelay)
+	SubmitRetryDelay = time.Millisecond
+
+	var keys []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req SubmitJobRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		keys = append(keys, req.IdempotencyKey)
+		if len(keys) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_1"}`))
+	})
+
+	id, err := c.SubmitJob(context.Background(), SubmitJobRequest{Type: "train"})
+	if err != nil || id != "job_1" {
+		t.Fatalf("expected job_1 after retries, got %q, %v", id, err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Errorf("expected 3 attempts with the same key, got %q", keys)
+	}
+}
+
+func TestSubmitJobDoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	})
+
+	if _, err := c.SubmitJob(context.Background(), SubmitJobRequest{Type: "train"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Errorf("expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestUploadFileSkipsKnownContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "train.py")
+	os.WriteFile(path, []byte("print('hi')"), 0o644)
+	sum := sha256.Sum256([]byte("print('hi')"))
+	digest := hex.EncodeToString(sum[:])
+
+	stored := false
+	posts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/uploads/"+digest:
+			if !stored {
+				http.Error(w, "Upload not found", http.StatusNotFound)
+				return
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/uploads":
+			posts++
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "print('hi')" || r.URL.Query().Get("sha256") != digest {
+				t.Errorf("unexpected upload %q with query %q", body, r.URL.RawQuery)
+			}
+			if ct := r.Header.Get("Content-Type"); ct != "application/octet-stream" {
+				t.Errorf("expected a raw body, got %q", ct)
+			}
+			stored = true
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"sha256":"` + digest + `","size":11}`))
+	})
+
+	for i := 0; i < 2; i++ {
+		upload, err := c.UploadFile(context.Background(), path)
+		if err != nil || upload.SHA256 != digest {
+			t.Fatalf("UploadFile: got %+v, %v", upload, err)
+		}
+	}
+	if posts != 1 {
+		t.Errorf("expected the file to be uploaded once, got %d uploads", posts)
+	}
+}
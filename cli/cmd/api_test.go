@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestAppContext returns an AppContext talking to a test server backed by handler.
+func newTestAppContext(t *testing.T, handler http.HandlerFunc) *AppContext {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &AppContext{
+		HTTPClient: server.Client(),
+		APIBaseURL: server.URL,
+	}
+}
+
+// withUploads answers file uploads itself and passes other requests to next.
+func withUploads(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/uploads/"):
+			http.Error(w, "Upload not found", http.StatusNotFound)
+		case r.Method == http.MethodPost && r.URL.Path == "/uploads":
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"sha256":%q,"size":%d}`, hex.EncodeToString(sum[:]), len(body))
+		default:
+			next(w, r)
+		}
+	}
+}
+
+// inTempDir runs the rest of the test in a new directory holding files.
+func inTempDir(t *testing.T, files ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("print('hi')\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
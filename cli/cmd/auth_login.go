@@ -2,48 +2,40 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
-)
 
-// TODO: Update with real auth URL
-const authUrl = "https://example.com/login"
+	"mist/cli/client"
+
+	"golang.org/x/term"
+)
 
 type LoginCmd struct {
+	Username string `help:"Username to log in as. Prompted for if not given." short:"u"`
 }
 
-func openUrl() error {
-
-	var cmd *exec.Cmd
-	url := authUrl
-	switch runtime.GOOS {
-	case "darwin":
-		cmd = exec.Command("open", url)
-	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
-	default: // Linux, BSD, etc.
-		cmd = exec.Command("xdg-open", url)
+// saveTokensToConfig stores tokens in the config file. It does nothing when
+// no config path is known.
+func saveTokensToConfig(ctx *AppContext, tokens *client.TokenPair) error {
+	if ctx.Config == nil {
+		ctx.Config = &Config{}
 	}
+	ctx.Config.AccessToken = tokens.AccessToken
+	ctx.Config.RefreshToken = tokens.RefreshToken
 
-	return cmd.Start()
-}
-
-func saveTokenToConfig(ctx *AppContext, token string) error {
-	configPath := defaultConfigPath()
+	configPath := ctx.ConfigPath
+	if configPath == "" {
+		return nil
+	}
 	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	if ctx.Config == nil {
-		ctx.Config = &Config{}
-	}
-	ctx.Config.AccessToken = token
-
 	data, err := json.MarshalIndent(ctx.Config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -51,24 +43,32 @@ func saveTokenToConfig(ctx *AppContext, token string) error {
 	if err := os.WriteFile(configPath, data, 0o600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
-	fmt.Println("Token saved:", token)
 	return nil
 }
 
-func getLongLivedToken(shortLivedToken string) (string, error) {
-	// Placeholder for actual implementation to exchange short-lived token for long-lived token
-	// In a real scenario, this would involve making an HTTP request to the auth server
-	return shortLivedToken + "_long_lived", nil
+// readPassword reads a password without echoing it when stdin is a terminal,
+// or a line from reader when it is not, e.g. when the password is piped in.
+func readPassword(reader *bufio.Reader) string {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return ""
+		}
+		return string(password)
+	}
+	password, _ := reader.ReadString('\n')
+	return strings.TrimRight(password, "\r\n")
 }
 
 func (l *LoginCmd) Run(ctx *AppContext) error {
 	// mist auth login
-	if ctx.Config != nil && ctx.Config.AccessToken != "" {
+	reader := bufio.NewReader(os.Stdin)
 
+	if ctx.Config != nil && ctx.Config.AccessToken != "" {
 		// Already logged in, ask if they want to re-login
-		fmt.Println("Already logged in with token:", ctx.Config.AccessToken)
-		fmt.Print("Re-enter token? (y/N): ")
-		reader := bufio.NewReader(os.Stdin)
+		fmt.Print("Already logged in. Log in again? (y/N): ")
 		answer, _ := reader.ReadString('\n')
 		answer = strings.TrimSpace(strings.ToLower(answer))
 		if answer != "y" && answer != "yes" {
@@ -77,33 +77,30 @@ func (l *LoginCmd) Run(ctx *AppContext) error {
 		}
 	}
 
-	fmt.Println("Opening browser for authentication...")
-	fmt.Printf("If your browser didn't open, click here: \033]8;;%s\033\\%s\033]8;;\033\\\n", authUrl, authUrl)
-
-	err := openUrl()
-	if err != nil {
-		fmt.Println("Error opening browser:", err)
-		return err
+	username := l.Username
+	if username == "" {
+		fmt.Print("Username: ")
+		username, _ = reader.ReadString('\n')
+		username = strings.TrimSpace(username)
 	}
-	fmt.Print("token: ")
-
-	reader := bufio.NewReader(os.Stdin)
-	token, _ := reader.ReadString('\n')
-	token = strings.TrimSpace(token)
+	fmt.Print("Password: ")
+	password := readPassword(reader)
 
-	token, err = getLongLivedToken(token)
-	if err != nil {
-		fmt.Println("Error obtaining long-lived token:", err)
-		return err
+	if username == "" || password == "" {
+		fmt.Println("Username and password are required.")
+		return nil
 	}
 
-	err = saveTokenToConfig(ctx, token)
+	// Tokens are saved through the client's OnRefresh hook
+	_, err := ctx.Client().Login(context.Background(), username, password)
+	if errors.Is(err, client.ErrUnauthorized) {
+		fmt.Println("Invalid username or password.")
+		return nil
+	}
 	if err != nil {
-		fmt.Println("Error during token saving")
-		return err
+		return apiError("login failed", err)
 	}
 
-	fmt.Println("Saved token to config")
-
+	fmt.Println("Logged in as", username)
 	return nil
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loginHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/auth/login" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Username != "alice" || req.Password != "password123" {
+			http.Error(w, "invalid username or password", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":900}`))
+	}
+}
+
+// Tokens end up in the config file
+func TestLoginSavesTokens(t *testing.T) {
+	ctx := newTestAppContext(t, loginHandler(t))
+	ctx.ConfigPath = filepath.Join(t.TempDir(), "config.json")
+	cmd := &LoginCmd{Username: "alice"}
+	output := CaptureOutput(func() {
+		MockInput("password123\n", func() {
+			if err := cmd.Run(ctx); err != nil {
+				t.Errorf("login failed: %v", err)
+			}
+		})
+	})
+	if !contains(output, "Logged in as alice") {
+		t.Errorf("expected a login message, got %q", output)
+	}
+
+	cfg, err := loadConfig(ctx.ConfigPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if cfg.AccessToken != "access" || cfg.RefreshToken != "refresh" {
+		t.Errorf("unexpected saved tokens %+v", cfg)
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	ctx := newTestAppContext(t, loginHandler(t))
+	ctx.ConfigPath = filepath.Join(t.TempDir(), "config.json")
+	cmd := &LoginCmd{}
+	output := CaptureOutput(func() {
+		MockInput("alice\nnope\n", func() {
+			_ = cmd.Run(ctx)
+		})
+	})
+	if !contains(output, "Invalid username or password.") {
+		t.Errorf("expected a rejection, got %q", output)
+	}
+	if _, err := os.Stat(ctx.ConfigPath); !os.IsNotExist(err) {
+		t.Errorf("expected no config to be written, got %v", err)
+	}
+}
@@ -4,7 +4,7 @@ import "fmt"
 
 // Config flags
 type ConfigCmd struct {
-	DefaultCluster string `help:"Set the default compute cluster." optional: ""`
+	DefaultCluster string `help:"Set the default compute cluster." optional:""`
 	Show           bool   `help:"Show current configuration."`
 }
 
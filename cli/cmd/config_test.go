@@ -10,7 +10,7 @@ import (
 func TestConfigNoFlags(t *testing.T){
 	cmd := &ConfigCmd{}
 	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+		_ = cmd.Run(&AppContext{})
 	})
 	if want := "No config action specified. Use --help for options."; !contains(output, want){
 	t.Errorf("expected output to contain %q, got %q", want, output)
@@ -22,7 +22,7 @@ func TestConfigDefaultCluster(t *testing.T){
 	cmd := &ConfigCmd{DefaultCluster: "tt-gpu-cluster-1"} // Create config object 
 
 	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+		_ = cmd.Run(&AppContext{})
 	})
 
 	// fmt.Printf("Captured the output:  %s\n", output)
@@ -36,7 +36,7 @@ func TestConfigDefaultCluster(t *testing.T){
 func TestConfigCmd_Show(t *testing.T){
 	cmd := &ConfigCmd{Show: true}
 	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+		_ = cmd.Run(&AppContext{})
 	})
 
 	if want := "Current configuration:"; !contains(output, want){
@@ -48,7 +48,7 @@ func TestConfigCmd_Show(t *testing.T){
 func TestConfigBothFlagError(t *testing.T){
 	cmd := &ConfigCmd{DefaultCluster: "tt-gpu-cluster-1", Show: true}
 	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+		_ = cmd.Run(&AppContext{})
 	})
 
 	if want := "Cannot use --show and --default-cluster together"; !contains(output, want){
@@ -4,9 +4,11 @@ type JobCmd struct {
 	Submit JobSubmitCmd `cmd:"" help:"Submit a new job"`
 	Cancel JobCancelCmd `cmd:"" help:"Cancel an existing job"`
 	// Delete JobDeleteCmd `cmd: "" help: "Delete an existing job"`
-	Status JobStatusCmd `cmd:"" help:"Check the status of a job"`
+	Status   JobStatusCmd   `cmd:"" help:"Check the status of a job"`
+	Logs     JobLogsCmd     `cmd:"" help:"Show the output of a job"`
+	Download JobDownloadCmd `cmd:"" help:"Download the artifacts of a job"`
 	// Cancel   CancelCmd   `cmd:"" help:"Cancel a running job"`
-	List ListCmd `cmd:"" help:"List all jobs" default:1`
+	List ListCmd `cmd:"" help:"List all jobs" default:"1"`
 }
 
 func (j *JobCmd) Run() error {
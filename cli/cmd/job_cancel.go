@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
-	"time"
+
+	"mist/cli/client"
 )
 
 type JobCancelCmd struct {
@@ -13,38 +16,6 @@ type JobCancelCmd struct {
 }
 
 func (c *JobCancelCmd) Run(ctx *AppContext) error {
-	// Same Mock data from job list.
-	jobs := []Job{
-		{
-			ID:        "ID:1",
-			Name:      "docker_container_name_1",
-			Status:    "Running",
-			GPUType:   "AMD",
-			CreatedAt: time.Now(),
-		},
-		{
-			ID:        "ID:2",
-			Name:      "docker_container_name_2",
-			Status:    "Enqueued",
-			GPUType:   "TT",
-			CreatedAt: time.Now().Add(-time.Hour * 24),
-		},
-		{
-			ID:        "ID:3",
-			Name:      "docker_container_name_3",
-			Status:    "Running",
-			GPUType:   "TT",
-			CreatedAt: time.Now().Add(-time.Hour * 24),
-		},
-	}
-
-	// Check if job exists
-	if !jobExists(jobs, c.ID) {
-		fmt.Printf("%s does not exist in your jobs.\n", c.ID)
-		fmt.Printf("Use the command \"job list\" for your list of jobs.")
-		return nil
-	}
-
 	fmt.Printf("Are you sure you want to cancel %s? (y/n): \n", c.ID)
 
 	reader := bufio.NewReader(os.Stdin)
@@ -53,11 +24,20 @@ func (c *JobCancelCmd) Run(ctx *AppContext) error {
 
 	if input == "y" || input == "yes" {
 		fmt.Println("Confirmed, proceeding job cancellation....")
-
-		// Confirmed job cancellation logic
-
 		fmt.Println("Cancelling job with ID:", c.ID)
-		fmt.Printf("Job cancelled successfully with ID: %s\n", c.ID)
+
+		err := ctx.Client().CancelJob(context.Background(), c.ID)
+		switch {
+		case err == nil:
+			fmt.Printf("Job cancelled successfully with ID: %s\n", c.ID)
+		case errors.Is(err, client.ErrNotFound):
+			fmt.Printf("%s does not exist in your jobs.\n", c.ID)
+			fmt.Printf("Use the command \"job list\" for your list of jobs.")
+		case errors.Is(err, client.ErrConflict):
+			fmt.Printf("%s has already finished and cannot be cancelled.\n", c.ID)
+		default:
+			return apiError("failed to cancel job", err)
+		}
 		return nil
 	} else if input == "n" || input == "no" {
 		fmt.Println("Cancelled.")
@@ -66,7 +46,4 @@ func (c *JobCancelCmd) Run(ctx *AppContext) error {
 		fmt.Println("Invalid response.")
 		return nil
 	}
-
-	return nil
-
 }
@@ -1,46 +1,75 @@
-package cmd 
+package cmd
 
 import (
+	"net/http"
 	"testing"
-	"fmt"
 )
 
+func cancelHandler(t *testing.T, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("expected DELETE, got %s", r.Method)
+		}
+		if r.URL.Path != "/jobs/job_12345" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}
+}
 
-// Added job, with no compute type added 
-func TestJobCancelJobDoesNotExist(t *testing.T){
-	// This job should not exist in the dummy 
+// Job the server does not know about
+func TestJobCancelJobDoesNotExist(t *testing.T) {
+	ctx := newTestAppContext(t, cancelHandler(t, http.StatusNotFound))
 	cmd := &JobCancelCmd{ID: "job_12345"}
-	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+	output := CaptureOutput(func() {
+		MockInput("y\n", func() {
+			_ = cmd.Run(ctx)
+		})
 	})
-	if want := "job_12345 does not exist in your jobs.\nUse the command \"job list\" for your list of jobs."; !contains(output, want){
+	if want := "job_12345 does not exist in your jobs.\nUse the command \"job list\" for your list of jobs."; !contains(output, want) {
 		t.Errorf("expected output to contain %q, got %q", want, output)
 	}
 }
 
-// Added job, with compute type 
-func TestJobCancelValid(t *testing.T){
-	// This job should not exist in the dummy 
-	cmd := &JobCancelCmd{ID: "ID:1"}
-	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+// Confirmation prompt
+func TestJobCancelValid(t *testing.T) {
+	ctx := newTestAppContext(t, cancelHandler(t, http.StatusOK))
+	cmd := &JobCancelCmd{ID: "job_12345"}
+	output := CaptureOutput(func() {
+		MockInput("n\n", func() {
+			_ = cmd.Run(ctx)
+		})
 	})
-	if want := "Are you sure you want to cancel ID:1? (y/n):"; !contains(output, want){
+	if want := "Are you sure you want to cancel job_12345? (y/n):"; !contains(output, want) {
 		t.Errorf("expected output to contain %q, got %q", want, output)
 	}
 }
 
-
-func TestJobCancelProceed(t *testing.T){
-	cmd := &JobCancelCmd{ID: "ID:1"}
-	// Lowkey, we should refactor this into a 
-	output := CaptureOutput(func(){
+func TestJobCancelProceed(t *testing.T) {
+	ctx := newTestAppContext(t, cancelHandler(t, http.StatusOK))
+	cmd := &JobCancelCmd{ID: "job_12345"}
+	output := CaptureOutput(func() {
 		MockInput("y\n", func() {
-			_ = cmd.Run()
+			_ = cmd.Run(ctx)
 		})
 	})
-	if !contains(output, "Confirmed, proceeding job cancellation...."){
+	if !contains(output, "Confirmed, proceeding job cancellation....") {
 		t.Errorf("expected 'Confirmed, proceeding job cancellation....' but got:\n%s", output)
 	}
-	// fmt.Printf("Got the output %s\n", output)
-}
\ No newline at end of file
+	if !contains(output, "Job cancelled successfully with ID: job_12345") {
+		t.Errorf("expected success message but got:\n%s", output)
+	}
+}
+
+func TestJobCancelAlreadyFinished(t *testing.T) {
+	ctx := newTestAppContext(t, cancelHandler(t, http.StatusConflict))
+	cmd := &JobCancelCmd{ID: "job_12345"}
+	output := CaptureOutput(func() {
+		MockInput("y\n", func() {
+			_ = cmd.Run(ctx)
+		})
+	})
+	if !contains(output, "job_12345 has already finished") {
+		t.Errorf("expected already finished message but got:\n%s", output)
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"text/tabwriter"
+
+	"mist/cli/client"
+)
+
+type JobDownloadCmd struct {
+	ID     string `arg:"" help:"ID of the job to download the artifacts of"`
+	Path   string `help:"Download only this artifact, e.g. model.pt"`
+	Output string `short:"o" help:"File to save to (default <id>-artifacts.tar.gz, or the artifact's name with --path)"`
+	List   bool   `help:"List the artifacts instead of downloading them"`
+}
+
+func (j *JobDownloadCmd) Run(ctx *AppContext) error {
+	if j.List {
+		return j.list(ctx)
+	}
+
+	output := j.Output
+	if output == "" {
+		output = j.ID + "-artifacts.tar.gz"
+		if j.Path != "" {
+			output = path.Base(j.Path)
+		}
+	}
+
+	// download next to the destination so a failed download leaves nothing behind
+	f, err := os.CreateTemp(filepath.Dir(output), ".mist-download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = ctx.Client().DownloadArtifacts(context.Background(), j.ID, j.Path, f)
+	if errors.Is(err, client.ErrNotFound) {
+		if j.Path != "" {
+			fmt.Printf("Job %s has no artifact %s.\n", j.ID, j.Path)
+		} else {
+			fmt.Printf("No artifacts found for job %s.\n", j.ID)
+		}
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to download artifacts", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	if err := os.Rename(f.Name(), output); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	fmt.Printf("Saved artifacts of job %s to %s\n", j.ID, output)
+	return nil
+}
+
+func (j *JobDownloadCmd) list(ctx *AppContext) error {
+	artifacts, err := ctx.Client().ListArtifacts(context.Background(), j.ID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("No artifacts found for job %s.\n", j.ID)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to list artifacts", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Path\tSize")
+	fmt.Fprintln(w, "--------------------------------------------------------------")
+	for _, artifact := range artifacts {
+		fmt.Fprintf(w, "%s\t%d\n", artifact.Path, artifact.Size)
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func artifactsHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jobs/job_1/artifacts":
+			w.Write([]byte(`{"job_id":"job_1","artifacts":[{"path":"model.pt","size":7},{"path":"logs/train.log","size":9}]}`))
+		case "/jobs/job_1/artifacts/download":
+			if p := r.URL.Query().Get("path"); p != "" && p != "model.pt" {
+				http.Error(w, "Artifact not found: "+p, http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("weights"))
+		default:
+			http.Error(w, "No artifacts for job", http.StatusNotFound)
+		}
+	}
+}
+
+func TestJobDownload(t *testing.T) {
+	ctx := newTestAppContext(t, artifactsHandler(t))
+	output := filepath.Join(t.TempDir(), "out.tar.gz")
+	cmd := &JobDownloadCmd{ID: "job_1", Output: output}
+	out := CaptureOutput(func() {
+		if err := cmd.Run(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !contains(out, "Saved artifacts of job job_1 to "+output) {
+		t.Errorf("expected a saved message, got %q", out)
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "weights" {
+		t.Errorf("expected the download in %s, got %q, %v", output, data, err)
+	}
+}
+
+func TestJobDownloadMissingArtifact(t *testing.T) {
+	ctx := newTestAppContext(t, artifactsHandler(t))
+	dir := t.TempDir()
+	cmd := &JobDownloadCmd{ID: "job_1", Path: "missing.txt", Output: filepath.Join(dir, "missing.txt")}
+	out := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if !contains(out, "Job job_1 has no artifact missing.txt.") {
+		t.Errorf("expected a not found message, got %q", out)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no files to be left behind, found %d", len(entries))
+	}
+}
+
+func TestJobDownloadList(t *testing.T) {
+	ctx := newTestAppContext(t, artifactsHandler(t))
+	cmd := &JobDownloadCmd{ID: "job_1", List: true}
+	out := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	for _, want := range []string{"model.pt        7", "logs/train.log  9"} {
+		if !contains(out, want) {
+			t.Errorf("expected output to contain %q, got %q", want, out)
+		}
+	}
+}
@@ -1,67 +1,78 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"text/tabwriter"
 	"time"
+
+	"mist/cli/client"
 )
 
 type ListCmd struct {
-	All bool `help:"List all jobs, including completed and failed ones." short:"a"`
+	All   bool   `help:"List all jobs, including completed and failed ones." short:"a"`
+	GPU   string `help:"Only list jobs for this GPU type." short:"g"`
+	Limit int    `help:"Maximum number of jobs to list." short:"n" default:"100"`
 }
 
-type Job struct {
-	ID        string
-	Name      string
-	Status    string
-	GPUType   string
-	CreatedAt time.Time
-}
+// activeJobStates are the states listed when --all is not given.
+var activeJobStates = []string{"Waiting", "Scheduled", "InProgress"}
 
 func (l *ListCmd) Run(ctx *AppContext) error {
-	// Mock data - pull from API in real implementation
-	jobs := []Job{
-		{
-			ID:        "ID:1",
-			Name:      "docker_container_name_1",
-			Status:    "Running",
-			GPUType:   "AMD",
-			CreatedAt: time.Now(),
-		},
-		{
-			ID:        "ID:2",
-			Name:      "docker_container_name_2",
-			Status:    "Enqueued",
-			GPUType:   "TT",
-			CreatedAt: time.Now().Add(-time.Hour * 24),
-		},
-		{
-			ID:        "ID:3",
-			Name:      "docker_container_name_3",
-			Status:    "Running",
-			GPUType:   "TT",
-			CreatedAt: time.Now().Add(-time.Hour * 24),
-		},
+	jobs, err := l.fetchJobs(ctx.Client())
+	if err != nil {
+		return apiError("failed to list jobs", err)
 	}
+
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Job ID\tName\tStatus\tGPU Type\tCreated At")
+	fmt.Fprintln(w, "Job ID\tType\tStatus\tGPU Type\tCreated At")
 	fmt.Fprintln(w, "--------------------------------------------------------------")
 
 	for _, job := range jobs {
-		// Maybe filter based on running?
 		fmt.Fprintf(
 			w,
 			"%s\t%s\t%s\t%s\t%s\n",
 			job.ID,
-			job.Name,
-			job.Status,
-			job.GPUType,
-			job.CreatedAt.Format(time.RFC1123),
+			job.Type,
+			job.JobState,
+			job.RequiredGPU,
+			job.Created.Format(time.RFC1123),
 		)
 	}
 
 	w.Flush()
 
+	if len(jobs) == 0 {
+		fmt.Println("No jobs found.")
+	}
+
 	return nil
 }
+
+// fetchJobs pages through the job list until Limit jobs were read or there
+// are no more.
+func (l *ListCmd) fetchJobs(c *client.Client) ([]client.Job, error) {
+	opts := client.ListJobsOptions{GPU: l.GPU}
+	if !l.All {
+		opts.States = activeJobStates
+	}
+
+	var jobs []client.Job
+	for l.Limit <= 0 || len(jobs) < l.Limit {
+		if l.Limit > 0 {
+			opts.Limit = l.Limit - len(jobs)
+		}
+		page, err := c.ListJobs(context.Background(), opts)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, page.Jobs...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	return jobs, nil
+}
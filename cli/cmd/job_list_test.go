@@ -1,18 +1,53 @@
-package cmd 
+package cmd
 
 import (
+	"encoding/json"
+	"net/http"
 	"testing"
+
+	"mist/cli/client"
 )
 
-// Will be more specific in the future!
-func TestJobList(t *testing.T){
-	cmd := &ListCmd{All: true}
-	output := CaptureOutput(func(){
-		_ = cmd.Run()
+// Active jobs only unless --all
+func TestJobList(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/jobs" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("state"); got != "Waiting,Scheduled,InProgress" {
+			t.Errorf("expected active states filter, got %q", got)
+		}
+		w.Write([]byte(`{"jobs":[{"id":"job_1","type":"train","job_state":"InProgress","required_gpu":"AMD","created":"2025-01-02T03:04:05Z"}],"count":1}`))
+	})
+	cmd := &ListCmd{Limit: 100}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
 	})
-	// Note the time is dynamic. 
-	want := "Job ID  Name  Status  GPU Type  Created At\n--------------------------------------------------------------\nID:1  docker_container_name_1  Running   AMD"	
-	if !contains(output, want){
+	want := "Job ID  Type  Status  GPU Type  Created At\n--------------------------------------------------------------\njob_1  train  InProgress  AMD"
+	if !contains(output, want) {
 		t.Errorf("expected output to contain %q, got %q", want, output)
 	}
-	}
\ No newline at end of file
+}
+
+// Follows next_cursor across pages
+func TestJobListAllPages(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("state"); got != "" {
+			t.Errorf("expected no state filter with --all, got %q", got)
+		}
+		page := client.JobPage{Jobs: []client.Job{{ID: "job_1", JobState: "Success"}}, Count: 1, NextCursor: "next"}
+		if r.URL.Query().Get("cursor") == "next" {
+			page = client.JobPage{Jobs: []client.Job{{ID: "job_2", JobState: "Failure"}}, Count: 1}
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+	cmd := &ListCmd{All: true, Limit: 100}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	for _, want := range []string{"job_1", "job_2"} {
+		if !contains(output, want) {
+			t.Errorf("expected output to contain %q, got %q", want, output)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"mist/cli/client"
+)
+
+type JobLogsCmd struct {
+	ID     string `arg:"" help:"ID of the job to show the output of"`
+	Follow bool   `short:"f" help:"Keep printing new output until the job finishes"`
+}
+
+func (j *JobLogsCmd) Run(ctx *AppContext) error {
+	err := ctx.Client().JobLogs(context.Background(), j.ID, j.Follow, os.Stdout)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, client.ErrNotFound):
+		fmt.Printf("%s does not exist in your jobs.\n", j.ID)
+		fmt.Printf("Use the command \"job list\" for your list of jobs.")
+		return nil
+	case errors.Is(err, client.ErrBadRequest):
+		fmt.Println("Error:", err)
+		return nil
+	default:
+		return apiError("failed to get job logs", err)
+	}
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+)
+
+func logsHandler(t *testing.T, wantFollow string, status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/jobs/job_1/logs" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("follow"); got != wantFollow {
+			t.Errorf("expected follow=%q, got %q", wantFollow, got)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestJobLogs(t *testing.T) {
+	ctx := newTestAppContext(t, logsHandler(t, "", http.StatusOK, "epoch 1\nepoch 2\n"))
+	cmd := &JobLogsCmd{ID: "job_1"}
+	output := CaptureOutput(func() {
+		if err := cmd.Run(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if output != "epoch 1\nepoch 2\n" {
+		t.Errorf("expected the job output, got %q", output)
+	}
+}
+
+func TestJobLogsFollow(t *testing.T) {
+	ctx := newTestAppContext(t, logsHandler(t, "true", http.StatusOK, "done\n"))
+	cmd := &JobLogsCmd{ID: "job_1", Follow: true}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if output != "done\n" {
+		t.Errorf("expected the job output, got %q", output)
+	}
+}
+
+func TestJobLogsJobDoesNotExist(t *testing.T) {
+	ctx := newTestAppContext(t, logsHandler(t, "", http.StatusNotFound, "Job not found: job_1"))
+	cmd := &JobLogsCmd{ID: "job_1"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if want := "job_1 does not exist in your jobs."; !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
+
+	"mist/cli/client"
 )
 
 type JobStatusCmd struct {
@@ -12,36 +17,73 @@ type JobStatusCmd struct {
 }
 
 func (j *JobStatusCmd) Run(ctx *AppContext) error {
-	// Mock data - pull from API in real implementation
-	jobs := []Job{{
-		ID:        "ID:1",
-		Name:      "docker_container_name_1",
-		Status:    "Running",
-		GPUType:   "AMD",
-		CreatedAt: time.Now(),
-	}}
-
-	job, err := findJobByID(jobs, j.ID)
-	if err != nil {
+	job, err := ctx.Client().GetJob(context.Background(), j.ID)
+	if errors.Is(err, client.ErrNotFound) {
 		fmt.Printf("%s does not exist in your jobs.\n", j.ID)
 		fmt.Printf("Use the command \"job list\" for your list of jobs.")
 		return nil
 	}
+	if err != nil {
+		return apiError("failed to get job status", err)
+	}
 
-	println("Checking status for job ID:", j.ID)
+	fmt.Println("Checking status for job ID:", j.ID)
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Job ID\tName\tStatus\tGPU Type\tCreated At")
+	fmt.Fprintln(w, "Job ID\tType\tStatus\tGPU Type\tCreated At")
 	fmt.Fprintln(w, "--------------------------------------------------------------")
 
 	fmt.Fprintf(
 		w,
 		"%s\t%s\t%s\t%s\t%s\n",
 		job.ID,
-		job.Name,
-		job.Status,
-		job.GPUType,
-		job.CreatedAt.Format(time.RFC1123),
+		job.Type,
+		job.JobState,
+		job.RequiredGPU,
+		job.Created.Format(time.RFC1123),
 	)
 	w.Flush()
+
+	if len(job.DependsOn) > 0 {
+		fmt.Println("Depends On:", strings.Join(job.DependsOn, ", "))
+	}
+	if job.WorkflowID != "" {
+		fmt.Println("Workflow:", job.WorkflowID)
+	}
+	if job.RunAfter != nil && job.JobState == "Scheduled" {
+		fmt.Println("Runs After:", job.RunAfter.Format(time.RFC1123))
+	}
+	if job.ConsumerID != nil {
+		fmt.Println("Supervisor:", *job.ConsumerID)
+	}
+	if job.TimeCompleted != nil {
+		fmt.Println("Completed At:", job.TimeCompleted.Format(time.RFC1123))
+	}
+	if code, ok := job.Result["exit_code"].(float64); ok {
+		if signal, _ := job.Result["signal"].(string); signal != "" {
+			fmt.Printf("Exit Code: %d (%s)\n", int64(code), signal)
+		} else {
+			fmt.Println("Exit Code:", int64(code))
+		}
+	}
+	if reason, _ := job.Result["reason"].(string); reason != "" {
+		if hint := exitHints[reason]; hint != "" {
+			fmt.Printf("Reason: %s, %s\n", reason, fmt.Sprintf(hint, job.ID))
+		} else {
+			fmt.Println("Reason:", reason)
+		}
+	}
+	if job.Error != nil {
+		fmt.Println("Error:", *job.Error)
+	}
 	return nil
 }
+
+// exitHints tell users what to do about each reason a job's container can
+// stop without succeeding. %s is the job ID.
+var exitHints = map[string]string{
+	"exit":    "the job's code failed; see mist job logs %s",
+	"signal":  "the job's process crashed; see mist job logs %s",
+	"OOM":     "the job ran out of memory; resubmit with a larger --memory",
+	"timeout": "the job ran out of time; resubmit with a longer --timeout",
+	"error":   "the container could not run the job; check its image and command",
+}
@@ -1,29 +1,68 @@
 package cmd
 
 import (
+	"net/http"
 	"testing"
 )
 
-// Added job, with no compute type added
+func statusHandler(t *testing.T, status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/jobs/status" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// Job the server does not know about
 func TestJobStatusJobDoesNotExist(t *testing.T) {
-	// This job should not exist in the dummy
+	ctx := newTestAppContext(t, statusHandler(t, http.StatusNotFound, "Job not found: job_12345"))
 	cmd := &JobStatusCmd{ID: "job_12345"}
 	output := CaptureOutput(func() {
-		_ = cmd.Run()
+		_ = cmd.Run(ctx)
 	})
 	if want := "job_12345 does not exist in your jobs.\nUse the command \"job list\" for your list of jobs."; !contains(output, want) {
 		t.Errorf("expected output to contain %q, got %q", want, output)
 	}
 }
 
-// Added job, with compute type
+// Job with an assigned supervisor
 func TestJobStatusValid(t *testing.T) {
-	// This job should not exist in the dummy
-	cmd := &JobStatusCmd{ID: "ID:1"}
+	body := `{"id":"job_1","type":"train","job_state":"InProgress","required_gpu":"TT","created":"2025-01-02T03:04:05Z","consumer_id":"worker_7"}`
+	ctx := newTestAppContext(t, statusHandler(t, http.StatusOK, body))
+	cmd := &JobStatusCmd{ID: "job_1"}
 	output := CaptureOutput(func() {
-		_ = cmd.Run()
+		_ = cmd.Run(ctx)
 	})
-	if want := "docker_container_name_1"; !contains(output, want) {
-		t.Errorf("expected output to contain %q, got %q", want, output)
+	for _, want := range []string{"job_1  train  InProgress  TT", "Supervisor: worker_7"} {
+		if !contains(output, want) {
+			t.Errorf("expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+// Job killed for running out of memory
+func TestJobStatusExitReason(t *testing.T) {
+	body := `{"id":"job_1","type":"train","job_state":"Failure","required_gpu":"AMD","created":"2025-01-02T03:04:05Z",` +
+		`"result":{"exit_code":137,"reason":"OOM","signal":"SIGKILL","oom_killed":true},"error":"job ran out of memory (limit 32GiB)"}`
+	ctx := newTestAppContext(t, statusHandler(t, http.StatusOK, body))
+	cmd := &JobStatusCmd{ID: "job_1"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	for _, want := range []string{"Exit Code: 137 (SIGKILL)", "Reason: OOM, the job ran out of memory; resubmit with a larger --memory", "Error: job ran out of memory"} {
+		if !contains(output, want) {
+			t.Errorf("expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestJobStatusUnauthorized(t *testing.T) {
+	ctx := newTestAppContext(t, statusHandler(t, http.StatusUnauthorized, "unauthorized"))
+	cmd := &JobStatusCmd{ID: "job_1"}
+	err := cmd.Run(ctx)
+	if err == nil || !contains(err.Error(), "mist auth login") {
+		t.Errorf("expected a login hint, got %v", err)
 	}
 }
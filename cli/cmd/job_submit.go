@@ -2,21 +2,34 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"mist/cli/client"
 )
 
 type JobSubmitCmd struct {
-	Script  string `arg:"" help:"Path to the job script file to submit"`
-	Compute string `help:"Type of compute required for the job: AMD|TT|CPU" default:"AMD"`
+	Script    string        `arg:"" help:"Path to the job script file to submit"`
+	Compute   string        `help:"Type of compute required for the job: AMD|TT|CPU" default:"AMD"`
+	Priority  string        `help:"Priority of the job among your own: low|normal|high" enum:"low,normal,high" default:"normal"`
+	RunAfter  string        `help:"Do not start the job before this time (RFC 3339, e.g. 2025-03-01T22:00:00Z)" name:"run-after"`
+	Delay     time.Duration `help:"Do not start the job before this much time has passed (e.g. 8h)"`
+	DependsOn []string      `help:"Only start the job once these jobs have succeeded" name:"depends-on" sep:","`
+	OutputDir string        `help:"Directory in the container to keep as the job's artifacts (e.g. /data/outputs)" name:"output-dir"`
+	Input     []string      `help:"Small input file to copy to /data next to the script (repeatable)"`
+	CPUs      float64       `help:"CPU cores for the job (default depends on the compute type)" name:"cpus"`
+	Memory    string        `help:"Memory limit for the job (e.g. 16g)"`
+	ShmSize   string        `help:"Size of /dev/shm for the job (e.g. 4g)" name:"shm-size"`
+	Timeout   time.Duration `help:"Stop the job once it has run this long (e.g. 6h)"`
 }
 
 func (j *JobSubmitCmd) Run(ctx *AppContext) error {
 	// mist job submit <script> <compute_type>
 
-	// TODO: ADD AUTH CHECK
-
 	// TODO: MAKE THIS GLOBAL OR LOADED FROM ENV?
 	// Validate compute type
 	validComputeTypes := map[string]bool{
@@ -25,17 +38,35 @@ func (j *JobSubmitCmd) Run(ctx *AppContext) error {
 		"CPU": true,
 	}
 
-	// Validate script file exists
-	// if _, err := os.Stat(j.Script); os.IsNotExist(err) {
-	// 	fmt.Println("Error: Script file does not exist at path:", j.Script)
-	// 	return nil
-	// }
-
 	if !validComputeTypes[strings.ToUpper(j.Compute)] {
 		fmt.Println("Error: Invalid compute type. Valid options are: AMD, TT, CPU")
 		return nil
 	}
 
+	var runAfter *time.Time
+	if j.RunAfter != "" {
+		t, err := time.Parse(time.RFC3339, j.RunAfter)
+		if err != nil {
+			fmt.Println("Error: Invalid --run-after time. Use RFC 3339, e.g. 2025-03-01T22:00:00Z")
+			return nil
+		}
+		runAfter = &t
+	}
+	if runAfter != nil && j.Delay != 0 {
+		fmt.Println("Error: Use either --run-after or --delay, not both")
+		return nil
+	}
+	var delay string
+	if j.Delay != 0 {
+		delay = j.Delay.String()
+	}
+
+	// Validate script and input files exist
+	if err := checkJobFiles(j.Script, j.Input); err != nil {
+		fmt.Println("Error:", err)
+		return nil
+	}
+
 	// Maybe turn this into some type of wrapper function later?
 	fmt.Print("Are you sure? (y/n): ")
 
@@ -46,10 +77,45 @@ func (j *JobSubmitCmd) Run(ctx *AppContext) error {
 	if input == "y" || input == "yes" {
 		fmt.Println("Confirmed, proceeding...")
 
-		// CONFIRMED LOGIC
 		fmt.Println("Submitting job with script:", j.Script)
 		fmt.Println("Requested GPU type:", j.Compute)
-		println("Job submitted successfully with ID: job_12345")
+		fmt.Println("Priority:", j.Priority)
+		if len(j.DependsOn) > 0 {
+			fmt.Println("Depends on:", strings.Join(j.DependsOn, ", "))
+		}
+		if j.OutputDir != "" {
+			fmt.Println("Artifacts from:", j.OutputDir)
+		}
+		if runAfter != nil {
+			fmt.Println("Runs after:", runAfter.Format(time.RFC1123))
+		} else if delay != "" {
+			fmt.Println("Delayed by:", delay)
+		}
+
+		payload, err := scriptPayload(ctx, j.Script, j.Input)
+		if err != nil {
+			return err
+		}
+		if j.OutputDir != "" {
+			payload["output_dir"] = j.OutputDir
+		}
+		if resources := j.resources(); len(resources) > 0 {
+			payload["resources"] = resources
+		}
+
+		jobID, err := ctx.Client().SubmitJob(context.Background(), client.SubmitJobRequest{
+			Type:        "script",
+			Payload:     payload,
+			RequiredGPU: strings.ToUpper(j.Compute),
+			Priority:    j.Priority,
+			RunAfter:    runAfter,
+			Delay:       delay,
+			DependsOn:   j.DependsOn,
+		})
+		if err != nil {
+			return apiError("failed to submit job", err)
+		}
+		fmt.Println("Job submitted successfully with ID:", jobID)
 
 		return nil
 
@@ -59,3 +125,67 @@ func (j *JobSubmitCmd) Run(ctx *AppContext) error {
 	}
 
 }
+
+// resources returns the resource limits set by flags, leaving the others to
+// the server's defaults for the compute type.
+func (j *JobSubmitCmd) resources() map[string]interface{} {
+	resources := make(map[string]interface{})
+	if j.CPUs != 0 {
+		resources["cpus"] = j.CPUs
+	}
+	if j.Memory != "" {
+		resources["memory"] = j.Memory
+	}
+	if j.ShmSize != "" {
+		resources["shm_size"] = j.ShmSize
+	}
+	if j.Timeout != 0 {
+		resources["timeout"] = j.Timeout.String()
+	}
+	return resources
+}
+
+// checkJobFiles makes sure the script and input files exist and do not end up
+// at the same path in /data.
+func checkJobFiles(script string, inputs []string) error {
+	names := make(map[string]string)
+	for _, file := range append([]string{script}, inputs...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", file, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", file)
+		}
+		name := filepath.Base(file)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("%s and %s would both be /data/%s", other, file, name)
+		}
+		names[name] = file
+	}
+	return nil
+}
+
+// scriptPayload uploads the script and input files and returns the payload
+// of a job running the script, with the files in /data.
+func scriptPayload(ctx *AppContext, script string, inputs []string) (map[string]interface{}, error) {
+	var jobInputs []client.JobInput
+	for _, file := range append([]string{script}, inputs...) {
+		upload, err := ctx.Client().UploadFile(context.Background(), file)
+		if err != nil {
+			return nil, apiError("failed to upload "+file, err)
+		}
+		jobInputs = append(jobInputs, client.JobInput{Path: filepath.Base(file), SHA256: upload.SHA256})
+	}
+
+	name := filepath.Base(script)
+	interpreter := "sh"
+	if filepath.Ext(name) == ".py" {
+		interpreter = "python"
+	}
+	return map[string]interface{}{
+		"script": name,
+		"inputs": jobInputs,
+		"args":   []string{interpreter, "/data/" + name},
+	}, nil
+}
@@ -1,45 +1,64 @@
-package cmd 
+package cmd
 
 import (
+	"encoding/json"
+	"net/http"
 	"testing"
-	// "os"
-	// "fmt"
-)
+	"time"
 
+	"mist/cli/client"
+)
 
-// Just printing out the confirmation 
-func TestJobSubmitConfirmation(t *testing.T){
-	// This job should not exist in the dummy 
-	cmd := &JobSubmitCmd{Script: "test", Compute:"TT"}
-	output := CaptureOutput(func(){
-		_ = cmd.Run() 
+// Just printing out the confirmation
+func TestJobSubmitConfirmation(t *testing.T) {
+	inTempDir(t, "test")
+	// This job should not exist in the dummy
+	cmd := &JobSubmitCmd{Script: "test", Compute: "TT"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(&AppContext{})
 	})
-	if want := "Are you sure? (y/n): "; !contains(output, want){
+	if want := "Are you sure? (y/n): "; !contains(output, want) {
 		t.Errorf("expected output to contain %q, got %q", want, output)
 	}
 }
 
-// Valid proceeding with TT work 
-func TestJobSubmitProceed(t *testing.T){
-	cmd := &JobSubmitCmd{Script: "test", Compute: "TT"}
-	output := CaptureOutput(func(){
+// Valid proceeding with TT work
+func TestJobSubmitProceed(t *testing.T) {
+	inTempDir(t, "test")
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		var req client.SubmitJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/jobs" || req.RequiredGPU != "TT" || req.Priority != "high" {
+			t.Errorf("unexpected request %s %s %+v", r.Method, r.URL.Path, req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_12345"}`))
+	}))
+	cmd := &JobSubmitCmd{Script: "test", Compute: "TT", Priority: "high"}
+	output := CaptureOutput(func() {
 		MockInput("y\n", func() {
-			_ = cmd.Run()
+			_ = cmd.Run(ctx)
 		})
 
 	})
-	
+
 	if !contains(output, "Confirmed, proceeding...\nSubmitting job with script: test\nRequested GPU type: TT") {
 		t.Errorf("expected 'Confirmed, proceeding...' but got:\n%s", output)
 	}
+	if !contains(output, "Job submitted successfully with ID: job_12345") {
+		t.Errorf("expected the job ID but got:\n%s", output)
+	}
 }
 
-// Valid Cancellation: Putting in N 
-func TestJobSubmitCancel(t *testing.T){
+// Valid Cancellation: Putting in N
+func TestJobSubmitCancel(t *testing.T) {
+	inTempDir(t, "test")
 	cmd := &JobSubmitCmd{Script: "test", Compute: "TT"}
-	output := CaptureOutput(func(){
+	output := CaptureOutput(func() {
 		MockInput("n\n", func() {
-			_ = cmd.Run()
+			_ = cmd.Run(&AppContext{})
 		})
 	})
 
@@ -49,12 +68,13 @@ func TestJobSubmitCancel(t *testing.T){
 	// fmt.Printf("Got the output %s", output)
 }
 
-// Valid Cancellation: Putting in bogus response 
-func TestJobSubmitBogusResponse(t *testing.T){
+// Valid Cancellation: Putting in bogus response
+func TestJobSubmitBogusResponse(t *testing.T) {
+	inTempDir(t, "test")
 	cmd := &JobSubmitCmd{Script: "test", Compute: "TT"}
-	output := CaptureOutput(func(){
+	output := CaptureOutput(func() {
 		MockInput("bogus\n", func() {
-			_ = cmd.Run()
+			_ = cmd.Run(&AppContext{})
 		})
 	})
 
@@ -62,4 +82,140 @@ func TestJobSubmitBogusResponse(t *testing.T){
 		t.Errorf("expected 'Cancelled.' but got:\n%s", output)
 	}
 	// fmt.Printf("Got the output %s", output)
-}
\ No newline at end of file
+}
+
+// Delayed submission sends the delay along
+func TestJobSubmitDelay(t *testing.T) {
+	inTempDir(t, "test")
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		var req client.SubmitJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.Delay != "8h0m0s" || req.RunAfter != nil {
+			t.Errorf("unexpected request %+v", req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_12345"}`))
+	}))
+	cmd := &JobSubmitCmd{Script: "test", Compute: "TT", Delay: 8 * time.Hour}
+	output := CaptureOutput(func() {
+		MockInput("y\n", func() {
+			_ = cmd.Run(ctx)
+		})
+	})
+
+	if !contains(output, "Delayed by: 8h0m0s") {
+		t.Errorf("expected the delay but got:\n%s", output)
+	}
+}
+
+// Both --run-after and --delay are rejected before asking
+func TestJobSubmitRunAfterAndDelay(t *testing.T) {
+	cmd := &JobSubmitCmd{Script: "test", Compute: "TT", RunAfter: "2025-03-01T22:00:00Z", Delay: time.Hour}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(&AppContext{})
+	})
+
+	if !contains(output, "Use either --run-after or --delay") || contains(output, "Are you sure?") {
+		t.Errorf("expected the flags to be rejected but got:\n%s", output)
+	}
+}
+
+// Jobs can wait for other jobs to succeed
+func TestJobSubmitDependsOn(t *testing.T) {
+	inTempDir(t, "evaluate.py")
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		var req client.SubmitJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if len(req.DependsOn) != 2 || req.DependsOn[0] != "job_1" || req.DependsOn[1] != "job_2" {
+			t.Errorf("unexpected request %+v", req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_3"}`))
+	}))
+	cmd := &JobSubmitCmd{Script: "evaluate.py", Compute: "CPU", DependsOn: []string{"job_1", "job_2"}}
+	output := CaptureOutput(func() {
+		MockInput("y\n", func() {
+			_ = cmd.Run(ctx)
+		})
+	})
+
+	if !contains(output, "Depends on: job_1, job_2") || !contains(output, "ID: job_3") {
+		t.Errorf("expected the dependencies and job ID but got:\n%s", output)
+	}
+}
+
+// The script and input files are uploaded and copied to /data
+func TestJobSubmitUploadsFiles(t *testing.T) {
+	inTempDir(t, "train.py", "labels.csv")
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		var req client.SubmitJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		inputs, _ := req.Payload["inputs"].([]interface{})
+		if len(inputs) != 2 || inputs[1].(map[string]interface{})["path"] != "labels.csv" {
+			t.Errorf("expected the script and labels.csv as inputs, got %v", req.Payload["inputs"])
+		}
+		if args, _ := req.Payload["args"].([]interface{}); len(args) != 2 || args[0] != "python" || args[1] != "/data/train.py" {
+			t.Errorf("expected the script to be run with python, got %v", req.Payload["args"])
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_1"}`))
+	}))
+	cmd := &JobSubmitCmd{Script: "train.py", Compute: "CPU", Input: []string{"labels.csv"}}
+	output := CaptureOutput(func() {
+		MockInput("y\n", func() {
+			_ = cmd.Run(ctx)
+		})
+	})
+
+	if !contains(output, "ID: job_1") {
+		t.Errorf("expected the job ID but got:\n%s", output)
+	}
+}
+
+// A missing script is rejected before asking
+func TestJobSubmitMissingScript(t *testing.T) {
+	inTempDir(t)
+	cmd := &JobSubmitCmd{Script: "train.py", Compute: "CPU"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(&AppContext{})
+	})
+
+	if !contains(output, "Error: cannot read train.py") || contains(output, "Are you sure?") {
+		t.Errorf("expected the missing script to be rejected but got:\n%s", output)
+	}
+}
+
+// Resource flags end up in the job payload
+func TestJobSubmitResources(t *testing.T) {
+	inTempDir(t, "train.py")
+	var payload map[string]interface{}
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		var req client.SubmitJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		payload = req.Payload
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"job_id":"job_12345"}`))
+	}))
+	cmd := &JobSubmitCmd{Script: "train.py", Compute: "AMD", Priority: "normal", Memory: "16g", Timeout: 6 * time.Hour}
+	CaptureOutput(func() {
+		MockInput("y\n", func() {
+			_ = cmd.Run(ctx)
+		})
+	})
+
+	resources, _ := payload["resources"].(map[string]interface{})
+	if resources["memory"] != "16g" || resources["timeout"] != "6h0m0s" {
+		t.Errorf("unexpected resources %v", payload["resources"])
+	}
+	if _, ok := resources["cpus"]; ok {
+		t.Errorf("expected cpus to be left to the server, got %v", resources)
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"mist/cli/client"
+)
+
+type QuotaCmd struct {
+	Show QuotaShowCmd `cmd:"" help:"Show quota limits and usage" default:"1"`
+	Set  QuotaSetCmd  `cmd:"" help:"Set the quota of a user or team (admin only)"`
+}
+
+type QuotaShowCmd struct {
+	User string `help:"Show the quota of this user (admin only)."`
+	Team string `help:"Show the quota of this team (admin only)."`
+}
+
+func (q *QuotaShowCmd) Run(ctx *AppContext) error {
+	quotas, err := ctx.Client().GetQuotas(context.Background(), q.User, q.Team)
+	if err != nil {
+		return apiError("failed to get quotas", err)
+	}
+	if len(quotas) == 0 {
+		fmt.Println("No quotas found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Scope\tRunning Jobs\tQueued Jobs\tGPU Hours (7d)")
+	fmt.Fprintln(w, "--------------------------------------------------------------")
+	for _, quota := range quotas {
+		usage := client.QuotaUsage{}
+		if quota.Usage != nil {
+			usage = *quota.Usage
+		}
+		fmt.Fprintf(
+			w,
+			"%s %s\t%s\t%s\t%s\n",
+			quota.Kind,
+			quota.Name,
+			formatLimit(fmt.Sprint(usage.RunningJobs), quota.Policy.MaxConcurrentJobs > 0, fmt.Sprint(quota.Policy.MaxConcurrentJobs)),
+			formatLimit(fmt.Sprint(usage.QueuedJobs), quota.Policy.MaxQueuedJobs > 0, fmt.Sprint(quota.Policy.MaxQueuedJobs)),
+			formatLimit(fmt.Sprintf("%.1f", usage.GPUHours), quota.Policy.GPUHoursPerWeek > 0, fmt.Sprintf("%.1f", quota.Policy.GPUHoursPerWeek)),
+		)
+	}
+	w.Flush()
+	return nil
+}
+
+// formatLimit renders usage against a limit, or as unlimited.
+func formatLimit(used string, limited bool, limit string) string {
+	if !limited {
+		return used + " / unlimited"
+	}
+	return used + " / " + limit
+}
+
+type QuotaSetCmd struct {
+	User          string  `help:"User to set the quota of." xor:"subject"`
+	Team          string  `help:"Team to set the quota of." xor:"subject"`
+	Default       string  `help:"Set the default quota for every user or team." enum:"user,team," default:"" xor:"subject"`
+	MaxConcurrent int     `help:"Maximum jobs running at once (0 for unlimited)."`
+	MaxQueued     int     `help:"Maximum jobs waiting to run (0 for unlimited)."`
+	GPUHours      float64 `help:"GPU hours per rolling week (0 for unlimited)." name:"gpu-hours"`
+}
+
+func (q *QuotaSetCmd) Run(ctx *AppContext) error {
+	kind, name := "", ""
+	switch {
+	case q.User != "":
+		kind, name = "user", q.User
+	case q.Team != "":
+		kind, name = "team", q.Team
+	case q.Default != "":
+		kind = q.Default
+	default:
+		fmt.Println("Error: one of --user, --team or --default is required.")
+		return nil
+	}
+
+	policy := client.QuotaPolicy{
+		MaxConcurrentJobs: q.MaxConcurrent,
+		MaxQueuedJobs:     q.MaxQueued,
+		GPUHoursPerWeek:   q.GPUHours,
+	}
+	err := ctx.Client().SetQuota(context.Background(), kind, name, policy)
+	if errors.Is(err, client.ErrBadRequest) {
+		fmt.Println("Error:", err)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to set quota", err)
+	}
+
+	if name == "" {
+		fmt.Printf("Default %s quota updated.\n", kind)
+	} else {
+		fmt.Printf("Quota for %s %s updated.\n", kind, name)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"mist/cli/client"
+)
+
+func TestQuotaShow(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/quotas" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"quotas":[{"kind":"user","name":"alice","policy":{"max_concurrent_jobs":2,"max_queued_jobs":0,"gpu_hours_per_week":10},"usage":{"queued_jobs":1,"running_jobs":1,"gpu_hours":2.5}}],"count":1}`))
+	})
+	cmd := &QuotaShowCmd{}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	want := "user alice  1 / 2  1 / unlimited  2.5 / 10.0"
+	if !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
+
+func TestQuotaSetTeam(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		var policy client.QuotaPolicy
+		json.NewDecoder(r.Body).Decode(&policy)
+		if r.Method != http.MethodPut || r.URL.Path != "/quotas/team/club" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if policy.MaxQueuedJobs != 3 || policy.GPUHoursPerWeek != 20 {
+			t.Errorf("unexpected policy %+v", policy)
+		}
+		w.Write([]byte(`{}`))
+	})
+	cmd := &QuotaSetCmd{Team: "club", MaxQueued: 3, GPUHours: 20}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if want := "Quota for team club updated."; !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
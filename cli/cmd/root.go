@@ -2,21 +2,45 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/alecthomas/kong"
+
+	"mist/cli/client"
 )
 
 type Config struct {
-	AccessToken string `json:"access_token"`
-	// maybe APIBaseURL, etc.
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	APIBaseURL   string `json:"api_base_url,omitempty"`
 }
 
 type AppContext struct {
 	Config     *Config
+	ConfigPath string
 	HTTPClient *http.Client
+	APIBaseURL string
+}
+
+// Client returns an API client for the configured server, authenticated with
+// the saved tokens when logged in. Tokens refreshed by the client are saved
+// back to the config file.
+func (c *AppContext) Client() *client.Client {
+	api := client.New(c.APIBaseURL, "", c.HTTPClient)
+	if c.Config != nil {
+		api.AccessToken = c.Config.AccessToken
+		api.RefreshToken = c.Config.RefreshToken
+	}
+	api.OnRefresh = func(tokens *client.TokenPair) {
+		if err := saveTokensToConfig(c, tokens); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not save refreshed tokens:", err)
+		}
+	}
+	return api
 }
 
 type Globals struct {
@@ -27,8 +51,10 @@ type CLI struct {
 	Globals
 
 	// Define your CLI structure here: Top Level Commands
-	Auth AuthCmd `cmd:"" help:"Authentication commands"`
-	Job  JobCmd  `cmd:"" help:"Job management commands"`
+	Auth     AuthCmd     `cmd:"" help:"Authentication commands"`
+	Job      JobCmd      `cmd:"" help:"Job management commands"`
+	Schedule ScheduleCmd `cmd:"" help:"Manage recurring jobs"`
+	Quota    QuotaCmd    `cmd:"" help:"Show and manage GPU quotas"`
 	// Config ConfigCmd `cmd:"" help:"Configuration commands"`
 	Help HelpCmd `cmd:"" help:"Show help information"`
 	// Config ConfigCmd `cmd:"" help: "Display Cluster Configuration"`
@@ -54,6 +80,7 @@ func Main() {
 
 	appCtx := &AppContext{
 		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		APIBaseURL: client.DefaultBaseURL,
 	}
 
 	kctx := kong.Parse(&cli,
@@ -64,8 +91,12 @@ func Main() {
 		kong.Bind(appCtx),
 	)
 
+	appCtx.ConfigPath = cli.ConfigPath
 	if cfg, err := loadConfig(cli.ConfigPath); err == nil {
 		appCtx.Config = cfg
+		if cfg.APIBaseURL != "" {
+			appCtx.APIBaseURL = cfg.APIBaseURL
+		}
 	} else if !os.IsNotExist(err) {
 		// config file is present but broken
 		kctx.FatalIfErrorf(err)
@@ -74,3 +105,17 @@ func Main() {
 	err := kctx.Run()
 	kctx.FatalIfErrorf(err)
 }
+
+// apiError wraps an error returned by the API client with a hint for the
+// cases the user can act on.
+func apiError(action string, err error) error {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		return fmt.Errorf("%s: not logged in or session expired, run \"mist auth login\": %w", action, err)
+	case errors.Is(err, client.ErrForbidden):
+		return fmt.Errorf("%s: permission denied: %w", action, err)
+	case errors.Is(err, client.ErrServer):
+		return fmt.Errorf("%s: the MIST server failed, try again later: %w", action, err)
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mist/cli/client"
+)
+
+type ScheduleCmd struct {
+	List   ScheduleListCmd   `cmd:"" help:"List your recurring jobs" default:"1"`
+	Create ScheduleCreateCmd `cmd:"" help:"Submit a job on a cron schedule"`
+	Status ScheduleStatusCmd `cmd:"" help:"Show a schedule and its last run"`
+	Pause  SchedulePauseCmd  `cmd:"" help:"Stop a schedule from submitting jobs"`
+	Resume ScheduleResumeCmd `cmd:"" help:"Resume a paused schedule"`
+	Delete ScheduleDeleteCmd `cmd:"" help:"Delete a schedule"`
+}
+
+type ScheduleCreateCmd struct {
+	Cron       string   `arg:"" help:"Cron expression, e.g. \"0 2 * * *\" or @daily"`
+	Script     string   `arg:"" help:"Path to the job script to run"`
+	Name       string   `help:"Name of the schedule"`
+	Compute    string   `help:"Type of compute required for the job: AMD|TT|CPU" default:"AMD"`
+	Priority   string   `help:"Priority of the jobs: low|normal|high" enum:"low,normal,high" default:"normal"`
+	Timezone   string   `help:"Timezone the cron expression is in, e.g. America/Toronto (default UTC)"`
+	MissedRuns string   `help:"What to do with runs missed while the scheduler was down: skip|run_once" enum:"skip,run_once" default:"skip"`
+	Input      []string `help:"Small input file to copy to /data next to the script (repeatable)"`
+}
+
+func (s *ScheduleCreateCmd) Run(ctx *AppContext) error {
+	if err := checkJobFiles(s.Script, s.Input); err != nil {
+		fmt.Println("Error:", err)
+		return nil
+	}
+	// every run uses the files as they are now
+	payload, err := scriptPayload(ctx, s.Script, s.Input)
+	if err != nil {
+		return err
+	}
+
+	sched, err := ctx.Client().CreateSchedule(context.Background(), client.CreateScheduleRequest{
+		Name:     s.Name,
+		Cron:     s.Cron,
+		Timezone: s.Timezone,
+		Job: client.SubmitJobRequest{
+			Type:        "script",
+			Payload:     payload,
+			RequiredGPU: strings.ToUpper(s.Compute),
+			Priority:    s.Priority,
+		},
+		MissedRuns: s.MissedRuns,
+	})
+	if errors.Is(err, client.ErrBadRequest) {
+		fmt.Println("Error:", err)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to create schedule", err)
+	}
+
+	fmt.Println("Schedule created with ID:", sched.ID)
+	fmt.Println("Next run:", formatOptionalTime(sched.NextRun))
+	return nil
+}
+
+type ScheduleListCmd struct{}
+
+func (s *ScheduleListCmd) Run(ctx *AppContext) error {
+	schedules, err := ctx.Client().ListSchedules(context.Background())
+	if err != nil {
+		return apiError("failed to list schedules", err)
+	}
+	if len(schedules) == 0 {
+		fmt.Println("No schedules found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Schedule ID\tName\tCron\tNext Run\tLast Job")
+	fmt.Fprintln(w, "--------------------------------------------------------------")
+	for _, sched := range schedules {
+		next := formatOptionalTime(sched.NextRun)
+		if sched.Paused {
+			next = "paused"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", sched.ID, sched.Name, sched.Cron, next, orDash(sched.LastJobID))
+	}
+	w.Flush()
+	return nil
+}
+
+type ScheduleStatusCmd struct {
+	ID string `arg:"" help:"ID of the schedule"`
+}
+
+func (s *ScheduleStatusCmd) Run(ctx *AppContext) error {
+	sched, err := ctx.Client().GetSchedule(context.Background(), s.ID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("%s does not exist in your schedules.\n", s.ID)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to get schedule", err)
+	}
+	printSchedule(sched)
+	return nil
+}
+
+type SchedulePauseCmd struct {
+	ID string `arg:"" help:"ID of the schedule to pause"`
+}
+
+func (s *SchedulePauseCmd) Run(ctx *AppContext) error {
+	_, err := ctx.Client().PauseSchedule(context.Background(), s.ID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("%s does not exist in your schedules.\n", s.ID)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to pause schedule", err)
+	}
+	fmt.Printf("Schedule %s paused.\n", s.ID)
+	return nil
+}
+
+type ScheduleResumeCmd struct {
+	ID string `arg:"" help:"ID of the schedule to resume"`
+}
+
+func (s *ScheduleResumeCmd) Run(ctx *AppContext) error {
+	sched, err := ctx.Client().ResumeSchedule(context.Background(), s.ID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("%s does not exist in your schedules.\n", s.ID)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to resume schedule", err)
+	}
+	fmt.Printf("Schedule %s resumed, next run: %s\n", s.ID, formatOptionalTime(sched.NextRun))
+	return nil
+}
+
+type ScheduleDeleteCmd struct {
+	ID string `arg:"" help:"ID of the schedule to delete"`
+}
+
+func (s *ScheduleDeleteCmd) Run(ctx *AppContext) error {
+	err := ctx.Client().DeleteSchedule(context.Background(), s.ID)
+	if errors.Is(err, client.ErrNotFound) {
+		fmt.Printf("%s does not exist in your schedules.\n", s.ID)
+		return nil
+	}
+	if err != nil {
+		return apiError("failed to delete schedule", err)
+	}
+	fmt.Printf("Schedule %s deleted. Jobs it already submitted are kept.\n", s.ID)
+	return nil
+}
+
+func printSchedule(sched *client.Schedule) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Schedule ID:\t%s\n", sched.ID)
+	if sched.Name != "" {
+		fmt.Fprintf(w, "Name:\t%s\n", sched.Name)
+	}
+	cron := sched.Cron
+	if sched.Timezone != "" {
+		cron += " (" + sched.Timezone + ")"
+	}
+	fmt.Fprintf(w, "Cron:\t%s\n", cron)
+	fmt.Fprintf(w, "Job:\t%s on %s\n", sched.Job.Type, orDash(sched.Job.RequiredGPU))
+	if sched.Paused {
+		fmt.Fprintf(w, "Next Run:\tpaused\n")
+	} else {
+		fmt.Fprintf(w, "Next Run:\t%s\n", formatOptionalTime(sched.NextRun))
+	}
+	fmt.Fprintf(w, "Missed Runs:\t%d (%s)\n", sched.Missed, sched.MissedRuns)
+	fmt.Fprintf(w, "Last Run:\t%s\n", formatOptionalTime(sched.LastRun))
+	if sched.LastJobID != "" {
+		fmt.Fprintf(w, "Last Job:\t%s (%s)\n", sched.LastJobID, orDash(sched.LastJobState))
+	}
+	if sched.LastError != "" {
+		fmt.Fprintf(w, "Last Error:\t%s\n", sched.LastError)
+	}
+	w.Flush()
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC1123)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
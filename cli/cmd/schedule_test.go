@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"mist/cli/client"
+)
+
+func TestScheduleCreate(t *testing.T) {
+	inTempDir(t, "eval.py")
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		var req client.CreateScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/schedules" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if req.Cron != "0 2 * * *" || req.Job.RequiredGPU != "TT" || req.Job.Payload["script"] != "eval.py" || req.MissedRuns != "run_once" {
+			t.Errorf("unexpected schedule %+v", req)
+		}
+		if inputs, _ := req.Job.Payload["inputs"].([]interface{}); len(inputs) != 1 {
+			t.Errorf("expected the script as input, got %v", req.Job.Payload["inputs"])
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"sched_1","cron":"0 2 * * *","next_run":"2025-03-02T02:00:00Z"}`))
+	}))
+	cmd := &ScheduleCreateCmd{Cron: "0 2 * * *", Script: "eval.py", Compute: "tt", Priority: "normal", MissedRuns: "run_once"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if want := "Schedule created with ID: sched_1"; !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
+
+func TestScheduleCreateInvalidCron(t *testing.T) {
+	inTempDir(t, "eval.py")
+	ctx := newTestAppContext(t, withUploads(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid schedule: invalid cron expression", http.StatusBadRequest)
+	}))
+	cmd := &ScheduleCreateCmd{Cron: "nightly", Script: "eval.py"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if want := "invalid cron expression"; !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
+
+func TestScheduleList(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"schedules":[{"id":"sched_1","name":"nightly","cron":"@daily","paused":true,"last_job_id":"job_9"}],"count":1}`))
+	})
+	cmd := &ScheduleListCmd{}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if want := "sched_1  nightly  @daily  paused  job_9"; !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
+
+func TestScheduleStatus(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schedules/sched_1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"sched_1","cron":"@daily","missed_runs":"skip","missed":1,"last_job_id":"job_9","last_job_state":"Completed"}`))
+	})
+	cmd := &ScheduleStatusCmd{ID: "sched_1"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	for _, want := range []string{"job_9 (Completed)", "1 (skip)"} {
+		if !contains(output, want) {
+			t.Errorf("expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestSchedulePauseNotFound(t *testing.T) {
+	ctx := newTestAppContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/schedules/sched_404/pause" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+	})
+	cmd := &SchedulePauseCmd{ID: "sched_404"}
+	output := CaptureOutput(func() {
+		_ = cmd.Run(ctx)
+	})
+	if want := "sched_404 does not exist in your schedules."; !contains(output, want) {
+		t.Errorf("expected output to contain %q, got %q", want, output)
+	}
+}
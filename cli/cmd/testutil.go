@@ -4,7 +4,6 @@ package cmd
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -41,24 +40,6 @@ func contains(s, substr string) bool {
 	return bytes.Contains([]byte(s), []byte(substr))
 }
 
-func jobExists(jobs []Job, id string) bool {
-	for _, job := range jobs {
-		if job.ID == id {
-			return true
-		}
-	}
-	return false
-}
-
-func findJobByID(jobs []Job, id string) (Job, error) {
-	for _, job := range jobs {
-		if job.ID == id {
-			return job, nil
-		}
-	}
-	return Job{}, fmt.Errorf("job with ID %s not found", id)
-}
-
 func defaultConfigPath() string {
 	dir, err := os.UserConfigDir()
 	if err != nil {
@@ -2,4 +2,9 @@ module mist/cli
 
 go 1.23.2
 
-require github.com/alecthomas/kong v1.12.1 // indirect
+require (
+	github.com/alecthomas/kong v1.12.1
+	golang.org/x/term v0.30.0
+)
+
+require golang.org/x/sys v0.31.0 // indirect
@@ -8,8 +8,11 @@ import (
 	"log/slog"
 	log2 "mist/multilogger"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -26,15 +29,24 @@ type App struct {
 	wg             sync.WaitGroup
 	log            *slog.Logger
 	statusRegistry *StatusRegistry
+	auth           *Authenticator
+	quotas         *QuotaManager
+	artifacts      *ArtifactStore
+	uploads        *UploadStore
+	ctx            context.Context
+	cancel         context.CancelFunc
 }
 
-func NewApp(redisAddr, gpuType string, log *slog.Logger) *App {
+func NewApp(redisAddr, gpuType string, log *slog.Logger, opts ...SupervisorOption) *App {
 	client := redis.NewClient(&redis.Options{Addr: redisAddr})
 	scheduler := NewScheduler(redisAddr, log)
 	statusRegistry := NewStatusRegistry(client, log)
+	auth := NewAuthenticator(client, authSecretFromEnv(log), log)
 
 	consumerID := fmt.Sprintf("worker_%d", os.Getpid())
-	supervisor := NewSupervisor(redisAddr, consumerID, gpuType, log)
+	supervisor := NewSupervisor(redisAddr, consumerID, gpuType, log, opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
 
 	mux := http.NewServeMux()
 	a := &App{
@@ -44,15 +56,33 @@ func NewApp(redisAddr, gpuType string, log *slog.Logger) *App {
 		httpServer:     &http.Server{Addr: ":3000", Handler: mux},
 		log:            log,
 		statusRegistry: statusRegistry,
+		auth:           auth,
+		quotas:         NewQuotaManager(client, log),
+		artifacts:      supervisor.artifacts,
+		uploads:        supervisor.uploads,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 
 	mux.HandleFunc("/auth/login", a.login)
 	mux.HandleFunc("/auth/refresh", a.refresh)
-	mux.HandleFunc("/jobs", a.handleJobs)
-	mux.HandleFunc("/jobs/status", a.getJobStatus)
-	mux.HandleFunc("/supervisors/status", a.getSupervisorStatus)
-	mux.HandleFunc("/supervisors/status/", a.getSupervisorStatusByID)
-	mux.HandleFunc("/supervisors", a.getAllSupervisors)
+	mux.HandleFunc("/users", a.requireAdmin(a.createUser))
+	mux.HandleFunc("/jobs", a.requireAuth(a.handleJobs))
+	mux.HandleFunc("/jobs/status", a.requireAuth(a.getJobStatus))
+	mux.HandleFunc("/jobs/", a.requireAuth(a.handleJobByID))
+	mux.HandleFunc("/uploads", a.requireAuth(a.createUpload))
+	mux.HandleFunc("/uploads/", a.requireAuth(a.getUpload))
+	mux.HandleFunc("/workflows", a.requireAuth(a.createWorkflow))
+	mux.HandleFunc("/workflows/", a.requireAuth(a.getWorkflow))
+	mux.HandleFunc("/schedules", a.requireAuth(a.handleSchedules))
+	mux.HandleFunc("/schedules/", a.requireAuth(a.handleScheduleByID))
+	mux.HandleFunc("/quotas", a.requireAuth(a.getQuotas))
+	mux.HandleFunc("/quotas/", a.requireAdmin(a.setQuota))
+	mux.HandleFunc("/fairshare/weights", a.requireAdmin(a.getFairShareWeights))
+	mux.HandleFunc("/fairshare/weights/", a.requireAdmin(a.setFairShareWeight))
+	mux.HandleFunc("/supervisors/status", a.requireAuth(a.getSupervisorStatus))
+	mux.HandleFunc("/supervisors/status/", a.requireAuth(a.getSupervisorStatusByID))
+	mux.HandleFunc("/supervisors", a.requireAuth(a.getAllSupervisors))
 
 	a.log.Info("new app initialized", "redis_address", redisAddr,
 		"gpu_type", gpuType, "http_address", a.httpServer.Addr)
@@ -67,12 +97,30 @@ func (a *App) Start() error {
 		return err
 	}
 
+	// Make sure there is an account to log in with
+	if username := os.Getenv("MIST_ADMIN_USER"); username != "" {
+		if err := a.auth.BootstrapAdmin(username, os.Getenv("MIST_ADMIN_PASSWORD")); err != nil {
+			a.log.Error("failed to bootstrap admin user", "username", username, "err", err)
+			return err
+		}
+	}
+
+	// Start scheduler background loops (job event listener)
+	a.scheduler.Start()
+
 	// Start supervisor
 	if err := a.supervisor.Start(); err != nil {
 		a.log.Error("supervisor start failed", "err", err)
 		return err
 	}
 
+	// Mark supervisors whose heartbeat lapsed as failed
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.statusRegistry.SweepSupervisors(a.ctx, HeartbeatInterval, SupervisorTimeout)
+	}()
+
 	// Launch HTTP server
 	a.wg.Add(1)
 	go func() {
@@ -91,7 +139,8 @@ func (a *App) Shutdown(ctx context.Context) error {
 		a.log.Error("error shutting down HTTP server", "err", err)
 	}
 
-	// Wait for ListenAndServe goroutine to finish
+	// Stop background loops and wait for them and the ListenAndServe goroutine to finish
+	a.cancel()
 	a.wg.Wait()
 
 	a.supervisor.Stop()
@@ -124,7 +173,8 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to create logger: %v\n", err)
 		os.Exit(1)
 	}
-	app := NewApp("localhost:6379", "AMD", log)
+	concurrency := envInt("MIST_SUPERVISOR_CONCURRENCY", DefaultConcurrency)
+	app := NewApp("localhost:6379", "AMD", log, WithConcurrency(concurrency))
 
 	if err := app.Start(); err != nil {
 		log.Error("failed to start app", "err", err)
@@ -145,32 +195,140 @@ func main() {
 	log.Info("all services stopped cleanly")
 }
 
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 func (a *App) login(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	a.log.Info("login handler accessed", "remote_address", r.RemoteAddr)
-	val, err := a.redisClient.Get(ctx, "some:key").Result()
-	if errors.Is(err, redis.Nil) {
-		a.log.Info("redis key not found")
-		http.Error(w, "redis key not found", http.StatusNotFound)
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		http.Error(w, "Username and password are required", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := a.auth.Login(req.Username, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		a.log.Warn("login failed", "username", req.Username, "remote_address", r.RemoteAddr)
+		http.Error(w, "invalid username or password", http.StatusUnauthorized)
 		return
 	}
 	if err != nil {
-		a.log.Error("redis error on login", "err", err)
-		http.Error(w, "redis error", http.StatusInternalServerError)
+		a.log.Error("login error", "username", req.Username, "err", err)
+		http.Error(w, "login failed", http.StatusInternalServerError)
 		return
 	}
-	a.log.Info("login success", "remote_address", r.RemoteAddr)
-	fmt.Fprintf(w, "login page; redis says: %q\n", val)
+
+	a.log.Info("login success", "username", req.Username, "remote_address", r.RemoteAddr)
+	a.writeTokens(w, tokens)
 }
 
 func (a *App) refresh(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Hello, world!\n")
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := a.auth.Refresh(req.RefreshToken)
+	if errors.Is(err, ErrInvalidToken) {
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		a.log.Error("token refresh error", "err", err)
+		http.Error(w, "refresh failed", http.StatusInternalServerError)
+		return
+	}
+
+	a.writeTokens(w, tokens)
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
+	Team     string `json:"team,omitempty"`
+}
+
+func (a *App) createUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleUser
+	}
+
+	err := a.auth.CreateUser(req.Username, req.Password, req.Role, req.Team)
+	switch {
+	case errors.Is(err, ErrUserExists):
+		http.Error(w, fmt.Sprintf("User already exists: %s", req.Username), http.StatusConflict)
+		return
+	case errors.Is(err, ErrInvalidUser):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		a.log.Error("failed to create user", "username", req.Username, "err", err)
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
+		return
+	}
+
+	admin, _ := identityFrom(r.Context())
+	a.log.Info("user created", "username", req.Username, "role", req.Role, "created_by", admin.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(User{Username: req.Username, Role: req.Role, Team: req.Team}); err != nil {
+		a.log.Error("failed to encode response", "err", err)
+	}
+}
+
+func (a *App) writeTokens(w http.ResponseWriter, tokens *TokenPair) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		a.log.Error("failed to encode token response", "err", err)
+	}
 }
 
 type CreateJobRequest struct {
 	Type        string                 `json:"type"`
 	Payload     map[string]interface{} `json:"payload"`
 	RequiredGPU string                 `json:"gpu,omitempty"`
+	Priority    string                 `json:"priority,omitempty"`   // low, normal or high
+	RunAfter    *time.Time             `json:"run_after,omitempty"`  // earliest start, RFC 3339
+	Delay       string                 `json:"delay,omitempty"`      // earliest start from now, e.g. "8h"
+	DependsOn   []string               `json:"depends_on,omitempty"` // IDs of jobs that must succeed first
+
+	// IdempotencyKey dedupes retried submissions, like the Idempotency-Key header
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 type CreateJobResponse struct {
@@ -178,11 +336,14 @@ type CreateJobResponse struct {
 }
 
 func (a *App) handleJobs(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
+	switch r.Method {
+	case http.MethodPost:
 		a.createJob(w, r)
-		return
+	case http.MethodGet:
+		a.listJobs(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
 func (a *App) createJob(w http.ResponseWriter, r *http.Request) {
@@ -196,21 +357,107 @@ func (a *App) createJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Type == "" {
-		http.Error(w, "Job type is required", http.StatusBadRequest)
+	key := r.Header.Get("Idempotency-Key")
+	if req.IdempotencyKey != "" {
+		if key != "" && key != req.IdempotencyKey {
+			http.Error(w, "Idempotency-Key header and idempotency_key differ", http.StatusBadRequest)
+			return
+		}
+		key = req.IdempotencyKey
+	}
+	if key != "" && !validIdempotencyKey(key) {
+		http.Error(w, fmt.Sprintf("%v: use up to %d printable characters", ErrInvalidIdempotencyKey, MaxIdempotencyKeyLen), http.StatusBadRequest)
 		return
 	}
-	jobID, err := a.scheduler.Enqueue(req.Type, req.RequiredGPU, req.Payload)
+	req.IdempotencyKey = ""
+
+	caller, _ := identityFrom(r.Context())
+	job, err := jobFromRequest(req, caller)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !a.checkInputs(w, job) {
+		return
+	}
+	// callers may only wait for jobs they own
+	for _, upstream := range req.DependsOn {
+		if _, ok := a.authorizeJob(w, r, upstream); !ok {
+			return
+		}
+	}
+
+	var fingerprint string
+	if key != "" {
+		if fingerprint, err = requestFingerprint(req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		// a retry gets its job back without counting against the quota again
+		jobID, err := a.scheduler.IdempotentJob(caller.Username, key, fingerprint)
+		if errors.Is(err, ErrIdempotencyKeyReused) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			a.log.Error("failed to look up idempotency key", "username", caller.Username, "err", err)
+			http.Error(w, "enqueue failed", http.StatusInternalServerError)
+			return
+		}
+		if jobID != "" {
+			a.writeCreatedJob(w, jobID, true)
+			return
+		}
+	}
+
+	// the job counts against the quota from here on unless enqueueing fails
+	job.ID = generateJobID()
+	if !a.reserveQuota(w, caller, []Job{job}) {
+		return
+	}
+
+	var jobID string
+	replayed := false
+	if key != "" {
+		jobID, replayed, err = a.scheduler.EnqueueJobOnce(job, key, fingerprint)
+	} else {
+		jobID, err = a.scheduler.EnqueueJob(job)
+	}
+	if err != nil || replayed {
+		a.quotas.Release(caller.Username, caller.Team, job.ID)
+	}
+	switch {
+	case errors.Is(err, ErrDependencyNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrDependencyFailed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
 		a.log.Error("enqueue failed", "err", err, "payload", req.Payload)
 		http.Error(w, "enqueue failed", http.StatusInternalServerError)
 		return
 	}
 
-	a.log.Info("job created", "job_id", jobID, "type", req.Type, "gpu", req.RequiredGPU)
+	if !replayed {
+		a.log.Info("job created", "job_id", jobID, "type", req.Type, "gpu", req.RequiredGPU, "owner", caller.Username)
+	}
+	a.writeCreatedJob(w, jobID, replayed)
+}
 
+// writeCreatedJob answers a job submission. A replayed submission gets the
+// original job with 200 instead of 201.
+func (a *App) writeCreatedJob(w http.ResponseWriter, jobID string, replayed bool) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
 
 	response := CreateJobResponse{JobID: jobID}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -218,6 +465,381 @@ func (a *App) createJob(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jobFromRequest validates req and turns it into a job of caller.
+func jobFromRequest(req CreateJobRequest, caller *Identity) (Job, error) {
+	if req.Type == "" {
+		return Job{}, errors.New("Job type is required")
+	}
+	priority, err := parsePriority(req.Priority)
+	if err != nil {
+		return Job{}, err
+	}
+	runAfter, err := resolveRunAfter(req.RunAfter, req.Delay, time.Now())
+	if err != nil {
+		return Job{}, err
+	}
+	spec, err := parseJobSpec(req.Payload)
+	if err != nil {
+		return Job{}, err
+	}
+	for _, upstream := range req.DependsOn {
+		if !validJobID(upstream) {
+			return Job{}, fmt.Errorf("Invalid job ID in depends_on: %q", upstream)
+		}
+	}
+
+	job := Job{
+		Type:        req.Type,
+		Payload:     req.Payload,
+		RequiredGPU: req.RequiredGPU,
+		Owner:       caller.Username,
+		Team:        caller.Team,
+		Priority:    priority,
+		RunAfter:    runAfter,
+		DependsOn:   req.DependsOn,
+	}
+	// reject jobs a supervisor could not run, e.g. for an unknown GPU type
+	if _, err := containerSpecFor(job, spec, ""); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// checkInputs answers with 400 and returns false if an input of job was not
+// uploaded. An invalid spec is left to the caller to reject.
+func (a *App) checkInputs(w http.ResponseWriter, job Job) bool {
+	spec, _ := parseJobSpec(job.Payload)
+	for _, in := range spec.Inputs {
+		_, err := a.uploads.Stat(in.SHA256)
+		if errors.Is(err, ErrUploadNotFound) {
+			http.Error(w, fmt.Sprintf("Input %s was not uploaded: %s", in.Path, in.SHA256), http.StatusBadRequest)
+			return false
+		}
+		if err != nil {
+			a.log.Error("failed to check job input", "sha256", in.SHA256, "error", err)
+			http.Error(w, "failed to check inputs", http.StatusInternalServerError)
+			return false
+		}
+	}
+	return true
+}
+
+// reserveQuota counts jobs against the quotas of caller, or answers with the
+// quota error and returns false if caller cannot submit them.
+func (a *App) reserveQuota(w http.ResponseWriter, caller *Identity, jobs []Job) bool {
+	var quotaErr *QuotaError
+	if err := a.quotas.Reserve(caller.Username, caller.Team, jobs); errors.As(err, &quotaErr) {
+		a.log.Warn("job rejected by quota", "username", caller.Username, "team", caller.Team, "reason", quotaErr)
+		http.Error(w, quotaErr.Error(), quotaErr.Status)
+		return false
+	} else if err != nil {
+		a.log.Error("failed to check quota", "username", caller.Username, "err", err)
+		http.Error(w, "failed to check quota", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+type ListJobsResponse struct {
+	Jobs       []Job  `json:"jobs"`
+	Count      int    `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parseJobFilter reads the filters of GET /jobs from the query string:
+// state (comma separated), type, gpu, owner, since and until (RFC 3339),
+// limit, cursor and order (asc or desc).
+func parseJobFilter(query url.Values) (JobFilter, error) {
+	filter := JobFilter{
+		Type:        query.Get("type"),
+		RequiredGPU: query.Get("gpu"),
+		Owner:       query.Get("owner"),
+		Cursor:      query.Get("cursor"),
+	}
+
+	if v := query.Get("state"); v != "" {
+		for _, state := range strings.Split(v, ",") {
+			state = strings.TrimSpace(state)
+			if state != "" {
+				filter.States = append(filter.States, JobState(state))
+			}
+		}
+	}
+
+	var err error
+	if v := query.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid since %q: expected RFC 3339 time", v)
+		}
+	}
+	if v := query.Get("until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid until %q: expected RFC 3339 time", v)
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+		filter.Limit = limit
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, fmt.Errorf("invalid order %q: expected asc or desc", query.Get("order"))
+	}
+
+	return filter, nil
+}
+
+func (a *App) listJobs(w http.ResponseWriter, r *http.Request) {
+	a.log.Info("listJobs handler accessed", "remote_address", r.RemoteAddr)
+
+	filter, err := parseJobFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// users only ever see their own jobs
+	caller, _ := identityFrom(r.Context())
+	if !caller.IsAdmin() {
+		if filter.Owner != "" && filter.Owner != caller.Username {
+			http.Error(w, "cannot list jobs of other users", http.StatusForbidden)
+			return
+		}
+		filter.Owner = caller.Username
+	}
+
+	jobs, next, err := a.statusRegistry.ListJobs(filter)
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to list jobs", "error", err)
+		http.Error(w, "failed to list jobs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := ListJobsResponse{Jobs: jobs, Count: len(jobs), NextCursor: next}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		a.log.Error("failed to encode jobs response", "error", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+type CancelJobResponse struct {
+	JobID    string   `json:"job_id"`
+	JobState JobState `json:"job_state"`
+}
+
+// handleJobByID routes requests for a single job:
+// DELETE /jobs/{id} and POST /jobs/{id}/cancel cancel the job,
+// GET /jobs/{id}/logs returns its output, GET /jobs/{id}/artifacts lists
+// its artifacts and GET /jobs/{id}/artifacts/download downloads them.
+func (a *App) handleJobByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	parts := strings.Split(path, "/")
+	if parts[0] == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+	jobID := parts[0]
+
+	// GET /jobs/status/{id} is an alias for /jobs/status?id={id}
+	if jobID == "status" {
+		a.getJobStatus(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete,
+		len(parts) == 2 && parts[1] == "cancel" && r.Method == http.MethodPost:
+		a.cancelJob(w, r, jobID)
+	case len(parts) == 2 && parts[1] == "logs" && r.Method == http.MethodGet:
+		a.getJobLogs(w, r, jobID)
+	case len(parts) == 2 && parts[1] == "artifacts" && r.Method == http.MethodGet:
+		a.listJobArtifacts(w, r, jobID)
+	case len(parts) == 3 && parts[1] == "artifacts" && parts[2] == "download" && r.Method == http.MethodGet:
+		a.downloadJobArtifacts(w, r, jobID)
+	case len(parts) == 1 || (len(parts) == 2 && (parts[1] == "cancel" || parts[1] == "logs" || parts[1] == "artifacts")),
+		len(parts) == 3 && parts[1] == "artifacts" && parts[2] == "download":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *App) cancelJob(w http.ResponseWriter, r *http.Request, jobID string) {
+	a.log.Info("cancelJob handler accessed", "job_id", jobID, "remote_address", r.RemoteAddr)
+
+	if _, ok := a.authorizeJob(w, r, jobID); !ok {
+		return
+	}
+
+	if err := a.scheduler.Cancel(jobID); err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			http.Error(w, fmt.Sprintf("Job not found: %s", jobID), http.StatusNotFound)
+		case errors.Is(err, ErrJobFinished):
+			http.Error(w, fmt.Sprintf("Job already finished: %s", jobID), http.StatusConflict)
+		default:
+			a.log.Error("failed to cancel job", "job_id", jobID, "error", err)
+			http.Error(w, "cancel failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := CancelJobResponse{JobID: jobID, JobState: JobStateCancelled}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		a.log.Error("failed to encode response", "err", err)
+	}
+}
+
+// getJobLogs writes the lines the job printed so far as plain text. With
+// ?follow=true it keeps the response open and writes new lines as they are
+// stored, until the job finishes or the client goes away.
+func (a *App) getJobLogs(w http.ResponseWriter, r *http.Request, jobID string) {
+	follow := false
+	if v := r.URL.Query().Get("follow"); v != "" {
+		var err error
+		if follow, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid follow: %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, ok := a.authorizeJob(w, r, jobID); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	flusher, _ := w.(http.Flusher)
+
+	last, sent := "0", false
+	for {
+		// check the state before reading so no line stored before the job
+		// finished is missed
+		finished := !follow
+		block := time.Duration(-1)
+		if follow {
+			job, err := a.statusRegistry.GetJobStatus(jobID)
+			if err != nil {
+				if r.Context().Err() == nil {
+					a.log.Error("failed to get job status", "job_id", jobID, "error", err)
+				}
+				return
+			}
+			finished = isTerminalState(job.JobState)
+			if !finished {
+				block = logPollInterval
+			}
+		}
+
+		lines, err := readJobLogs(r.Context(), a.redisClient, jobID, last, logBatchSize, block)
+		if err != nil {
+			if r.Context().Err() == nil {
+				a.log.Error("failed to read job logs", "job_id", jobID, "error", err)
+				if !sent {
+					http.Error(w, "failed to read job logs", http.StatusInternalServerError)
+				}
+			}
+			return
+		}
+		for _, line := range lines {
+			fmt.Fprintln(w, line.Line)
+			last = line.ID
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		sent = true
+		if len(lines) == 0 && finished {
+			return
+		}
+	}
+}
+
+type JobArtifactsResponse struct {
+	JobID     string     `json:"job_id"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+func (a *App) listJobArtifacts(w http.ResponseWriter, r *http.Request, jobID string) {
+	if _, ok := a.authorizeJob(w, r, jobID); !ok {
+		return
+	}
+
+	artifacts, err := a.artifacts.List(jobID)
+	if errors.Is(err, ErrNoArtifacts) {
+		http.Error(w, fmt.Sprintf("No artifacts for job: %s", jobID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to list job artifacts", "job_id", jobID, "error", err)
+		http.Error(w, "failed to list artifacts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(JobArtifactsResponse{JobID: jobID, Artifacts: artifacts}); err != nil {
+		a.log.Error("failed to encode response", "err", err)
+	}
+}
+
+// downloadJobArtifacts sends all artifacts of the job as a gzipped tar
+// archive, or with ?path= just the one file.
+func (a *App) downloadJobArtifacts(w http.ResponseWriter, r *http.Request, jobID string) {
+	if _, ok := a.authorizeJob(w, r, jobID); !ok {
+		return
+	}
+
+	if name := r.URL.Query().Get("path"); name != "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+		err := a.artifacts.CopyFile(jobID, name, w)
+		switch {
+		case errors.Is(err, ErrNoArtifacts), errors.Is(err, ErrArtifactNotFound):
+			http.Error(w, fmt.Sprintf("Artifact not found: %s", name), http.StatusNotFound)
+		case err != nil:
+			a.log.Error("failed to send job artifact", "job_id", jobID, "path", name, "error", err)
+		}
+		return
+	}
+
+	f, err := a.artifacts.Open(jobID)
+	if errors.Is(err, ErrNoArtifacts) {
+		http.Error(w, fmt.Sprintf("No artifacts for job: %s", jobID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to open job artifacts", "job_id", jobID, "error", err)
+		http.Error(w, "failed to open artifacts", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		a.log.Error("failed to open job artifacts", "job_id", jobID, "error", err)
+		http.Error(w, "failed to open artifacts", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", jobID+"-artifacts.tar.gz"))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
 func (a *App) getJobStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -240,10 +862,8 @@ func (a *App) getJobStatus(w http.ResponseWriter, r *http.Request) {
 
 	a.log.Info("getJobStatus handler accessed", "job_id", jobID, "remote_address", r.RemoteAddr)
 
-	job, err := a.statusRegistry.GetJobStatus(jobID)
-	if err != nil {
-		a.log.Error("failed to get job status", "job_id", jobID, "error", err)
-		http.Error(w, fmt.Sprintf("Job not found: %s", jobID), http.StatusNotFound)
+	job, ok := a.authorizeJob(w, r, jobID)
+	if !ok {
 		return
 	}
 
@@ -255,6 +875,34 @@ func (a *App) getJobStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorizeJob checks that jobID is well-formed, loads the job and checks
+// the caller may access it, writing the error response if not.
+func (a *App) authorizeJob(w http.ResponseWriter, r *http.Request, jobID string) (*Job, bool) {
+	if !validJobID(jobID) {
+		http.Error(w, fmt.Sprintf("Invalid job ID: %q", jobID), http.StatusBadRequest)
+		return nil, false
+	}
+
+	job, err := a.statusRegistry.GetJobStatus(jobID)
+	if errors.Is(err, ErrJobNotFound) {
+		http.Error(w, fmt.Sprintf("Job not found: %s", jobID), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		a.log.Error("failed to get job status", "job_id", jobID, "error", err)
+		http.Error(w, "failed to get job status", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	caller, _ := identityFrom(r.Context())
+	if !caller.CanAccess(job) {
+		a.log.Warn("job access denied", "job_id", jobID, "username", caller.Username)
+		http.Error(w, fmt.Sprintf("Not allowed to access job: %s", jobID), http.StatusForbidden)
+		return nil, false
+	}
+	return job, true
+}
+
 func (a *App) getSupervisorStatus(w http.ResponseWriter, r *http.Request) {
 	supervisors, err := a.statusRegistry.GetAllSupervisors()
 	if err != nil {
@@ -326,3 +974,484 @@ func (a *App) getAllSupervisors(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type QuotaStatus struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Policy QuotaPolicy `json:"policy"`
+	Usage  *QuotaUsage `json:"usage,omitempty"`
+}
+
+// WorkflowJobRequest is one job of a workflow. Its depends_on holds the
+// names of other jobs in the workflow rather than job IDs.
+type WorkflowJobRequest struct {
+	Name string `json:"name"`
+	CreateJobRequest
+}
+
+type CreateWorkflowRequest struct {
+	Name string               `json:"name,omitempty"`
+	Jobs []WorkflowJobRequest `json:"jobs"`
+}
+
+// createWorkflow handles POST /workflows, submitting every job of the
+// workflow at once.
+func (a *App) createWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateWorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	caller, _ := identityFrom(r.Context())
+	steps := make([]WorkflowStep, len(req.Jobs))
+	for i, jobReq := range req.Jobs {
+		dependsOn := jobReq.DependsOn
+		jobReq.DependsOn = nil
+		job, err := jobFromRequest(jobReq.CreateJobRequest, caller)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("job %q: %v", jobReq.Name, err), http.StatusBadRequest)
+			return
+		}
+		if !a.checkInputs(w, job) {
+			return
+		}
+		job.ID = generateJobID()
+		steps[i] = WorkflowStep{Name: jobReq.Name, Job: job, DependsOn: dependsOn}
+	}
+	if _, err := planWorkflow(steps); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the whole workflow has to fit into the quota
+	jobs := make([]Job, len(steps))
+	jobIDs := make([]string, len(steps))
+	for i, step := range steps {
+		jobs[i] = step.Job
+		jobIDs[i] = step.Job.ID
+	}
+	if !a.reserveQuota(w, caller, jobs) {
+		return
+	}
+
+	wf, err := a.scheduler.SubmitWorkflow(req.Name, caller.Username, steps)
+	if err != nil {
+		a.quotas.Release(caller.Username, caller.Team, jobIDs...)
+		a.log.Error("failed to submit workflow", "username", caller.Username, "error", err)
+		http.Error(w, "failed to submit workflow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(wf); err != nil {
+		a.log.Error("failed to encode workflow response", "error", err)
+	}
+}
+
+// getWorkflow handles GET /workflows/{id}, returning the state of every job
+// of the workflow.
+func (a *App) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/workflows/"), "/")
+	if id == "" {
+		http.Error(w, "Workflow ID is required", http.StatusBadRequest)
+		return
+	}
+
+	wf, err := a.scheduler.GetWorkflow(id)
+	if errors.Is(err, ErrWorkflowNotFound) {
+		http.Error(w, fmt.Sprintf("Workflow not found: %s", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to get workflow", "workflow_id", id, "error", err)
+		http.Error(w, "failed to get workflow", http.StatusInternalServerError)
+		return
+	}
+	caller, _ := identityFrom(r.Context())
+	if !caller.IsAdmin() && wf.Owner != caller.Username {
+		http.Error(w, fmt.Sprintf("Not allowed to access workflow: %s", id), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(wf); err != nil {
+		a.log.Error("failed to encode workflow response", "error", err)
+	}
+}
+
+type UploadResponse struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// createUpload handles POST /uploads, storing the request body as a file jobs
+// can name as an input. With ?sha256= the body must have that digest.
+func (a *App) createUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	want := r.URL.Query().Get("sha256")
+	if want != "" && !digestPattern.MatchString(want) {
+		http.Error(w, fmt.Sprintf("Invalid sha256: %q", want), http.StatusBadRequest)
+		return
+	}
+
+	caller, _ := identityFrom(r.Context())
+	digest, size, err := a.uploads.Put(r.Body, want)
+	switch {
+	case errors.Is(err, ErrUploadTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, ErrDigestMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		a.log.Error("failed to store upload", "username", caller.Username, "error", err)
+		http.Error(w, "upload failed", http.StatusInternalServerError)
+		return
+	}
+	a.log.Info("file uploaded", "sha256", digest, "size", size, "username", caller.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(UploadResponse{SHA256: digest, Size: size}); err != nil {
+		a.log.Error("failed to encode response", "err", err)
+	}
+}
+
+// getUpload handles GET and HEAD /uploads/{sha256}, so clients can skip
+// uploading a file the server already has.
+func (a *App) getUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	digest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/uploads/"), "/")
+	size, err := a.uploads.Stat(digest)
+	if errors.Is(err, ErrUploadNotFound) {
+		http.Error(w, fmt.Sprintf("Upload not found: %s", digest), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to check upload", "sha256", digest, "error", err)
+		http.Error(w, "failed to check upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(UploadResponse{SHA256: digest, Size: size}); err != nil {
+		a.log.Error("failed to encode response", "err", err)
+	}
+}
+
+type CreateScheduleRequest struct {
+	Name       string           `json:"name,omitempty"`
+	Cron       string           `json:"cron"`
+	Timezone   string           `json:"timezone,omitempty"`
+	Job        CreateJobRequest `json:"job"`
+	MissedRuns string           `json:"missed_runs,omitempty"` // skip (default) or run_once
+}
+
+type ListSchedulesResponse struct {
+	Schedules []Schedule `json:"schedules"`
+	Count     int        `json:"count"`
+}
+
+func (a *App) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.createSchedule(w, r)
+	case http.MethodGet:
+		a.listSchedules(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *App) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !a.checkInputs(w, Job{Payload: req.Job.Payload}) {
+		return
+	}
+
+	caller, _ := identityFrom(r.Context())
+	sched, err := a.scheduler.CreateSchedule(Schedule{
+		Name:       req.Name,
+		Cron:       req.Cron,
+		Timezone:   req.Timezone,
+		Job:        req.Job,
+		MissedRuns: MissedRunPolicy(req.MissedRuns),
+		Owner:      caller.Username,
+		Team:       caller.Team,
+	})
+	if errors.Is(err, ErrInvalidSchedule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to create schedule", "username", caller.Username, "error", err)
+		http.Error(w, "failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(sched); err != nil {
+		a.log.Error("failed to encode schedule response", "error", err)
+	}
+}
+
+// listSchedules returns the caller's schedules. Admins see every schedule
+// and can narrow it down with ?owner=.
+func (a *App) listSchedules(w http.ResponseWriter, r *http.Request) {
+	caller, _ := identityFrom(r.Context())
+	owner := r.URL.Query().Get("owner")
+	if !caller.IsAdmin() {
+		if owner != "" && owner != caller.Username {
+			http.Error(w, "Not allowed to list schedules of other users", http.StatusForbidden)
+			return
+		}
+		owner = caller.Username
+	}
+
+	schedules, err := a.scheduler.ListSchedules(owner)
+	if err != nil {
+		a.log.Error("failed to list schedules", "error", err)
+		http.Error(w, "failed to list schedules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ListSchedulesResponse{Schedules: schedules, Count: len(schedules)}); err != nil {
+		a.log.Error("failed to encode schedules response", "error", err)
+	}
+}
+
+// handleScheduleByID routes requests for a single schedule:
+// GET and DELETE /schedules/{id}, POST /schedules/{id}/pause and
+// POST /schedules/{id}/resume.
+func (a *App) handleScheduleByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/schedules/"), "/"), "/")
+	if parts[0] == "" {
+		http.Error(w, "Schedule ID is required", http.StatusBadRequest)
+		return
+	}
+	id := parts[0]
+
+	var action string
+	switch {
+	case len(parts) == 1 && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+		action = r.Method
+	case len(parts) == 2 && (parts[1] == "pause" || parts[1] == "resume") && r.Method == http.MethodPost:
+		action = parts[1]
+	case len(parts) == 1 || (len(parts) == 2 && (parts[1] == "pause" || parts[1] == "resume")):
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	sched, err := a.scheduler.GetSchedule(id)
+	if errors.Is(err, ErrScheduleNotFound) {
+		http.Error(w, fmt.Sprintf("Schedule not found: %s", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to get schedule", "schedule_id", id, "error", err)
+		http.Error(w, "failed to get schedule", http.StatusInternalServerError)
+		return
+	}
+	caller, _ := identityFrom(r.Context())
+	if !caller.IsAdmin() && sched.Owner != caller.Username {
+		http.Error(w, fmt.Sprintf("Not allowed to access schedule: %s", id), http.StatusForbidden)
+		return
+	}
+
+	switch action {
+	case http.MethodDelete:
+		err = a.scheduler.DeleteSchedule(id)
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	case "pause", "resume":
+		sched, err = a.scheduler.SetSchedulePaused(id, action == "pause")
+	}
+	if errors.Is(err, ErrScheduleNotFound) {
+		http.Error(w, fmt.Sprintf("Schedule not found: %s", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to update schedule", "schedule_id", id, "action", action, "error", err)
+		http.Error(w, "failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sched); err != nil {
+		a.log.Error("failed to encode schedule response", "error", err)
+	}
+}
+
+// getQuotas returns the quota policy and usage of the caller and their team.
+// Admins can ask for any user or team with ?user= or ?team=.
+func (a *App) getQuotas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, _ := identityFrom(r.Context())
+	user, team := caller.Username, caller.Team
+	query := r.URL.Query()
+	if query.Has("user") || query.Has("team") {
+		if !caller.IsAdmin() {
+			http.Error(w, "admin role required to view other quotas", http.StatusForbidden)
+			return
+		}
+		user, team = query.Get("user"), query.Get("team")
+	}
+
+	var quotas []QuotaStatus
+	for _, subject := range quotaSubjects(user, team) {
+		kind, name := subject[0], subject[1]
+		policy, err := a.quotas.GetPolicy(kind, name)
+		if err != nil {
+			a.log.Error("failed to get quota policy", "kind", kind, "name", name, "error", err)
+			http.Error(w, "failed to get quotas", http.StatusInternalServerError)
+			return
+		}
+		usage, err := a.quotas.Usage(kind, name)
+		if err != nil {
+			a.log.Error("failed to get quota usage", "kind", kind, "name", name, "error", err)
+			http.Error(w, "failed to get quotas", http.StatusInternalServerError)
+			return
+		}
+		quotas = append(quotas, QuotaStatus{Kind: kind, Name: name, Policy: policy, Usage: &usage})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"quotas": quotas,
+		"count":  len(quotas),
+	}); err != nil {
+		a.log.Error("failed to encode quotas response", "error", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// setQuota handles PUT /quotas/{user|team}/{name}. Without a name it sets
+// the default policy of the kind.
+func (a *App) setQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind, name, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/quotas/"), "/"), "/")
+
+	var policy QuotaPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := a.quotas.SetPolicy(kind, name, policy)
+	if errors.Is(err, ErrInvalidQuota) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to set quota", "kind", kind, "name", name, "error", err)
+		http.Error(w, "failed to set quota", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(QuotaStatus{Kind: kind, Name: name, Policy: policy}); err != nil {
+		a.log.Error("failed to encode quota response", "error", err)
+	}
+}
+
+type FairShareWeightRequest struct {
+	Weight float64 `json:"weight"`
+}
+
+func (a *App) getFairShareWeights(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	weights, err := a.scheduler.FairShareWeights()
+	if err != nil {
+		a.log.Error("failed to get fair-share weights", "error", err)
+		http.Error(w, "failed to get weights", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"weights":        weights,
+		"default_weight": DefaultFairShareWeight,
+	}); err != nil {
+		a.log.Error("failed to encode weights response", "error", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// setFairShareWeight handles PUT /fairshare/weights/{user}.
+func (a *App) setFairShareWeight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner := strings.Trim(strings.TrimPrefix(r.URL.Path, "/fairshare/weights/"), "/")
+	if owner == "" {
+		http.Error(w, "username required", http.StatusBadRequest)
+		return
+	}
+
+	var req FairShareWeightRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := a.scheduler.SetFairShareWeight(owner, req.Weight)
+	if errors.Is(err, ErrInvalidWeight) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		a.log.Error("failed to set fair-share weight", "username", owner, "error", err)
+		http.Error(w, "failed to set weight", http.StatusInternalServerError)
+		return
+	}
+
+	a.log.Info("fair-share weight updated", "username", owner, "weight", req.Weight)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A job whose spec names an output_dir keeps what its container left there:
+// once the container exits the supervisor copies the directory out of it and
+// stores its files as <artifact dir>/<job id>.tar.gz, so they outlive the job
+// volume. The archive holds regular files only, named relative to output_dir.
+
+const (
+	DefaultArtifactDir      = "artifacts"
+	DefaultMaxArtifactBytes = 1 << 30
+)
+
+var (
+	ErrNoArtifacts       = errors.New("job has no artifacts")
+	ErrArtifactNotFound  = errors.New("artifact not found")
+	ErrArtifactsTooLarge = errors.New("artifacts too large")
+)
+
+// Artifact is a file a job left in its output directory.
+type Artifact struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// ArtifactStore keeps job artifacts in a local directory.
+type ArtifactStore struct {
+	dir      string
+	maxBytes int64
+}
+
+func NewArtifactStore(dir string, maxBytes int64) *ArtifactStore {
+	return &ArtifactStore{dir: dir, maxBytes: maxBytes}
+}
+
+// artifactStoreFromEnv returns the store in MIST_ARTIFACT_DIR, keeping up to
+// MIST_ARTIFACT_MAX_MB of artifacts per job.
+func artifactStoreFromEnv() *ArtifactStore {
+	dir := os.Getenv("MIST_ARTIFACT_DIR")
+	if dir == "" {
+		dir = DefaultArtifactDir
+	}
+	return NewArtifactStore(dir, int64(envInt("MIST_ARTIFACT_MAX_MB", DefaultMaxArtifactBytes>>20))<<20)
+}
+
+func (st *ArtifactStore) archivePath(jobID string) string {
+	return filepath.Join(st.dir, jobID+".tar.gz")
+}
+
+// artifactName returns the name an entry of a container archive is stored
+// under, or "" if it would point outside of the output directory. Container
+// archives name entries after the copied directory, e.g. outputs/model.pt.
+func artifactName(entry string) string {
+	name := strings.TrimPrefix(entry, "/")
+	if _, rest, ok := strings.Cut(name, "/"); ok {
+		name = rest
+	}
+	name = path.Clean(name)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+	return name
+}
+
+// Save stores the regular files of the tar archive src as the artifacts of
+// the job, replacing earlier ones, and returns them. Nothing is stored if
+// there are no files.
+func (st *ArtifactStore) Save(jobID string, src io.Reader) ([]Artifact, error) {
+	if err := os.MkdirAll(st.dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(st.dir, jobID+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	tr := tar.NewReader(src)
+
+	var artifacts []Artifact
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		name := artifactName(hdr.Name)
+		if name == "" || !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if total += hdr.Size; total > st.maxBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrArtifactsTooLarge, st.maxBytes)
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     hdr.Mode & 0o777,
+			Size:     hdr.Size,
+			ModTime:  hdr.ModTime,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, fmt.Errorf("copy %s: %w", name, err)
+		}
+		artifacts = append(artifacts, Artifact{Path: name, Size: hdr.Size})
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if len(artifacts) == 0 {
+		return nil, nil
+	}
+	if err := os.Rename(tmp.Name(), st.archivePath(jobID)); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// Open returns the gzipped tar archive of the job's artifacts.
+func (st *ArtifactStore) Open(jobID string) (*os.File, error) {
+	f, err := os.Open(st.archivePath(jobID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoArtifacts
+	}
+	return f, err
+}
+
+// Remove deletes the artifacts of the job, if it has any.
+func (st *ArtifactStore) Remove(jobID string) error {
+	if err := os.Remove(st.archivePath(jobID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// walk calls fn with each file in the job's archive until fn returns false.
+func (st *ArtifactStore) walk(jobID string, fn func(hdr *tar.Header, r io.Reader) bool) error {
+	f, err := st.Open(jobID)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(hdr, tr) {
+			return nil
+		}
+	}
+}
+
+// List returns the artifacts of the job.
+func (st *ArtifactStore) List(jobID string) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := st.walk(jobID, func(hdr *tar.Header, _ io.Reader) bool {
+		artifacts = append(artifacts, Artifact{Path: hdr.Name, Size: hdr.Size})
+		return true
+	})
+	return artifacts, err
+}
+
+// CopyFile writes the artifact of the job at name to w.
+func (st *ArtifactStore) CopyFile(jobID, name string, w io.Writer) error {
+	found := false
+	var copyErr error
+	err := st.walk(jobID, func(hdr *tar.Header, r io.Reader) bool {
+		if hdr.Name != name {
+			return true
+		}
+		found = true
+		_, copyErr = io.Copy(w, r)
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrArtifactNotFound
+	}
+	return copyErr
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// containerArchive builds a tar archive like the one Docker returns for a
+// copied directory: entries are named after the directory.
+func containerArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "outputs/", Mode: 0o755})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "outputs/passwd", Linkname: "/etc/passwd"})
+	for _, name := range []string{"outputs/model.pt", "outputs/logs/train.log", "outputs/../../escape"} {
+		body, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	return &buf
+}
+
+func TestArtifactName(t *testing.T) {
+	for entry, want := range map[string]string{
+		"outputs/model.pt":     "model.pt",
+		"outputs/a/b.txt":      "a/b.txt",
+		"model.pt":             "model.pt",
+		"/outputs/model.pt":    "model.pt",
+		"outputs/a/../b.txt":   "b.txt",
+		"outputs/../../passwd": "",
+		"outputs/":             "",
+		"outputs/..":           "",
+	} {
+		if got := artifactName(entry); got != want {
+			t.Errorf("artifactName(%q) = %q, want %q", entry, got, want)
+		}
+	}
+}
+
+func TestArtifactStoreSaveAndRead(t *testing.T) {
+	store := NewArtifactStore(t.TempDir(), DefaultMaxArtifactBytes)
+
+	saved, err := store.Save("job_1", containerArchive(t, map[string]string{
+		"outputs/model.pt":       "weights",
+		"outputs/logs/train.log": "loss 0.1\n",
+		"outputs/../../escape":   "nope",
+	}))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	want := []Artifact{{Path: "model.pt", Size: 7}, {Path: "logs/train.log", Size: 9}}
+	if !reflect.DeepEqual(saved, want) {
+		t.Errorf("Save: got %+v, want %+v", saved, want)
+	}
+
+	listed, err := store.List("job_1")
+	if err != nil || !reflect.DeepEqual(listed, want) {
+		t.Errorf("List: got %+v, %v", listed, err)
+	}
+
+	var out strings.Builder
+	if err := store.CopyFile("job_1", "logs/train.log", &out); err != nil || out.String() != "loss 0.1\n" {
+		t.Errorf("CopyFile: got %q, %v", out.String(), err)
+	}
+	if err := store.CopyFile("job_1", "missing.txt", &out); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected ErrArtifactNotFound, got %v", err)
+	}
+	if _, err := store.List("job_2"); !errors.Is(err, ErrNoArtifacts) {
+		t.Errorf("Expected ErrNoArtifacts, got %v", err)
+	}
+}
+
+func TestArtifactStoreSkipsEmptyAndLimitsSize(t *testing.T) {
+	store := NewArtifactStore(t.TempDir(), 4)
+
+	saved, err := store.Save("job_1", containerArchive(t, nil))
+	if err != nil || saved != nil {
+		t.Errorf("Expected nothing saved, got %+v, %v", saved, err)
+	}
+	if _, err := store.Open("job_1"); !errors.Is(err, ErrNoArtifacts) {
+		t.Errorf("Expected ErrNoArtifacts, got %v", err)
+	}
+
+	if _, err := store.Save("job_2", containerArchive(t, map[string]string{"outputs/model.pt": "weights"})); !errors.Is(err, ErrArtifactsTooLarge) {
+		t.Errorf("Expected ErrArtifactsTooLarge, got %v", err)
+	}
+	if _, err := store.Open("job_2"); !errors.Is(err, ErrNoArtifacts) {
+		t.Errorf("Expected no archive to be left behind, got %v", err)
+	}
+}
+
+func TestArtifactStoreRemove(t *testing.T) {
+	store := NewArtifactStore(t.TempDir(), 1<<20)
+
+	if _, err := store.Save("job_1", containerArchive(t, map[string]string{"outputs/model.pt": "weights"})); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Remove("job_1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := store.Open("job_1"); !errors.Is(err, ErrNoArtifacts) {
+		t.Errorf("Expected ErrNoArtifacts after Remove, got %v", err)
+	}
+	if err := store.Remove("job_1"); err != nil {
+		t.Errorf("Expected removing missing artifacts to succeed, got %v", err)
+	}
+}
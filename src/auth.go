@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Users are stored in a hash per user holding a salted PBKDF2 hash of their
+// password. Logging in issues a signed, short-lived access token (an HS256
+// JWT) and an opaque refresh token kept in Redis. Refreshing consumes the
+// refresh token and issues a new pair, so every refresh token works once.
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+
+	passwordHashIterations = 100_000
+	passwordSaltSize       = 16
+	passwordHashSize       = 32
+	refreshTokenSize       = 32
+	minPasswordLength      = 8
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidUser        = errors.New("invalid user")
+)
+
+type User struct {
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Team     string    `json:"team,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+// Claims are carried in an access token.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	Team      string `json:"team,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenPair is returned by login and refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // seconds until the access token expires
+}
+
+type Authenticator struct {
+	client *redis.Client
+	secret []byte
+	log    *slog.Logger
+}
+
+func NewAuthenticator(client *redis.Client, secret []byte, log *slog.Logger) *Authenticator {
+	return &Authenticator{
+		client: client,
+		secret: secret,
+		log:    log,
+	}
+}
+
+func userKey(username string) string {
+	return fmt.Sprintf("user:%s", username)
+}
+
+func refreshTokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("auth:refresh:%s", hex.EncodeToString(sum[:]))
+}
+
+// CreateUser adds a user, optionally as a member of team. Returns
+// ErrUserExists if the username is taken.
+func (a *Authenticator) CreateUser(username, password, role, team string) error {
+	if username == "" || strings.ContainsAny(username, ": ") {
+		return fmt.Errorf("%w: username %q", ErrInvalidUser, username)
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLength)
+	}
+	if role != RoleAdmin && role != RoleUser {
+		return fmt.Errorf("%w: role %q", ErrInvalidUser, role)
+	}
+	if strings.ContainsAny(team, ": ") {
+		return fmt.Errorf("%w: team %q", ErrInvalidUser, team)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	key := userKey(username)
+	// claim the username first so concurrent creations cannot overwrite each other
+	created, err := a.client.HSetNX(ctx, key, "password_hash", hash).Result()
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	if !created {
+		return ErrUserExists
+	}
+	if err := a.client.HSet(ctx, key,
+		"role", role,
+		"team", team,
+		"created", time.Now().Format(time.RFC3339Nano),
+	).Err(); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	a.log.Info("user created", "username", username, "role", role, "team", team)
+	return nil
+}
+
+// GetUser returns the user with the given name. Returns an error wrapping
+// ErrInvalidUser if there is no such user.
+func (a *Authenticator) GetUser(username string) (*User, error) {
+	fields, err := a.client.HGetAll(context.Background(), userKey(username)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: %s not found", ErrInvalidUser, username)
+	}
+
+	return userFromFields(username, fields), nil
+}
+
+func userFromFields(username string, fields map[string]string) *User {
+	user := &User{Username: username, Role: fields["role"], Team: fields["team"]}
+	if v := fields["created"]; v != "" {
+		user.Created, _ = time.Parse(time.RFC3339Nano, v)
+	}
+	return user
+}
+
+// BootstrapAdmin creates the admin account if it does not exist yet, so a
+// fresh deployment has someone who can log in.
+func (a *Authenticator) BootstrapAdmin(username, password string) error {
+	err := a.CreateUser(username, password, RoleAdmin, "")
+	if errors.Is(err, ErrUserExists) {
+		return nil
+	}
+	return err
+}
+
+// Login checks the credentials of username and issues a token pair.
+func (a *Authenticator) Login(username, password string) (*TokenPair, error) {
+	fields, err := a.client.HGetAll(context.Background(), userKey(username)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if len(fields) == 0 || !verifyPassword(password, fields["password_hash"]) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.issueTokens(userFromFields(username, fields))
+}
+
+// Refresh consumes refreshToken and issues a new token pair for its user.
+func (a *Authenticator) Refresh(refreshToken string) (*TokenPair, error) {
+	ctx := context.Background()
+	username, err := a.client.GetDel(ctx, refreshTokenKey(refreshToken)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh token: %w", err)
+	}
+
+	user, err := a.GetUser(username)
+	if errors.Is(err, ErrInvalidUser) {
+		return nil, ErrInvalidToken // user was removed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.issueTokens(user)
+}
+
+func (a *Authenticator) issueTokens(user *User) (*TokenPair, error) {
+	now := time.Now()
+	accessToken, err := a.signToken(Claims{
+		Subject:   user.Username,
+		Role:      user.Role,
+		Team:      user.Team,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(AccessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, refreshTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+	if err := a.client.Set(context.Background(), refreshTokenKey(refreshToken), user.Username, RefreshTokenTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTokenTTL / time.Second),
+	}, nil
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (a *Authenticator) signToken(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + a.signature(unsigned), nil
+}
+
+func (a *Authenticator) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAccessToken checks the signature and expiry of token and returns its
+// claims. Returns ErrInvalidToken if the token cannot be trusted.
+func (a *Authenticator) VerifyAccessToken(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrInvalidToken
+	}
+	expected := a.signature(parts[0] + "." + parts[1])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(parts[2])) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Subject == "" || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// hashPassword returns the encoded PBKDF2-SHA256 hash of password:
+// pbkdf2-sha256$<iterations>$<salt>$<hash>.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordHashIterations, passwordHashSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s",
+		passwordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func verifyPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// authSecretFromEnv returns the key access tokens are signed with, read from
+// MIST_AUTH_SECRET. Without it a random key is generated, so tokens do not
+// survive a restart.
+func authSecretFromEnv(log *slog.Logger) []byte {
+	if secret := os.Getenv("MIST_AUTH_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Warn("MIST_AUTH_SECRET not set, using a random key; tokens will be invalidated on restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("failed to generate auth secret: %v", err))
+	}
+	return secret
+}
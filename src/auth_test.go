@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$") {
+		t.Errorf("Unexpected hash format %q", hash)
+	}
+	if !verifyPassword("correct horse", hash) {
+		t.Error("Expected the password to verify")
+	}
+	if verifyPassword("wrong horse", hash) {
+		t.Error("Expected a wrong password to be rejected")
+	}
+	if verifyPassword("correct horse", "plaintext") {
+		t.Error("Expected a malformed hash to be rejected")
+	}
+
+	other, _ := hashPassword("correct horse")
+	if other == hash {
+		t.Error("Expected hashes of the same password to be salted differently")
+	}
+}
+
+func TestAccessTokens(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	auth := NewAuthenticator(nil, []byte("secret"), log)
+	now := time.Now()
+
+	token, err := auth.signToken(Claims{Subject: "alice", Role: RoleUser, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("signToken failed: %v", err)
+	}
+	claims, err := auth.VerifyAccessToken(token)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken failed: %v", err)
+	}
+	if claims.Subject != "alice" || claims.Role != RoleUser {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	other := NewAuthenticator(nil, []byte("other secret"), log)
+	if _, err := other.VerifyAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token signed with another key to be rejected, got %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged, _ := auth.signToken(Claims{Subject: "alice", Role: RoleAdmin, ExpiresAt: now.Add(time.Minute).Unix()})
+	tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+	if _, err := auth.VerifyAccessToken(tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a tampered token to be rejected, got %v", err)
+	}
+
+	expired, _ := auth.signToken(Claims{Subject: "alice", Role: RoleUser, ExpiresAt: now.Add(-time.Second).Unix()})
+	if _, err := auth.VerifyAccessToken(expired); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+}
@@ -58,6 +58,7 @@ func TestContainerJobCPU(t *testing.T) {
 	}
 
 	scheduler := NewScheduler(redisAddr, schedulerLog)
+	scheduler.Start()
 	defer scheduler.Close()
 
 	consumerID := fmt.Sprintf("worker_cpu_test_%d", os.Getpid())
@@ -69,8 +70,9 @@ func TestContainerJobCPU(t *testing.T) {
 
 	// Enqueue a CPU job
 	payload := map[string]interface{}{
-		"task": "test_task",
-		"data": "test_data",
+		"task":    "test_task",
+		"data":    "test_data",
+		"command": []string{"python", "-c", "print('hello from mist')"},
 	}
 	jobID, err := scheduler.Enqueue("test_job", "CPU", payload)
 	if err != nil {
@@ -78,7 +80,7 @@ func TestContainerJobCPU(t *testing.T) {
 	}
 	slog.Info("enqueued CPU job", "job_id", jobID)
 
-	// Wait for the job to be processed (supervisor runs container for ~2 sec then cleans up)
+	// Wait for the job to be processed (supervisor waits for the container to exit, then cleans up)
 	deadline := time.After(15 * time.Second)
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
@@ -136,7 +138,12 @@ func TestRunContainerCPUIntegration(t *testing.T) {
 	}
 	defer mgr.RemoveVolume(volName, true)
 
-	containerID, err := mgr.RunContainer("pytorch-cpu", "runc", volName)
+	containerID, err := mgr.RunContainer(docker.ContainerSpec{
+		Image:   "pytorch-cpu",
+		Runtime: "runc",
+		Volume:  volName,
+		Cmd:     []string{"sleep", "1000"},
+	})
 	if err != nil {
 		t.Fatalf("run container: %v", err)
 	}
@@ -155,4 +162,4 @@ func TestRunContainerCPUIntegration(t *testing.T) {
 	}
 
 	t.Logf("CPU container started successfully: %s", containerID[:12])
-}
\ No newline at end of file
+}
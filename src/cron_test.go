@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsBadExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@fortnightly",
+	} {
+		if _, err := ParseCron(expr); !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("ParseCron(%q): expected ErrInvalidCron, got %v", expr, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Saturday
+	from := time.Date(2025, 3, 1, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 3, 1, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2025, 3, 1, 10, 25, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2025, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2025, 3, 3, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 15 * 1", time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := cron.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: expected next run %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestCronNextNeverMatches(t *testing.T) {
+	cron, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+	if got := cron.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Expected no next run for 30 February, got %v", got)
+	}
+}
+
+func TestCronNextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	cron, _ := ParseCron("0 2 * * *")
+	got := cron.Next(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC).In(loc))
+	if want := time.Date(2025, 3, 2, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected 2am in Toronto (%v), got %v", want, got.UTC())
+	}
+}
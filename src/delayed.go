@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Jobs submitted with run_after or delay are stored like any other job and
+// show as Scheduled, but instead of their owner's pending queue they wait in
+// the jobs:delayed sorted set, scored by the time they become runnable. The
+// dispatcher moves due jobs into the pending queues before releasing jobs.
+
+const (
+	DelayedJobsKey = "jobs:delayed"
+	MaxJobDelay    = 30 * 24 * time.Hour
+)
+
+var ErrInvalidRunAfter = errors.New("invalid run_after")
+
+// resolveRunAfter returns the time a job submitted at now may start, given an
+// absolute runAfter or a delay such as "8h". At most one of them may be set;
+// nil means right away.
+func resolveRunAfter(runAfter *time.Time, delay string, now time.Time) (*time.Time, error) {
+	if runAfter != nil && delay != "" {
+		return nil, fmt.Errorf("%w: set either run_after or delay, not both", ErrInvalidRunAfter)
+	}
+
+	var at time.Time
+	switch {
+	case runAfter != nil:
+		at = *runAfter
+	case delay != "":
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%w: delay %q is not a positive duration", ErrInvalidRunAfter, delay)
+		}
+		at = now.Add(d)
+	default:
+		return nil, nil
+	}
+
+	if at.Sub(now) > MaxJobDelay {
+		return nil, fmt.Errorf("%w: jobs can be delayed by at most %s", ErrInvalidRunAfter, MaxJobDelay)
+	}
+	if !at.After(now) {
+		return nil, nil
+	}
+	return &at, nil
+}
+
+// promoteDelayed moves the delayed jobs that are due into the pending queues.
+func (s *Scheduler) promoteDelayed() {
+	due, err := s.client.ZRangeByScore(s.ctx, DelayedJobsKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.log.Error("failed to read delayed jobs", "error", err)
+		}
+		return
+	}
+
+	for _, jobID := range due {
+		if err := s.promote(jobID); err != nil && s.ctx.Err() == nil {
+			s.log.Error("failed to promote delayed job", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+// promote moves a delayed job to its owner's pending queue, or drops it if it
+// was cancelled meanwhile. Both happen in one transaction with removing it
+// from the delayed jobs, so a job is neither lost nor queued twice when
+// schedulers race; the loser leaves it to the winner.
+func (s *Scheduler) promote(jobID string) error {
+	metadataKey := jobKey(jobID)
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		if err := tx.ZScore(s.ctx, DelayedJobsKey, jobID).Err(); err != nil {
+			if errors.Is(err, redis.Nil) {
+				// promoted by another scheduler
+				return nil
+			}
+			return err
+		}
+		fields, err := tx.HGetAll(s.ctx, metadataKey).Result()
+		if err != nil {
+			return err
+		}
+
+		var job *Job
+		if len(fields) == 0 || JobState(fields["job_state"]) != JobStateScheduled {
+			s.log.Info("dropping delayed job that is no longer scheduled", "job_id", jobID, "state", fields["job_state"])
+		} else if job, err = jobFromFields(jobID, fields); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(s.ctx, DelayedJobsKey, jobID)
+			if job != nil {
+				queueJob(s.ctx, pipe, *job, fields["stream"])
+			}
+			return nil
+		})
+		if err == nil && job != nil {
+			s.log.Info("promoted delayed job", "job_id", jobID, "run_after", fields["run_after"])
+		}
+		return err
+	}, DelayedJobsKey, metadataKey)
+	if errors.Is(err, redis.TxFailedErr) {
+		// changed meanwhile; the next pass looks at it again
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolveRunAfter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	tonight := now.Add(6 * time.Hour)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		runAfter *time.Time
+		delay    string
+		want     *time.Time
+		err      bool
+	}{
+		{name: "neither", want: nil},
+		{name: "run after", runAfter: &tonight, want: &tonight},
+		{name: "delay", delay: "6h", want: &tonight},
+		{name: "past run after runs now", runAfter: &past, want: nil},
+		{name: "zero delay runs now", delay: "0s", want: nil},
+		{name: "both", runAfter: &tonight, delay: "6h", err: true},
+		{name: "bad delay", delay: "tonight", err: true},
+		{name: "negative delay", delay: "-1h", err: true},
+		{name: "too far", delay: "800h", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveRunAfter(tt.runAfter, tt.delay, now)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidRunAfter) {
+				t.Errorf("%s: expected ErrInvalidRunAfter, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
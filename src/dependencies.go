@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A job submitted with depends_on is held in the Waiting state until every
+// job it depends on has succeeded. The upstream jobs still running are kept
+// in job:<id>:waiting_on, and every upstream job lists the jobs waiting for
+// it in job:<id>:dependents. When the event listener sees an upstream job
+// finish it either ticks it off its dependents, releasing those with nothing
+// left to wait for, or, if it did not succeed, cancels them. Their own
+// cancellation events carry the cancellation further down the graph.
+// Waiting jobs are also indexed in jobs:waiting, which the scheduler sweeps
+// every DependencySweepInterval for jobs whose upstream jobs finished without
+// their event being handled, e.g. while no scheduler was running.
+
+const (
+	WaitingJobsKey          = "jobs:waiting"
+	DependencySweepInterval = 30 * time.Second
+
+	maxDependencyAttempts = 5
+)
+
+var (
+	ErrDependencyNotFound = errors.New("dependency not found")
+	ErrDependencyFailed   = errors.New("dependency did not succeed")
+)
+
+func dependentsKey(jobID string) string {
+	return fmt.Sprintf("job:%s:dependents", jobID)
+}
+
+func waitingOnKey(jobID string) string {
+	return fmt.Sprintf("job:%s:waiting_on", jobID)
+}
+
+// enqueueDependent stores job, which has dependencies, and registers it with
+// the upstream jobs that have not succeeded yet. The upstream job records are
+// watched so none of them can finish unnoticed in the meantime.
+func (s *Scheduler) enqueueDependent(job *Job, fields map[string]interface{}) error {
+	keys := make([]string, len(job.DependsOn))
+	for i, upstream := range job.DependsOn {
+		keys[i] = jobKey(upstream)
+	}
+
+	for attempt := 0; attempt < maxDependencyAttempts; attempt++ {
+		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+			var pending []string
+			for _, upstream := range job.DependsOn {
+				state, err := tx.HGet(s.ctx, jobKey(upstream), "job_state").Result()
+				if errors.Is(err, redis.Nil) {
+					return fmt.Errorf("%w: %s", ErrDependencyNotFound, upstream)
+				}
+				if err != nil {
+					return err
+				}
+				switch JobState(state) {
+				case JobStateSuccess:
+				case JobStateFailure, JobStateCancelled:
+					return fmt.Errorf("%w: %s is %s", ErrDependencyFailed, upstream, state)
+				default:
+					pending = append(pending, upstream)
+				}
+			}
+
+			job.JobState = JobStateScheduled
+			if len(pending) > 0 {
+				job.JobState = JobStateWaiting
+			}
+			fields["job_state"] = string(job.JobState)
+
+			_, err := tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+				s.addJob(pipe, *job, fields)
+				for _, upstream := range pending {
+					pipe.SAdd(s.ctx, dependentsKey(upstream), job.ID)
+					pipe.SAdd(s.ctx, waitingOnKey(job.ID), upstream)
+				}
+				return nil
+			})
+			return err
+		}, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to enqueue job %s: its dependencies kept changing", job.ID)
+}
+
+// resolveDependents updates the jobs waiting for jobID after it finished in
+// state. Once they are all updated the list of dependents is dropped.
+func (s *Scheduler) resolveDependents(jobID string, state JobState) {
+	dependents, err := s.client.SMembers(s.ctx, dependentsKey(jobID)).Result()
+	if err != nil {
+		s.log.Error("failed to read dependent jobs", "job_id", jobID, "error", err)
+		return
+	}
+
+	resolved := true
+	for _, dependent := range dependents {
+		if err := s.resolveDependent(dependent, jobID, state); err != nil {
+			s.log.Error("failed to resolve dependent job", "job_id", dependent, "dependency", jobID, "error", err)
+			resolved = false
+		}
+	}
+	if resolved {
+		// replayed events find nothing left to do
+		if err := s.client.Del(s.ctx, dependentsKey(jobID)).Err(); err != nil {
+			s.log.Warn("failed to remove dependent jobs", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+// resolveDependent updates dependent after its upstream job jobID finished in
+// state: it is cancelled if jobID did not succeed, and released once it has
+// nothing left to wait for.
+func (s *Scheduler) resolveDependent(dependent, jobID string, state JobState) error {
+	if state != JobStateSuccess {
+		err := s.cancelJob(dependent, fmt.Sprintf("dependency %s ended in %s", jobID, state))
+		if errors.Is(err, ErrJobFinished) || errors.Is(err, ErrJobNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// removing an upstream job that is already gone does nothing, so
+	// replayed events are harmless
+	pipe := s.client.TxPipeline()
+	pipe.SRem(s.ctx, waitingOnKey(dependent), jobID)
+	left := pipe.SCard(s.ctx, waitingOnKey(dependent))
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return err
+	}
+	if left.Val() == 0 {
+		return s.releaseWaiting(dependent)
+	}
+	return nil
+}
+
+// sweepWaiting resolves waiting jobs whose upstream jobs have finished, or
+// that have nothing left to wait for, and drops jobs that are no longer
+// waiting from the index.
+func (s *Scheduler) sweepWaiting() {
+	jobIDs, err := s.client.SMembers(s.ctx, WaitingJobsKey).Result()
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.log.Error("failed to read waiting jobs", "error", err)
+		}
+		return
+	}
+
+	for _, jobID := range jobIDs {
+		if err := s.sweepWaitingJob(jobID); err != nil && s.ctx.Err() == nil {
+			s.log.Error("failed to resolve waiting job", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+func (s *Scheduler) sweepWaitingJob(jobID string) error {
+	state, err := s.client.HGet(s.ctx, jobKey(jobID), "job_state").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if JobState(state) != JobStateWaiting {
+		return s.client.SRem(s.ctx, WaitingJobsKey, jobID).Err()
+	}
+
+	upstream, err := s.client.SMembers(s.ctx, waitingOnKey(jobID)).Result()
+	if err != nil {
+		return err
+	}
+	if len(upstream) == 0 {
+		// its last dependency was ticked off but the release did not happen
+		s.log.Warn("releasing waiting job with no dependencies left", "job_id", jobID)
+		return s.releaseWaiting(jobID)
+	}
+	for _, upstreamID := range upstream {
+		upstreamState, err := s.client.HGet(s.ctx, jobKey(upstreamID), "job_state").Result()
+		if errors.Is(err, redis.Nil) {
+			// it expired or was deleted, so it will never succeed
+			s.log.Warn("cancelling job with missing dependency", "job_id", jobID, "dependency", upstreamID)
+			err := s.cancelJob(jobID, fmt.Errorf("%w: %s", ErrDependencyNotFound, upstreamID).Error())
+			if errors.Is(err, ErrJobFinished) || errors.Is(err, ErrJobNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !isTerminalState(JobState(upstreamState)) {
+			continue
+		}
+		s.log.Warn("resolving missed dependency", "job_id", jobID, "dependency", upstreamID, "state", upstreamState)
+		if err := s.resolveDependent(jobID, upstreamID, JobState(upstreamState)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseWaiting moves a job whose dependencies have all succeeded from
+// Waiting to Scheduled and puts it in line.
+func (s *Scheduler) releaseWaiting(jobID string) error {
+	key := jobKey(jobID)
+	released := false
+
+	release := func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(s.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if JobState(fields["job_state"]) != JobStateWaiting {
+			// released already or cancelled
+			return nil
+		}
+		job, err := jobFromFields(jobID, fields)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(s.ctx, key, "job_state", string(JobStateScheduled))
+			if job.RunAfter != nil && job.RunAfter.After(time.Now()) {
+				pipe.ZAdd(s.ctx, DelayedJobsKey, redis.Z{Score: float64(job.RunAfter.UnixMilli()), Member: jobID})
+			} else {
+				queueJob(s.ctx, pipe, *job, fields["stream"])
+			}
+			pipe.Del(s.ctx, waitingOnKey(jobID))
+			pipe.SRem(s.ctx, WaitingJobsKey, jobID)
+			trackQuotaUsage(s.ctx, pipe, jobID, fields, JobStateScheduled, time.Now())
+			return nil
+		})
+		released = err == nil
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < maxDependencyAttempts; attempt++ {
+		if err = s.client.Watch(s.ctx, release, key); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil || !released {
+		return err
+	}
+
+	s.emitJobEvent(jobID, JobStateScheduled)
+	s.log.Info("dependencies done, job scheduled", "job_id", jobID)
+	return nil
+}
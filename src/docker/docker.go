@@ -3,6 +3,7 @@ package docker
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"sync"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 // DockerMgr manages Docker containers and volumes, enforces resource limits, and tracks active resources.
@@ -35,6 +37,11 @@ func NewDockerMgr(client *client.Client, containerLimit, volumeLimit int) *Docke
 	}
 }
 
+// ContainerLimit returns the maximum number of containers the manager runs at once.
+func (mgr *DockerMgr) ContainerLimit() int {
+	return mgr.containerLimit
+}
+
 // StopContainer stops a running container by its ID.
 // Returns an error if the operation fails.
 func (mgr *DockerMgr) StopContainer(containerID string) error {
@@ -116,10 +123,55 @@ func (mgr *DockerMgr) RemoveVolume(volumeName string, force bool) error {
 	return nil
 }
 
-// RunContainer creates and starts a container with the specified image, runtime, and volume attached at /data.
+// ContainerSpec describes the container to run for a job.
+type ContainerSpec struct {
+	Image      string
+	Runtime    string
+	Volume     string    // mounted at /data
+	Entrypoint []string  // overrides the image entrypoint when set
+	Cmd        []string  // overrides the image command when set
+	Env        []string  // KEY=VALUE pairs
+	Devices    []string  // host device paths passed through to the container
+	Inputs     io.Reader // tar archive unpacked into /data before the container starts
+
+	// Resource limits; zero leaves the Docker default, which is unlimited except for ShmSize.
+	NanoCPUs  int64 // CPU quota in units of 10^-9 CPUs
+	Memory    int64 // memory limit in bytes, with no swap on top of it
+	ShmSize   int64 // size of /dev/shm in bytes
+	PidsLimit int64 // maximum number of processes
+}
+
+// RunContainer creates and starts a container from spec with its volume attached at /data.
 // Enforces the container limit and checks that the volume exists.
 // Returns the container ID or an error.
-func (mgr *DockerMgr) RunContainer(imageName string, runtimeName string, volumeName string) (string, error) {
+func (mgr *DockerMgr) RunContainer(spec ContainerSpec) (string, error) {
+	containerID, err := mgr.createContainer(spec)
+	if err != nil {
+		return "", err
+	}
+	ctx := mgr.ctx
+	cli := mgr.cli
+
+	// inputs can be large, copy them without holding up other containers
+	if spec.Inputs != nil {
+		if err := cli.CopyToContainer(ctx, containerID, "/data", spec.Inputs, container.CopyToContainerOptions{}); err != nil {
+			slog.Error("Failed to copy inputs to container", "containerID", containerID, "error", err)
+			mgr.RemoveContainer(containerID)
+			return "", fmt.Errorf("copy inputs: %w", err)
+		}
+	}
+
+	if err := cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		slog.Error("Failed to start container", "containerID", containerID, "error", err)
+		return "", err
+	}
+
+	return containerID, nil
+}
+
+// createContainer creates the container for spec and counts it against the
+// container limit.
+func (mgr *DockerMgr) createContainer(spec ContainerSpec) (string, error) {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
 	if len(mgr.containers) >= mgr.containerLimit {
@@ -132,31 +184,57 @@ func (mgr *DockerMgr) RunContainer(imageName string, runtimeName string, volumeN
 	vols, _ := cli.VolumeList(ctx, volume.ListOptions{})
 	found := false
 	for _, v := range vols.Volumes {
-		if v.Name == volumeName {
+		if v.Name == spec.Volume {
 			found = true
 			break
 		}
 	}
 	if !found {
-		slog.Error("Volume does not exist for container", "volumeName", volumeName)
-		return "", fmt.Errorf("volume %s does not exist", volumeName)
+		slog.Error("Volume does not exist for container", "volumeName", spec.Volume)
+		return "", fmt.Errorf("volume %s does not exist", spec.Volume)
+	}
+
+	var devices []container.DeviceMapping
+	for _, d := range spec.Devices {
+		devices = append(devices, container.DeviceMapping{
+			PathOnHost:        d,
+			PathInContainer:   d,
+			CgroupPermissions: "rwm",
+		})
+	}
+
+	resources := container.Resources{
+		Devices:  devices,
+		NanoCPUs: spec.NanoCPUs,
+		Memory:   spec.Memory,
+	}
+	if spec.Memory > 0 {
+		// the same value for memory and memory+swap disables swap
+		resources.MemorySwap = spec.Memory
+	}
+	if spec.PidsLimit > 0 {
+		resources.PidsLimit = &spec.PidsLimit
 	}
 
 	resp, err := cli.ContainerCreate(
 		ctx,
 		&container.Config{
-			Image: imageName,
-			Cmd:   []string{"sleep", "1000"},
+			Image:      spec.Image,
+			Entrypoint: spec.Entrypoint,
+			Cmd:        spec.Cmd,
+			Env:        spec.Env,
 		},
 		&container.HostConfig{
-			Runtime: runtimeName,
+			Runtime: spec.Runtime,
 			Mounts: []mount.Mount{
 				{
 					Type:   mount.TypeVolume,
-					Source: volumeName,
+					Source: spec.Volume,
 					Target: "/data",
 				},
 			},
+			ShmSize:   spec.ShmSize,
+			Resources: resources,
 		},
 		nil,
 		nil,
@@ -164,15 +242,84 @@ func (mgr *DockerMgr) RunContainer(imageName string, runtimeName string, volumeN
 	)
 
 	if err != nil {
-		slog.Error("Failed to create container", "imageName", imageName, "runtimeName", runtimeName, "volumeName", volumeName, "error", err)
+		slog.Error("Failed to create container", "imageName", spec.Image, "runtimeName", spec.Runtime, "volumeName", spec.Volume, "error", err)
 		return "", err
 	}
 
 	mgr.containers[resp.ID] = struct{}{}
-	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		slog.Error("Failed to start container", "containerID", resp.ID, "error", err)
-		return "", err
+	return resp.ID, nil
+}
+
+// WaitContainer blocks until the container stops running and returns its exit code.
+// Returns an error if the wait fails or the daemon reports an error for the container.
+func (mgr *DockerMgr) WaitContainer(containerID string) (int64, error) {
+	statusCh, errCh := mgr.cli.ContainerWait(mgr.ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		slog.Error("Failed to wait for container", "containerID", containerID, "error", err)
+		return -1, err
+	case status := <-statusCh:
+		if status.Error != nil {
+			slog.Error("Container wait returned an error", "containerID", containerID, "error", status.Error.Message)
+			return status.StatusCode, fmt.Errorf("wait for container %s: %s", containerID, status.Error.Message)
+		}
+		return status.StatusCode, nil
 	}
+}
 
-	return resp.ID, nil
+// ExitInfo describes how a container stopped.
+type ExitInfo struct {
+	ExitCode  int64
+	OOMKilled bool   // killed for exceeding its memory limit
+	Error     string // set when the daemon failed to run the container
+}
+
+// InspectExit returns how the exited container stopped.
+func (mgr *DockerMgr) InspectExit(containerID string) (ExitInfo, error) {
+	info, err := mgr.cli.ContainerInspect(mgr.ctx, containerID)
+	if err != nil {
+		slog.Error("Failed to inspect container", "containerID", containerID, "error", err)
+		return ExitInfo{}, err
+	}
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return ExitInfo{}, fmt.Errorf("no state for container %s", containerID)
+	}
+	return ExitInfo{
+		ExitCode:  int64(info.State.ExitCode),
+		OOMKilled: info.State.OOMKilled,
+		Error:     info.State.Error,
+	}, nil
+}
+
+// StreamLogs copies the container's stdout and stderr to the given writers as it
+// produces them, from the start of its output until it exits or ctx is cancelled.
+func (mgr *DockerMgr) StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	logs, err := mgr.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		slog.Error("Failed to get container logs", "containerID", containerID, "error", err)
+		return err
+	}
+	defer logs.Close()
+
+	// containers without a TTY multiplex both streams over one connection
+	if _, err := stdcopy.StdCopy(stdout, stderr, logs); err != nil && ctx.Err() == nil {
+		slog.Error("Failed to read container logs", "containerID", containerID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of path in the container, which may already
+// have exited. Entries are named relative to the parent of path. The caller must close it.
+func (mgr *DockerMgr) CopyFromContainer(containerID, path string) (io.ReadCloser, error) {
+	rc, _, err := mgr.cli.CopyFromContainer(mgr.ctx, containerID, path)
+	if err != nil {
+		slog.Error("Failed to copy from container", "containerID", containerID, "path", path, "error", err)
+		return nil, err
+	}
+	return rc, nil
 }
@@ -1,7 +1,9 @@
 package docker
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types/volume"
@@ -25,6 +27,16 @@ func cpuImageAndRuntime(t *testing.T, mgr *DockerMgr) (imageName, runtimeName st
 	return "pytorch-cpu", "runc"
 }
 
+// idleSpec returns a spec for a container that keeps running until it is stopped.
+func idleSpec(imageName, runtimeName, volumeName string) ContainerSpec {
+	return ContainerSpec{
+		Image:   imageName,
+		Runtime: runtimeName,
+		Volume:  volumeName,
+		Cmd:     []string{"sleep", "1000"},
+	}
+}
+
 // Create a volume, check exists, delete, check not exists
 func TestCreateDeleteVolume(t *testing.T) {
 	mgr := setupMgr(t)
@@ -93,7 +105,7 @@ func TestRunContainerCPU(t *testing.T) {
 		t.Fatalf("Failed to create volume %s: %v", volName, err)
 	}
 	defer mgr.RemoveVolume(volName, true)
-	containerID, err := mgr.RunContainer(imageName, runtimeName, volName)
+	containerID, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err != nil {
 		t.Fatalf("Failed to start CPU container: %v", err)
 	}
@@ -104,6 +116,98 @@ func TestRunContainerCPU(t *testing.T) {
 	t.Logf("CPU container started: %s", containerID[:12])
 }
 
+// TestWaitContainerExitCode verifies that WaitContainer reports the exit code of the workload.
+func TestWaitContainerExitCode(t *testing.T) {
+	mgr := setupMgr(t)
+	imageName, runtimeName := cpuImageAndRuntime(t, mgr)
+	volName := "test_volume_exit_code"
+	_, err := mgr.CreateVolume(volName)
+	if err != nil {
+		t.Fatalf("Failed to create volume %s: %v", volName, err)
+	}
+	defer mgr.RemoveVolume(volName, true)
+	containerID, err := mgr.RunContainer(ContainerSpec{
+		Image:   imageName,
+		Runtime: runtimeName,
+		Volume:  volName,
+		Cmd:     []string{"sh", "-c", "exit 3"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+	defer mgr.RemoveContainer(containerID)
+	code, err := mgr.WaitContainer(containerID)
+	if err != nil {
+		t.Fatalf("Failed to wait for container: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code: got %d want 3", code)
+	}
+}
+
+// TestInspectExitOOM verifies that a container exceeding its memory limit is reported as OOM-killed.
+func TestInspectExitOOM(t *testing.T) {
+	mgr := setupMgr(t)
+	imageName, runtimeName := cpuImageAndRuntime(t, mgr)
+	volName := "test_volume_oom"
+	_, err := mgr.CreateVolume(volName)
+	if err != nil {
+		t.Fatalf("Failed to create volume %s: %v", volName, err)
+	}
+	defer mgr.RemoveVolume(volName, true)
+	containerID, err := mgr.RunContainer(ContainerSpec{
+		Image:   imageName,
+		Runtime: runtimeName,
+		Volume:  volName,
+		Cmd:     []string{"python", "-c", "b = bytearray(512 << 20)"},
+		Memory:  64 << 20,
+	})
+	if err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+	defer mgr.RemoveContainer(containerID)
+	code, err := mgr.WaitContainer(containerID)
+	if err != nil {
+		t.Fatalf("Failed to wait for container: %v", err)
+	}
+	info, err := mgr.InspectExit(containerID)
+	if err != nil {
+		t.Fatalf("Failed to inspect container: %v", err)
+	}
+	if !info.OOMKilled || info.ExitCode != code || code == 0 {
+		t.Errorf("expected OOM kill with exit code %d, got %+v", code, info)
+	}
+}
+
+// TestStreamLogs verifies that StreamLogs separates the container's stdout and stderr.
+func TestStreamLogs(t *testing.T) {
+	mgr := setupMgr(t)
+	imageName, runtimeName := cpuImageAndRuntime(t, mgr)
+	volName := "test_volume_logs"
+	_, err := mgr.CreateVolume(volName)
+	if err != nil {
+		t.Fatalf("Failed to create volume %s: %v", volName, err)
+	}
+	defer mgr.RemoveVolume(volName, true)
+	containerID, err := mgr.RunContainer(ContainerSpec{
+		Image:   imageName,
+		Runtime: runtimeName,
+		Volume:  volName,
+		Cmd:     []string{"sh", "-c", "echo out; echo err >&2"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+	defer mgr.RemoveContainer(containerID)
+	var stdout, stderr strings.Builder
+	if err := mgr.StreamLogs(context.Background(), containerID, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to stream logs: %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("logs: got stdout %q stderr %q", stdout.String(), stderr.String())
+	}
+}
+
 // Remove volume in use (should fail or panic)
 func TestRemoveVolumeInUse(t *testing.T) {
 	mgr := setupMgr(t)
@@ -113,7 +217,7 @@ func TestRemoveVolumeInUse(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create volume %s: %v", volName, err)
 	}
-	containerID, err := mgr.RunContainer(imageName, runtimeName, volName)
+	containerID, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err != nil {
 		t.Fatalf("Failed to start container: %v", err)
 	}
@@ -148,7 +252,7 @@ func TestAttachNonexistentVolume(t *testing.T) {
 	mgr := setupMgr(t)
 	imageName, runtimeName := cpuImageAndRuntime(t, mgr)
 	volName := "nonexistent_volume_t6"
-	id, err := mgr.RunContainer(imageName, runtimeName, volName)
+	id, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	// If Docker auto-creates the volume, this may not error; check your policy
 	if id != "" && err != nil {
 		t.Errorf("Expected error when attaching nonexistent volume, but got id=%v, err=%v", id, err)
@@ -166,11 +270,11 @@ func TestTwoContainersSameVolume(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create volume %s: %v", volName, err)
 	}
-	id1, err := mgr.RunContainer(imageName, runtimeName, volName)
+	id1, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err != nil {
 		t.Fatalf("Failed to start first container: %v", err)
 	}
-	id2, err := mgr.RunContainer(imageName, runtimeName, volName)
+	id2, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err != nil {
 		t.Fatalf("Failed to start second container: %v", err)
 	}
@@ -201,11 +305,11 @@ func TestTwoContainersSameVolumeConcurrent(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create volume %s: %v", volName, err)
 	}
-	id1, err := mgr.RunContainer(imageName, runtimeName, volName)
+	id1, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err != nil {
 		t.Fatalf("Failed to start first container: %v", err)
 	}
-	id2, err2 := mgr.RunContainer(imageName, runtimeName, volName)
+	id2, err2 := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err2 != nil {
 		t.Fatalf("Failed to start second container: %v", err2)
 	}
@@ -271,13 +375,13 @@ func TestContainerLimit(t *testing.T) {
 	ids := []string{}
 	limit := 10
 	for i := 0; i < limit; i++ {
-		id, err := mgr.RunContainer(imageName, runtimeName, volName)
+		id, err := mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 		if err != nil {
 			t.Fatalf("Failed to start container %d: %v", i, err)
 		}
 		ids = append(ids, id)
 	}
-	_, err = mgr.RunContainer(imageName, runtimeName, volName)
+	_, err = mgr.RunContainer(idleSpec(imageName, runtimeName, volName))
 	if err == nil {
 		t.Errorf("Container limit not enforced")
 	} else {
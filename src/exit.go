@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"mist/docker"
+)
+
+// When a job's container stops without succeeding, the supervisor works out
+// why and records it in the job result, so users can tell a bug in their code
+// from a job that needs more memory or time.
+
+// Reasons a job's container stopped without succeeding.
+const (
+	FailureExit    = "exit"    // the workload exited with a non-zero code
+	FailureSignal  = "signal"  // the workload was killed by a signal, e.g. a segfault
+	FailureOOM     = "OOM"     // killed for exceeding its memory limit
+	FailureTimeout = "timeout" // stopped for running past its timeout
+	FailureError   = "error"   // the container runtime failed to run the workload
+)
+
+// signalNames names the signals a workload is commonly killed by.
+var signalNames = map[int64]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+// ExitStatus describes how a job's container stopped.
+type ExitStatus struct {
+	Reason    string // one of the Failure reasons, or "" if the job succeeded
+	ExitCode  int64
+	Signal    string // e.g. SIGSEGV, for exit codes above 128
+	OOMKilled bool
+	Error     string // reported by the container runtime
+	Limit     string // the memory or time limit an OOM kill or timeout exceeded
+}
+
+// exitStatus classifies how a container stopped. memoryLimit and timeout
+// are the job's limits, named in the status when the job exceeded them.
+func exitStatus(info docker.ExitInfo, timedOut bool, memoryLimit, timeout string) ExitStatus {
+	st := ExitStatus{ExitCode: info.ExitCode, OOMKilled: info.OOMKilled, Error: info.Error}
+	if info.ExitCode > 128 && info.ExitCode < 128+65 {
+		n := info.ExitCode - 128
+		st.Signal = signalNames[n]
+		if st.Signal == "" {
+			st.Signal = fmt.Sprintf("signal %d", n)
+		}
+	}
+
+	switch {
+	case timedOut:
+		// the supervisor stopped it, whatever the exit code says
+		st.Reason, st.Limit = FailureTimeout, timeout
+	case info.OOMKilled:
+		st.Reason, st.Limit = FailureOOM, memoryLimit
+	case info.Error != "":
+		st.Reason = FailureError
+	case st.Signal != "":
+		st.Reason = FailureSignal
+	case info.ExitCode != 0:
+		st.Reason = FailureExit
+	}
+	return st
+}
+
+// recordIn adds the status to a job result.
+func (st ExitStatus) recordIn(result map[string]interface{}) {
+	result["exit_code"] = st.ExitCode
+	if st.Reason != "" {
+		result["reason"] = st.Reason
+	}
+	if st.Signal != "" {
+		result["signal"] = st.Signal
+	}
+	if st.OOMKilled {
+		result["oom_killed"] = true
+	}
+	if st.Error != "" {
+		result["container_error"] = st.Error
+	}
+}
+
+// exitError reports that a job's container ran and stopped without
+// succeeding. Failures that come from the workload itself or its limits are
+// not retried, since another attempt would fail the same way.
+type exitError struct {
+	status ExitStatus
+}
+
+// retryable reports whether the container runtime, rather than the workload,
+// failed the job, so another attempt may succeed.
+func (e *exitError) retryable() bool {
+	return e.status.Reason == FailureError
+}
+
+func (e *exitError) Error() string {
+	st := e.status
+	switch st.Reason {
+	case FailureTimeout:
+		return fmt.Sprintf("job exceeded its time limit of %s; set resources.timeout to allow more", st.Limit)
+	case FailureOOM:
+		return fmt.Sprintf("job ran out of memory (limit %s); set resources.memory to allow more", st.Limit)
+	case FailureError:
+		return fmt.Sprintf("container failed: %s", st.Error)
+	case FailureSignal:
+		return fmt.Sprintf("container was killed by %s (exit code %d)", st.Signal, st.ExitCode)
+	}
+	return fmt.Sprintf("container exited with code %d", st.ExitCode)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"mist/docker"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     docker.ExitInfo
+		timedOut bool
+		reason   string
+		signal   string
+		message  string
+		retry    bool
+	}{
+		{"success", docker.ExitInfo{}, false, "", "", "", false},
+		{"exit code", docker.ExitInfo{ExitCode: 3}, false, FailureExit, "", "exited with code 3", false},
+		{"segfault", docker.ExitInfo{ExitCode: 139}, false, FailureSignal, "SIGSEGV", "killed by SIGSEGV", false},
+		{"unnamed signal", docker.ExitInfo{ExitCode: 128 + 30}, false, FailureSignal, "signal 30", "killed by signal 30", false},
+		{"oom", docker.ExitInfo{ExitCode: 137, OOMKilled: true}, false, FailureOOM, "SIGKILL", "ran out of memory (limit 4GiB)", false},
+		{"timeout", docker.ExitInfo{ExitCode: 137, OOMKilled: true}, true, FailureTimeout, "SIGKILL", "time limit of 2h0m0s", false},
+		{"timeout with clean exit", docker.ExitInfo{}, true, FailureTimeout, "", "time limit of 2h0m0s", false},
+		{"runtime error", docker.ExitInfo{ExitCode: 127, Error: "exec: python: not found"}, false, FailureError, "", "container failed: exec: python: not found", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := exitStatus(tt.info, tt.timedOut, "4GiB", "2h0m0s")
+			if st.Reason != tt.reason || st.Signal != tt.signal {
+				t.Errorf("got reason %q signal %q, want %q %q", st.Reason, st.Signal, tt.reason, tt.signal)
+			}
+			if tt.reason == "" {
+				return
+			}
+			err := error(&exitError{status: st})
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("error %q does not contain %q", err, tt.message)
+			}
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) {
+				t.Error("expected an exitError")
+			}
+			if exitErr.retryable() != tt.retry {
+				t.Errorf("got retryable %v, want %v", exitErr.retryable(), tt.retry)
+			}
+		})
+	}
+}
+
+func TestExitStatusRecordIn(t *testing.T) {
+	result := make(map[string]interface{})
+	exitStatus(docker.ExitInfo{ExitCode: 137, OOMKilled: true}, false, "4GiB", "").recordIn(result)
+	if result["exit_code"] != int64(137) || result["reason"] != FailureOOM || result["signal"] != "SIGKILL" || result["oom_killed"] != true {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	result = make(map[string]interface{})
+	exitStatus(docker.ExitInfo{}, false, "4GiB", "").recordIn(result)
+	if len(result) != 1 || result["exit_code"] != int64(0) {
+		t.Errorf("expected only the exit code for a successful job, got %v", result)
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Submitted jobs are not added to a stream straight away. They wait in a
+// pending queue per stream and owner, ordered by priority and then by age,
+// and the scheduler's dispatcher releases them into the stream a few at a
+// time. Each time it picks the owner to serve next: the highest priority
+// waiting job wins, and between jobs of equal priority the owner with the
+// smallest share of recent usage relative to their weight goes first. A user
+// who submits a hundred jobs therefore only gets every other slot when
+// someone else is waiting too.
+
+type JobPriority string
+
+const (
+	PriorityLow    JobPriority = "low"
+	PriorityNormal JobPriority = "normal"
+	PriorityHigh   JobPriority = "high"
+)
+
+const (
+	PendingStreamsKey      = "jobs:pending:streams"
+	FairShareWeightsKey    = "fairshare:weights"
+	DispatchInterval       = 500 * time.Millisecond
+	DefaultDispatchWindow  = 4
+	DefaultFairShareWeight = 1.0
+
+	// RunningJobEstimate is the least a released or running job adds to its
+	// owner's fair-share usage, since it will usually run a while yet.
+	RunningJobEstimate = time.Hour
+
+	// scores of pending jobs put the priority above the creation time in ms
+	priorityScoreBase = 1e13
+)
+
+var (
+	ErrInvalidPriority = errors.New("invalid priority")
+	ErrInvalidWeight   = errors.New("invalid fair-share weight")
+)
+
+// parsePriority validates a priority given by a user. Empty means normal.
+func parsePriority(s string) (JobPriority, error) {
+	switch p := JobPriority(s); p {
+	case "":
+		return PriorityNormal, nil
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w %q: expected low, normal or high", ErrInvalidPriority, s)
+}
+
+func (p JobPriority) rank() int {
+	switch p {
+	case PriorityHigh:
+		return 2
+	case PriorityLow:
+		return 0
+	}
+	return 1
+}
+
+// pendingScore orders an owner's pending jobs: higher priority first, then
+// oldest first.
+func pendingScore(priority JobPriority, created time.Time) float64 {
+	return float64(2-priority.rank())*priorityScoreBase + float64(created.UnixMilli())
+}
+
+func scoreRank(score float64) int {
+	return 2 - int(math.Floor(score/priorityScoreBase))
+}
+
+// fairShareOwner is the owner pending jobs are queued under.
+func fairShareOwner(owner string) string {
+	if owner == "" {
+		return "anonymous"
+	}
+	return owner
+}
+
+func pendingKey(stream, owner string) string {
+	return fmt.Sprintf("jobs:pending:%s:%s", stream, fairShareOwner(owner))
+}
+
+func pendingOwnersKey(stream string) string {
+	return fmt.Sprintf("jobs:pending:owners:%s", stream)
+}
+
+// releasedKey holds the jobs released into stream that no supervisor has
+// started yet.
+func releasedKey(stream string) string {
+	return fmt.Sprintf("jobs:released:%s", stream)
+}
+
+// shareCandidate is the next pending job of one owner.
+type shareCandidate struct {
+	owner  string
+	jobID  string
+	score  float64
+	usage  float64 // hours, see fairShareUsage
+	weight float64
+}
+
+func (c shareCandidate) share() float64 {
+	return c.usage / c.weight
+}
+
+// pickNext returns the index of the candidate to release next, or -1 if
+// there is none.
+func pickNext(candidates []shareCandidate) int {
+	best := -1
+	for i, c := range candidates {
+		if c.jobID == "" {
+			continue
+		}
+		if best < 0 || fairShareLess(c, candidates[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func fairShareLess(a, b shareCandidate) bool {
+	if ra, rb := scoreRank(a.score), scoreRank(b.score); ra != rb {
+		return ra > rb
+	}
+	if sa, sb := a.share(), b.share(); sa != sb {
+		return sa < sb
+	}
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	return a.owner < b.owner
+}
+
+// queueJob adds job to the pending queue of its owner on stream.
+func queueJob(ctx context.Context, pipe redis.Pipeliner, job Job, stream string) {
+	pipe.ZAdd(ctx, pendingKey(stream, job.Owner), redis.Z{
+		Score:  pendingScore(job.Priority, job.Created),
+		Member: job.ID,
+	})
+	pipe.SAdd(ctx, pendingOwnersKey(stream), fairShareOwner(job.Owner))
+	pipe.SAdd(ctx, PendingStreamsKey, stream)
+}
+
+// dispatch runs due schedules, promotes due delayed jobs and releases
+// pending jobs every DispatchInterval until the scheduler is closed. Waiting
+// jobs are swept less often.
+func (s *Scheduler) dispatch() {
+	ticker := time.NewTicker(DispatchInterval)
+	defer ticker.Stop()
+	sweep := time.NewTicker(DependencySweepInterval)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.runSchedules()
+			s.promoteDelayed()
+			s.dispatchPending()
+		case <-sweep.C:
+			s.sweepWaiting()
+		}
+	}
+}
+
+// dispatchPending makes one pass over every stream with pending jobs.
+func (s *Scheduler) dispatchPending() {
+	streams, err := s.client.SMembers(s.ctx, PendingStreamsKey).Result()
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.log.Error("failed to read pending streams", "error", err)
+		}
+		return
+	}
+	for _, stream := range streams {
+		if err := s.dispatchStream(stream); err != nil && s.ctx.Err() == nil {
+			s.log.Error("failed to dispatch jobs", "stream", stream, "error", err)
+		}
+	}
+}
+
+// dispatchStream releases jobs into stream until DispatchWindow of them are
+// waiting for a supervisor.
+func (s *Scheduler) dispatchStream(stream string) error {
+	released, err := s.pruneReleased(stream)
+	if err != nil {
+		return err
+	}
+	free := s.dispatchWindow - released
+	if free <= 0 {
+		return nil
+	}
+
+	owners, err := s.client.SMembers(s.ctx, pendingOwnersKey(stream)).Result()
+	if err != nil || len(owners) == 0 {
+		return err
+	}
+	sort.Strings(owners)
+
+	candidates, err := s.shareCandidates(stream, owners)
+	if err != nil {
+		return err
+	}
+
+	for free > 0 {
+		i := pickNext(candidates)
+		if i < 0 {
+			break
+		}
+		c := &candidates[i]
+
+		// only the scheduler that removes the job gets to release it
+		removed, err := s.client.ZRem(s.ctx, pendingKey(stream, c.owner), c.jobID).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 1 {
+			ok, err := s.release(c.jobID, stream)
+			var quotaErr *QuotaError
+			if errors.As(err, &quotaErr) {
+				// the owner runs as many jobs as they may, skip them this pass
+				s.client.ZAdd(s.ctx, pendingKey(stream, c.owner), redis.Z{Score: c.score, Member: c.jobID})
+				c.jobID = ""
+				continue
+			}
+			if err != nil {
+				// put it back so it is not lost
+				s.client.ZAdd(s.ctx, pendingKey(stream, c.owner), redis.Z{Score: c.score, Member: c.jobID})
+				return err
+			}
+			if ok {
+				free--
+				c.usage += RunningJobEstimate.Hours() // the released job now runs for the owner
+			}
+		}
+
+		if err := s.nextCandidate(stream, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneReleased drops the jobs of stream's released set that are no longer
+// scheduled and returns how many are left. Jobs normally leave the set when
+// their start is recorded, but one that was cancelled, or whose update was
+// lost, would otherwise hold a dispatch slot forever.
+func (s *Scheduler) pruneReleased(stream string) (int, error) {
+	key := releasedKey(stream)
+	jobIDs, err := s.client.SMembers(s.ctx, key).Result()
+	if err != nil || len(jobIDs) == 0 {
+		return 0, err
+	}
+
+	pipe := s.client.Pipeline()
+	states := make([]*redis.StringCmd, len(jobIDs))
+	for i, jobID := range jobIDs {
+		states[i] = pipe.HGet(s.ctx, jobKey(jobID), "job_state")
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+
+	var stale []interface{}
+	for i, cmd := range states {
+		if JobState(cmd.Val()) != JobStateScheduled {
+			stale = append(stale, jobIDs[i])
+		}
+	}
+	if len(stale) == 0 {
+		return len(jobIDs), nil
+	}
+	if err := s.client.SRem(s.ctx, key, stale...).Err(); err != nil {
+		return 0, err
+	}
+	s.log.Info("dropped stale released jobs", "stream", stream, "jobs", len(stale))
+	return len(jobIDs) - len(stale), nil
+}
+
+// shareCandidates returns the next pending job of each owner along with
+// their usage and weight.
+func (s *Scheduler) shareCandidates(stream string, owners []string) ([]shareCandidate, error) {
+	weights, err := s.client.HMGet(s.ctx, FairShareWeightsKey, owners...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]shareCandidate, len(owners))
+	for i, owner := range owners {
+		weight := DefaultFairShareWeight
+		if v, ok := weights[i].(string); ok {
+			if w, err := strconv.ParseFloat(v, 64); err == nil && w > 0 {
+				weight = w
+			}
+		}
+
+		usage, err := s.fairShareUsage(owner)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates[i] = shareCandidate{
+			owner:  owner,
+			usage:  usage,
+			weight: weight,
+		}
+		if err := s.nextCandidate(stream, &candidates[i]); err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+// fairShareUsage returns the hours owner's jobs have taken lately: the GPU
+// hours of the last week plus the time their jobs have been running so far,
+// each running job counting for at least RunningJobEstimate.
+func (s *Scheduler) fairShareUsage(owner string) (float64, error) {
+	usage, err := s.quotas.Usage(QuotaKindUser, owner)
+	if err != nil {
+		return 0, err
+	}
+	running, err := s.client.SMembers(s.ctx, runningJobsKey(QuotaKindUser, owner)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := s.client.Pipeline()
+	started := make([]*redis.StringCmd, len(running))
+	for i, jobID := range running {
+		started[i] = pipe.HGet(s.ctx, jobKey(jobID), "time_started")
+	}
+	if len(running) > 0 {
+		if _, err := pipe.Exec(s.ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+	}
+
+	hours := usage.GPUHours
+	now := time.Now()
+	for _, cmd := range started {
+		hours += runningHours(parseOptionalTime(cmd.Val()), now)
+	}
+	return hours, nil
+}
+
+// runningHours is what a job started at started, or not yet started if nil,
+// adds to fair-share usage at now.
+func runningHours(started *time.Time, now time.Time) float64 {
+	if started == nil || now.Sub(*started) < RunningJobEstimate {
+		return RunningJobEstimate.Hours()
+	}
+	return now.Sub(*started).Hours()
+}
+
+// nextCandidate loads the next pending job of c's owner into c, dropping the
+// owner from the stream's pending owners once their queue is empty.
+func (s *Scheduler) nextCandidate(stream string, c *shareCandidate) error {
+	key := pendingKey(stream, c.owner)
+	c.jobID, c.score = "", 0
+
+	return s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		head, err := tx.ZRangeWithScores(s.ctx, key, 0, 0).Result()
+		if err != nil {
+			return err
+		}
+		if len(head) > 0 {
+			c.jobID, _ = head[0].Member.(string)
+			c.score = head[0].Score
+			return nil
+		}
+		// enqueueing a job touches the queue, so this fails rather than
+		// forgetting an owner who just submitted
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.SRem(s.ctx, pendingOwnersKey(stream), c.owner)
+			return nil
+		})
+		if errors.Is(err, redis.TxFailedErr) {
+			return nil
+		}
+		return err
+	}, key)
+}
+
+// release hands a job over to the supervisors by adding it to stream. Jobs
+// that were cancelled meanwhile are dropped; it returns whether the job was
+// released. A *QuotaError means the owner or their team already run as many
+// jobs as their quota allows.
+func (s *Scheduler) release(jobID, stream string) (bool, error) {
+	metadataKey := jobKey(jobID)
+	fields, err := s.client.HGetAll(s.ctx, metadataKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if len(fields) == 0 || JobState(fields["job_state"]) != JobStateScheduled {
+		s.log.Info("dropping job that is no longer scheduled", "job_id", jobID, "state", fields["job_state"])
+		return false, nil
+	}
+	if err := s.quotas.CheckConcurrent(fields["owner"], fields["team"]); err != nil {
+		return false, err
+	}
+
+	messageID, err := s.client.XAdd(s.ctx, &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{
+			"job_id":    jobID,
+			"payload":   fields["payload"],
+			"job_state": string(JobStateScheduled),
+		},
+	}).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to add job to stream: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+	pipe.HSet(s.ctx, metadataKey, "stream", stream, "message_id", messageID)
+	pipe.SAdd(s.ctx, releasedKey(stream), jobID)
+	// a released job counts as running from now on, so the dispatcher does not
+	// release more than the concurrent job limit before any of them start
+	trackQuotaUsage(s.ctx, pipe, jobID, fields, JobStateInProgress, time.Now())
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		s.log.Warn("failed to record job release", "job_id", jobID, "error", err)
+	}
+
+	s.log.Info("released job", "job_id", jobID, "stream", stream, "owner", fields["owner"], "priority", fields["priority"])
+	return true, nil
+}
+
+// SetFairShareWeight sets the weight of owner. An owner with weight 2 is
+// entitled to twice the usage of an owner with the default weight of 1.
+func (s *Scheduler) SetFairShareWeight(owner string, weight float64) error {
+	if weight <= 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+		return fmt.Errorf("%w: must be positive", ErrInvalidWeight)
+	}
+	return s.client.HSet(s.ctx, FairShareWeightsKey, owner, weight).Err()
+}
+
+// FairShareWeights returns the weights that differ from the default.
+func (s *Scheduler) FairShareWeights() (map[string]float64, error) {
+	raw, err := s.client.HGetAll(s.ctx, FairShareWeightsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[string]float64, len(raw))
+	for owner, v := range raw {
+		if w, err := strconv.ParseFloat(v, 64); err == nil {
+			weights[owner] = w
+		}
+	}
+	return weights, nil
+}
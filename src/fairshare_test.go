@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	for in, want := range map[string]JobPriority{"": PriorityNormal, "low": PriorityLow, "high": PriorityHigh} {
+		got, err := parsePriority(in)
+		if err != nil || got != want {
+			t.Errorf("parsePriority(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := parsePriority("urgent"); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+}
+
+func TestPendingScoreOrdersByPriorityThenAge(t *testing.T) {
+	now := time.Now()
+	oldLow := pendingScore(PriorityLow, now.Add(-time.Hour))
+	newHigh := pendingScore(PriorityHigh, now)
+	oldNormal := pendingScore(PriorityNormal, now.Add(-time.Minute))
+	newNormal := pendingScore(PriorityNormal, now)
+
+	if !(newHigh < oldNormal && oldNormal < newNormal && newNormal < oldLow) {
+		t.Errorf("Unexpected order: high=%v oldNormal=%v newNormal=%v low=%v", newHigh, oldNormal, newNormal, oldLow)
+	}
+	for p, score := range map[JobPriority]float64{PriorityLow: oldLow, PriorityNormal: newNormal, PriorityHigh: newHigh} {
+		if scoreRank(score) != p.rank() {
+			t.Errorf("scoreRank for %s = %d, want %d", p, scoreRank(score), p.rank())
+		}
+	}
+}
+
+// simulatePicks releases jobs from per-owner queues the way the dispatcher
+// does and returns the owners in release order.
+func simulatePicks(queues map[string][]float64, usage map[string]float64, weights map[string]float64) []string {
+	var candidates []shareCandidate
+	for owner := range queues {
+		weight := weights[owner]
+		if weight == 0 {
+			weight = DefaultFairShareWeight
+		}
+		candidates = append(candidates, shareCandidate{owner: owner, usage: usage[owner], weight: weight})
+	}
+	next := func(c *shareCandidate) {
+		c.jobID, c.score = "", 0
+		if q := queues[c.owner]; len(q) > 0 {
+			c.jobID, c.score = c.owner, q[0]
+			queues[c.owner] = q[1:]
+		}
+	}
+	for i := range candidates {
+		next(&candidates[i])
+	}
+
+	var order []string
+	for {
+		i := pickNext(candidates)
+		if i < 0 {
+			return order
+		}
+		order = append(order, candidates[i].owner)
+		candidates[i].usage += RunningJobEstimate.Hours()
+		next(&candidates[i])
+	}
+}
+
+func TestPickNextInterleavesOwners(t *testing.T) {
+	now := time.Now()
+	normal := func(d time.Duration) float64 { return pendingScore(PriorityNormal, now.Add(d)) }
+
+	// alice submitted a batch before bob and carol submitted one job each
+	order := simulatePicks(map[string][]float64{
+		"alice": {normal(-time.Hour), normal(-59 * time.Minute), normal(-58 * time.Minute), normal(-57 * time.Minute)},
+		"bob":   {normal(0)},
+		"carol": {normal(time.Second)},
+	}, nil, nil)
+
+	want := []string{"alice", "bob", "carol", "alice", "alice", "alice"}
+	if len(order) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, order)
+		}
+	}
+}
+
+func TestPickNextPriorityAndUsage(t *testing.T) {
+	now := time.Now()
+
+	// a high priority job goes first even from the heaviest user
+	order := simulatePicks(map[string][]float64{
+		"alice": {pendingScore(PriorityHigh, now)},
+		"bob":   {pendingScore(PriorityNormal, now.Add(-time.Hour))},
+	}, map[string]float64{"alice": 50}, nil)
+	if order[0] != "alice" {
+		t.Errorf("Expected the high priority job first, got %v", order)
+	}
+
+	// with equal priority, lower usage per weight goes first
+	order = simulatePicks(map[string][]float64{
+		"alice": {pendingScore(PriorityNormal, now.Add(-time.Hour))},
+		"bob":   {pendingScore(PriorityNormal, now)},
+	}, map[string]float64{"alice": 10, "bob": 8}, map[string]float64{"alice": 2})
+	if order[0] != "alice" {
+		t.Errorf("Expected alice with twice the weight first, got %v", order)
+	}
+}
+
+func TestRunningHours(t *testing.T) {
+	now := time.Now()
+	started := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		started *time.Time
+		want    float64
+	}{
+		{"released", nil, 1},
+		{"just started", started(time.Minute), 1},
+		{"running for hours", started(3 * time.Hour), 3},
+	}
+	for _, tt := range tests {
+		if got := runningHours(tt.started, now); got != tt.want {
+			t.Errorf("%s: runningHours = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
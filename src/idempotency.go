@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A client retrying a submission it did not get an answer for sends the same
+// idempotency key again. The first submission with a key reserves it for its
+// owner by storing the job ID it will get, together with a fingerprint of the
+// request, so a retry gets the original job back instead of a second one and
+// a different request reusing the key is refused.
+
+const (
+	IdempotencyKeyTTL    = 24 * time.Hour
+	MaxIdempotencyKeyLen = 255
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was used for a different request")
+)
+
+func idempotencyKey(owner, key string) string {
+	return fmt.Sprintf("idempotency:%s:%s", fairShareOwner(owner), key)
+}
+
+// validIdempotencyKey reports whether key is short and printable.
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > MaxIdempotencyKeyLen {
+		return false
+	}
+	for _, c := range key {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint identifies the content of a submission.
+func requestFingerprint(req interface{}) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IdempotentJob returns the ID of the job owner already submitted with key,
+// or "" if there is none. It fails with ErrIdempotencyKeyReused if that job
+// was submitted with a different fingerprint.
+func (s *Scheduler) IdempotentJob(owner, key, fingerprint string) (string, error) {
+	value, err := s.client.Get(s.ctx, idempotencyKey(owner, key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	jobID, stored, _ := strings.Cut(value, " ")
+	if stored != fingerprint {
+		return "", ErrIdempotencyKeyReused
+	}
+	return jobID, nil
+}
+
+// EnqueueJobOnce enqueues job unless its owner already submitted a job with
+// key in the last IdempotencyKeyTTL. It returns the ID of the job and whether
+// it was submitted before. The job's ID is kept if the caller set one.
+func (s *Scheduler) EnqueueJobOnce(job Job, key, fingerprint string) (string, bool, error) {
+	if job.ID == "" {
+		job.ID = generateJobID()
+	}
+	redisKey := idempotencyKey(job.Owner, key)
+
+	reserved, err := s.client.SetNX(s.ctx, redisKey, job.ID+" "+fingerprint, IdempotencyKeyTTL).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if !reserved {
+		// another submission with the key got here first
+		jobID, err := s.IdempotentJob(job.Owner, key, fingerprint)
+		if err == nil && jobID == "" {
+			err = fmt.Errorf("idempotency key %q expired during submission", key)
+		}
+		if err != nil {
+			return "", false, err
+		}
+		s.log.Info("duplicate job submission", "job_id", jobID, "owner", job.Owner)
+		return jobID, true, nil
+	}
+
+	jobID, err := s.EnqueueJob(job)
+	if err != nil {
+		// free the key so the client can retry
+		s.client.Del(s.ctx, redisKey)
+		return "", false, err
+	}
+	return jobID, false, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidIdempotencyKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"":                                     false,
+		"0b7e6c1f-3d2a-4a4e-9f1e-6a1f0c2d9b11": true,
+		"submit train.py":                      false,
+		"tab\there":                            false,
+		strings.Repeat("k", 255):               true,
+		strings.Repeat("k", 256):               false,
+	} {
+		if got := validIdempotencyKey(key); got != want {
+			t.Errorf("validIdempotencyKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	a := CreateJobRequest{Type: "train", Payload: map[string]interface{}{"lr": 0.1, "epochs": 3}, RequiredGPU: "AMD"}
+	b := CreateJobRequest{Type: "train", Payload: map[string]interface{}{"epochs": 3, "lr": 0.1}, RequiredGPU: "AMD"}
+	c := CreateJobRequest{Type: "train", Payload: map[string]interface{}{"epochs": 4, "lr": 0.1}, RequiredGPU: "AMD"}
+
+	fa, _ := requestFingerprint(a)
+	fb, _ := requestFingerprint(b)
+	fc, _ := requestFingerprint(c)
+	if fa != fb {
+		t.Errorf("Expected equal requests to have the same fingerprint")
+	}
+	if fa == fc {
+		t.Errorf("Expected different requests to have different fingerprints")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// IDs of jobs, schedules and workflows are a prefix followed by a ULID: 48
+// bits of milliseconds since the epoch and 80 random bits, written as 26
+// characters of Crockford's base32. They sort by creation time, and IDs made
+// in the same millisecond by this process keep their order because the
+// random part is incremented rather than drawn again.
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	ulidMu      sync.Mutex
+	ulidLastMs  uint64
+	ulidLastRnd [10]byte
+
+	jobIDPattern = regexp.MustCompile(`^job_[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+
+	// IDs of jobs created before ULIDs, kept readable
+	legacyJobIDPattern = regexp.MustCompile(`^job_[0-9]{1,20}_[0-9]{1,10}$`)
+)
+
+// newULID returns a ULID for time t.
+func newULID(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+
+	ulidMu.Lock()
+	if ms <= ulidLastMs {
+		// same millisecond, or the clock went back: keep counting
+		ms = ulidLastMs
+		for i := len(ulidLastRnd) - 1; i >= 0; i-- {
+			ulidLastRnd[i]++
+			if ulidLastRnd[i] != 0 {
+				break
+			}
+		}
+	} else {
+		rand.Read(ulidLastRnd[:])
+		ulidLastMs = ms
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	copy(id[6:], ulidLastRnd[:])
+	ulidMu.Unlock()
+
+	return encodeULID(id)
+}
+
+// encodeULID writes the 128 bits of id as 26 base32 characters, the first of
+// which only holds 3 bits.
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+func generateJobID() string {
+	return "job_" + newULID(time.Now())
+}
+
+func generateScheduleID() string {
+	return "sched_" + newULID(time.Now())
+}
+
+func generateWorkflowID() string {
+	return "wf_" + newULID(time.Now())
+}
+
+// validJobID reports whether id is well-formed, so malformed IDs can be
+// rejected before looking them up.
+func validJobID(id string) bool {
+	return jobIDPattern.MatchString(id) || legacyJobIDPattern.MatchString(id)
+}
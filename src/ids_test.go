@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestGenerateJobIDIsValidAndSortable(t *testing.T) {
+	ids := make([]string, 1000)
+	seen := map[string]bool{}
+	for i := range ids {
+		ids[i] = generateJobID()
+		if !validJobID(ids[i]) {
+			t.Fatalf("generated an invalid job ID %q", ids[i])
+		}
+		if seen[ids[i]] {
+			t.Fatalf("generated %q twice", ids[i])
+		}
+		seen[ids[i]] = true
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("Expected job IDs to sort in creation order")
+	}
+}
+
+func TestNewULIDEncodesTime(t *testing.T) {
+	early := newULID(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	late := newULID(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	if len(early) != 26 || len(late) != 26 {
+		t.Fatalf("Expected 26 characters, got %q and %q", early, late)
+	}
+	if early[:10] >= late[:10] {
+		t.Errorf("Expected the time part of %q to sort before %q", early, late)
+	}
+	if got := encodeULID([16]byte{}); got != "00000000000000000000000000" {
+		t.Errorf("Expected the zero ULID, got %q", got)
+	}
+	max := [16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("Expected the largest ULID, got %q", got)
+	}
+}
+
+func TestValidJobID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"job_01HQ3V5E8XK2M9P4R7T6W0Y1ZA":    true,
+		"job_1712345678901234567_4242":      true, // created before ULIDs
+		"":                                  false,
+		"job_":                              false,
+		"job_01HQ3V5E8XK2M9P4R7T6W0Y1Z":     false, // too short
+		"job_81HQ3V5E8XK2M9P4R7T6W0Y1ZA":    false, // overflows 128 bits
+		"job_01HQ3V5E8XK2M9P4R7T6W0Y1ZU":    false, // U is not base32
+		"job_01hq3v5e8xk2m9p4r7t6w0y1za":    false,
+		"01HQ3V5E8XK2M9P4R7T6W0Y1ZA":        false,
+		"job_01HQ3V5E8XK2M9P4R7T6W0Y1ZA/..": false,
+		"job_1_2; DEL *":                    false,
+	} {
+		if got := validJobID(id); got != want {
+			t.Errorf("validJobID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"mist/multilogger"
@@ -69,6 +70,7 @@ func TestIntegration(t *testing.T) {
 	}
 
 	scheduler := NewScheduler(redisAddr, schedulerLog)
+	scheduler.Start()
 	defer scheduler.Close()
 
 	supervisorLog, err := multilogger.CreateLogger("supervisor", &config)
@@ -78,7 +80,7 @@ func TestIntegration(t *testing.T) {
 	}
 
 	consumerID := fmt.Sprintf("worker_%d", os.Getpid())
-	supervisor := NewSupervisor(redisAddr, consumerID, "AMD", supervisorLog)
+	supervisor := NewSupervisor(redisAddr, consumerID, "AMD", supervisorLog, WithRuntime(newFakeRuntime(nil)))
 
 	if err := supervisor.Start(); err != nil {
 		t.Errorf("Failed to start supervisor: %v", err)
@@ -123,7 +125,7 @@ func TestDummySupervisors(t *testing.T) {
 	defer client.Close()
 	client.FlushDB(context.Background())
 
-	app := NewApp(redisAddr, "AMD", log)
+	app := NewApp(redisAddr, "AMD", log, WithRuntime(newFakeRuntime(nil)))
 	defer app.redisClient.Close()
 
 	// Manually add dummy supervisors for testing
@@ -207,3 +209,589 @@ func TestStatusRegistry_BasicOperations(t *testing.T) {
 		t.Errorf("Expected 1 active supervisor, got %d", len(activeSupervisors))
 	}
 }
+
+func TestStatusRegistry_MarkStaleSupervisors(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	registry := NewStatusRegistry(client, log)
+	now := time.Now()
+
+	fresh := SupervisorStatus{ConsumerID: "worker_fresh", GPUType: "AMD", Status: SupervisorStateActive, LastSeen: now, StartedAt: now}
+	stale := SupervisorStatus{ConsumerID: "worker_stale", GPUType: "TT", Status: SupervisorStateActive, LastSeen: now.Add(-time.Minute), StartedAt: now.Add(-time.Hour)}
+	for _, status := range []SupervisorStatus{fresh, stale} {
+		if err := registry.UpdateStatus(status.ConsumerID, status); err != nil {
+			t.Fatalf("UpdateStatus failed: %v", err)
+		}
+	}
+
+	marked, err := registry.MarkStaleSupervisors(SupervisorTimeout)
+	if err != nil {
+		t.Fatalf("MarkStaleSupervisors failed: %v", err)
+	}
+	if len(marked) != 1 || marked[0] != stale.ConsumerID {
+		t.Errorf("Expected only %s to be marked, got %v", stale.ConsumerID, marked)
+	}
+
+	status, err := registry.GetSupervisor(stale.ConsumerID)
+	if err != nil {
+		t.Fatalf("GetSupervisor failed: %v", err)
+	}
+	if status.Status != SupervisorStateFailed {
+		t.Errorf("Expected Status %s, got %s", SupervisorStateFailed, status.Status)
+	}
+
+	active, err := registry.GetActiveSupervisors()
+	if err != nil {
+		t.Fatalf("GetActiveSupervisors failed: %v", err)
+	}
+	if len(active) != 1 || active[0].ConsumerID != fresh.ConsumerID {
+		t.Errorf("Expected only %s to stay active, got %v", fresh.ConsumerID, active)
+	}
+}
+
+func TestSupervisorRegistersAndDeregisters(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	registry := NewStatusRegistry(client, log)
+
+	supervisor := NewSupervisor(redisAddr, "test_worker_heartbeat", "AMD", log, WithRuntime(newFakeRuntime(nil)))
+	if err := supervisor.Start(); err != nil {
+		t.Fatalf("Failed to start supervisor: %v", err)
+	}
+
+	status, err := registry.GetSupervisor("test_worker_heartbeat")
+	if err != nil {
+		t.Fatalf("GetSupervisor failed: %v", err)
+	}
+	if status.Status != SupervisorStateActive {
+		t.Errorf("Expected Status %s after start, got %s", SupervisorStateActive, status.Status)
+	}
+
+	supervisor.Stop()
+
+	status, err = registry.GetSupervisor("test_worker_heartbeat")
+	if err != nil {
+		t.Fatalf("GetSupervisor failed: %v", err)
+	}
+	if status.Status != SupervisorStateInactive {
+		t.Errorf("Expected Status %s after stop, got %s", SupervisorStateInactive, status.Status)
+	}
+}
+
+func TestStatusRegistry_ListJobs(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+	registry := NewStatusRegistry(client, log)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		gpu := "AMD"
+		if i%2 == 1 {
+			gpu = "TT"
+		}
+		id, err := scheduler.Enqueue("train", gpu, map[string]interface{}{"n": i})
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		ids = append(ids, id)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// newest first, two per page
+	var listed []string
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		jobs, next, err := registry.ListJobs(JobFilter{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListJobs failed: %v", err)
+		}
+		for _, job := range jobs {
+			listed = append(listed, job.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(listed) != len(ids) {
+		t.Fatalf("Expected %d jobs across pages, got %v", len(ids), listed)
+	}
+	for i, id := range listed {
+		if want := ids[len(ids)-1-i]; id != want {
+			t.Errorf("Expected job %d to be %s, got %s", i, want, id)
+		}
+	}
+
+	jobs, _, err := registry.ListJobs(JobFilter{RequiredGPU: "TT", Ascending: true})
+	if err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != ids[1] || jobs[1].ID != ids[3] {
+		t.Errorf("Expected TT jobs %s and %s, got %v", ids[1], ids[3], jobs)
+	}
+
+	jobs, _, err = registry.ListJobs(JobFilter{States: []JobState{JobStateSuccess}})
+	if err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("Expected no finished jobs, got %d", len(jobs))
+	}
+}
+
+func TestAuthenticator_LoginAndRefresh(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	auth := NewAuthenticator(client, []byte("test secret"), log)
+	if err := auth.CreateUser("alice", "password123", RoleUser, ""); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if err := auth.CreateUser("alice", "password456", RoleUser, ""); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+
+	if _, err := auth.Login("alice", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	tokens, err := auth.Login("alice", "password123")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	claims, err := auth.VerifyAccessToken(tokens.AccessToken)
+	if err != nil || claims.Subject != "alice" {
+		t.Fatalf("Expected a valid access token for alice, got %+v, %v", claims, err)
+	}
+
+	rotated, err := auth.Refresh(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if rotated.RefreshToken == tokens.RefreshToken {
+		t.Error("Expected the refresh token to be rotated")
+	}
+	if _, err := auth.Refresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a used refresh token to be rejected, got %v", err)
+	}
+}
+
+func TestQuotaManager_EnforcesQueuedLimit(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+	quotas := NewQuotaManager(client, log)
+
+	if err := quotas.SetPolicy(QuotaKindTeam, "club", QuotaPolicy{MaxQueuedJobs: 2}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := quotas.Check("alice", "club", "AMD"); err != nil {
+			t.Fatalf("Expected job %d to be allowed, got %v", i, err)
+		}
+		if _, err := scheduler.EnqueueJob(Job{Type: "train", RequiredGPU: "AMD", Owner: "alice", Team: "club"}); err != nil {
+			t.Fatalf("EnqueueJob failed: %v", err)
+		}
+	}
+
+	err := quotas.Check("bob", "club", "AMD")
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Kind != QuotaKindTeam {
+		t.Fatalf("Expected the team queue limit to be hit, got %v", err)
+	}
+	if err := quotas.Check("bob", "", "AMD"); err != nil {
+		t.Errorf("Expected bob outside the team to be allowed, got %v", err)
+	}
+
+	usage, err := quotas.Usage(QuotaKindUser, "alice")
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.QueuedJobs != 2 {
+		t.Errorf("Expected 2 queued jobs for alice, got %d", usage.QueuedJobs)
+	}
+}
+
+func TestScheduler_FairShareDispatch(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+	scheduler.dispatchWindow = 3
+
+	owners := map[string]string{}
+	for _, owner := range []string{"alice", "alice", "alice", "bob"} {
+		id, err := scheduler.EnqueueJob(Job{Type: "train", RequiredGPU: "AMD", Owner: owner})
+		if err != nil {
+			t.Fatalf("EnqueueJob failed: %v", err)
+		}
+		owners[id] = owner
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	scheduler.dispatchPending()
+
+	stream := streamForGPU("AMD")
+	messages, err := client.XRange(context.Background(), stream, "-", "+").Result()
+	if err != nil {
+		t.Fatalf("XRange failed: %v", err)
+	}
+	var order []string
+	for _, msg := range messages {
+		order = append(order, owners[msg.Values["job_id"].(string)])
+	}
+	if want := []string{"alice", "bob", "alice"}; fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("Expected release order %v, got %v", want, order)
+	}
+}
+
+func TestScheduler_PromotesDelayedJobs(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+	registry := NewStatusRegistry(client, log)
+
+	runAfter := time.Now().Add(200 * time.Millisecond)
+	jobID, err := scheduler.EnqueueJob(Job{Type: "train", RequiredGPU: "AMD", Owner: "alice", RunAfter: &runAfter})
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+
+	job, err := registry.GetJobStatus(jobID)
+	if err != nil {
+		t.Fatalf("GetJobStatus failed: %v", err)
+	}
+	if job.JobState != JobStateScheduled || job.RunAfter == nil {
+		t.Errorf("Expected a scheduled job with run_after, got %+v", job)
+	}
+
+	pending := pendingKey(streamForGPU("AMD"), "alice")
+	scheduler.promoteDelayed()
+	if n := client.ZCard(context.Background(), pending).Val(); n != 0 {
+		t.Fatalf("Expected the job to stay delayed, found %d pending", n)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	scheduler.promoteDelayed()
+	if n := client.ZCard(context.Background(), pending).Val(); n != 1 {
+		t.Errorf("Expected the due job to be pending, found %d", n)
+	}
+	if n := client.ZCard(context.Background(), DelayedJobsKey).Val(); n != 0 {
+		t.Errorf("Expected no delayed jobs left, found %d", n)
+	}
+}
+
+func TestScheduler_RunsDueSchedules(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+
+	create := func(policy MissedRunPolicy, dueAgo time.Duration) *Schedule {
+		sched, err := scheduler.CreateSchedule(Schedule{
+			Cron:       "@hourly",
+			Job:        CreateJobRequest{Type: "eval", RequiredGPU: "AMD"},
+			MissedRuns: policy,
+			Owner:      "alice",
+		})
+		if err != nil {
+			t.Fatalf("CreateSchedule failed: %v", err)
+		}
+		// pretend the run was due a while ago
+		due := time.Now().Add(-dueAgo)
+		client.HSet(context.Background(), scheduleKey(sched.ID), "next_run", due.Format(time.RFC3339Nano))
+		client.ZAdd(context.Background(), ScheduleDueKey, redis.Z{Score: float64(due.UnixMilli()), Member: sched.ID})
+		return sched
+	}
+
+	onTime := create(MissedRunSkip, time.Second)
+	skipped := create(MissedRunSkip, time.Hour)
+	caughtUp := create(MissedRunOnce, time.Hour)
+
+	scheduler.runSchedules()
+	scheduler.runSchedules() // a second pass must not run them again
+
+	for _, tt := range []struct {
+		sched  *Schedule
+		ran    bool
+		missed int
+	}{{onTime, true, 0}, {skipped, false, 1}, {caughtUp, true, 1}} {
+		got, err := scheduler.GetSchedule(tt.sched.ID)
+		if err != nil {
+			t.Fatalf("GetSchedule failed: %v", err)
+		}
+		if (got.LastJobID != "") != tt.ran || got.Missed != tt.missed {
+			t.Errorf("Schedule %s: expected ran=%v missed=%d, got %+v", got.MissedRuns, tt.ran, tt.missed, got)
+		}
+		if got.NextRun == nil || !got.NextRun.After(time.Now()) {
+			t.Errorf("Expected the next run to be in the future, got %v", got.NextRun)
+		}
+		if tt.ran && (got.LastJobState != JobStateScheduled || got.LastRun == nil) {
+			t.Errorf("Expected a scheduled last job, got %+v", got)
+		}
+	}
+
+	jobs, _, err := NewStatusRegistry(client, log).ListJobs(JobFilter{})
+	if err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("Expected 2 submitted jobs, got %d", len(jobs))
+	}
+
+	paused, err := scheduler.SetSchedulePaused(onTime.ID, true)
+	if err != nil || !paused.Paused || paused.NextRun != nil {
+		t.Errorf("Expected a paused schedule without next run, got %+v, %v", paused, err)
+	}
+	if err := client.ZScore(context.Background(), ScheduleDueKey, onTime.ID).Err(); err != redis.Nil {
+		t.Errorf("Expected the paused schedule to leave %s", ScheduleDueKey)
+	}
+}
+
+func TestScheduler_JobDependencies(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+	registry := NewStatusRegistry(client, log)
+
+	wf, err := scheduler.SubmitWorkflow("pipeline", "alice", []WorkflowStep{
+		{Name: "preprocess", Job: Job{Type: "preprocess", Owner: "alice"}},
+		{Name: "train", Job: Job{Type: "train", RequiredGPU: "AMD", Owner: "alice"}, DependsOn: []string{"preprocess"}},
+		{Name: "evaluate", Job: Job{Type: "evaluate", Owner: "alice"}, DependsOn: []string{"train"}},
+		{Name: "report", Job: Job{Type: "report", Owner: "alice"}, DependsOn: []string{"preprocess"}},
+	})
+	if err != nil {
+		t.Fatalf("SubmitWorkflow failed: %v", err)
+	}
+	ids := map[string]string{}
+	for _, job := range wf.Jobs {
+		ids[job.Name] = job.JobID
+	}
+	state := func(name string) JobState {
+		job, err := registry.GetJobStatus(ids[name])
+		if err != nil {
+			t.Fatalf("GetJobStatus failed: %v", err)
+		}
+		return job.JobState
+	}
+
+	if state("preprocess") != JobStateScheduled || state("train") != JobStateWaiting || wf.JobState != JobStateScheduled {
+		t.Fatalf("Unexpected initial states: %+v", wf)
+	}
+
+	// preprocess succeeds, train and report become runnable
+	client.HSet(context.Background(), jobKey(ids["preprocess"]), "job_state", string(JobStateSuccess))
+	scheduler.resolveDependents(ids["preprocess"], JobStateSuccess)
+	scheduler.resolveDependents(ids["preprocess"], JobStateSuccess) // replayed event
+	if state("train") != JobStateScheduled || state("report") != JobStateScheduled || state("evaluate") != JobStateWaiting {
+		t.Errorf("Expected train and report to be scheduled")
+	}
+	if n := client.ZCard(context.Background(), pendingKey(streamForGPU("AMD"), "alice")).Val(); n != 1 {
+		t.Errorf("Expected train to be pending once, found %d", n)
+	}
+
+	// train fails, evaluate is cancelled
+	client.HSet(context.Background(), jobKey(ids["train"]), "job_state", string(JobStateFailure))
+	scheduler.resolveDependents(ids["train"], JobStateFailure)
+	if state("evaluate") != JobStateCancelled {
+		t.Errorf("Expected evaluate to be cancelled, got %s", state("evaluate"))
+	}
+
+	// a job cannot depend on a job that already failed
+	if _, err := scheduler.EnqueueJob(Job{Type: "retry", DependsOn: []string{ids["train"]}}); !errors.Is(err, ErrDependencyFailed) {
+		t.Errorf("Expected ErrDependencyFailed, got %v", err)
+	}
+	if n := client.Exists(context.Background(), dependentsKey(ids["preprocess"])).Val(); n != 0 {
+		t.Errorf("Expected the dependents of preprocess to be removed")
+	}
+
+	// the sweep resolves a dependency whose event was missed
+	upstream, err := scheduler.EnqueueJob(Job{Type: "preprocess", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+	downstream, err := scheduler.EnqueueJob(Job{Type: "train", Owner: "alice", DependsOn: []string{upstream}})
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+	client.HSet(context.Background(), jobKey(upstream), "job_state", string(JobStateSuccess))
+	scheduler.sweepWaiting()
+	ids["downstream"] = downstream
+	if state("downstream") != JobStateScheduled {
+		t.Errorf("Expected the sweep to schedule the waiting job, got %s", state("downstream"))
+	}
+	if client.SIsMember(context.Background(), WaitingJobsKey, downstream).Val() {
+		t.Errorf("Expected the released job to leave the waiting jobs")
+	}
+
+	// and cancels a job whose dependency has disappeared
+	upstream, err = scheduler.EnqueueJob(Job{Type: "preprocess", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+	orphan, err := scheduler.EnqueueJob(Job{Type: "train", Owner: "alice", DependsOn: []string{upstream}})
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+	client.Del(context.Background(), jobKey(upstream))
+	scheduler.sweepWaiting()
+	ids["orphan"] = orphan
+	if state("orphan") != JobStateCancelled {
+		t.Errorf("Expected the sweep to cancel the orphaned job, got %s", state("orphan"))
+	}
+}
+
+func TestScheduler_EnqueueJobOnce(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+
+	job := Job{Type: "train", RequiredGPU: "AMD", Owner: "alice"}
+	first, replayed, err := scheduler.EnqueueJobOnce(job, "key-1", "fp-1")
+	if err != nil || replayed {
+		t.Fatalf("Expected a new job, got %q, %v, %v", first, replayed, err)
+	}
+	again, replayed, err := scheduler.EnqueueJobOnce(job, "key-1", "fp-1")
+	if err != nil || !replayed || again != first {
+		t.Errorf("Expected the retry to return %s, got %q, %v, %v", first, again, replayed, err)
+	}
+	if _, _, err := scheduler.EnqueueJobOnce(job, "key-1", "fp-2"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Expected ErrIdempotencyKeyReused, got %v", err)
+	}
+
+	job.Owner = "bob"
+	other, replayed, err := scheduler.EnqueueJobOnce(job, "key-1", "fp-1")
+	if err != nil || replayed || other == first {
+		t.Errorf("Expected keys to be scoped to their owner, got %q, %v, %v", other, replayed, err)
+	}
+
+	if n := client.ZCard(context.Background(), JobIndexKey).Val(); n != 2 {
+		t.Errorf("Expected 2 jobs, found %d", n)
+	}
+}
+
+func TestScheduler_KeepsCancelledJobs(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+	registry := NewStatusRegistry(client, log)
+
+	jobID, err := scheduler.EnqueueJob(Job{Type: "train", RequiredGPU: "AMD", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+	if err := scheduler.Cancel(jobID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	// the job finished just as it was cancelled
+	scheduler.handleEventMessage(redis.XMessage{ID: "0-1", Values: map[string]interface{}{
+		"job_id":    jobID,
+		"state":     string(JobStateSuccess),
+		"timestamp": time.Now().Format(time.RFC3339),
+	}})
+
+	job, err := registry.GetJobStatus(jobID)
+	if err != nil {
+		t.Fatalf("GetJobStatus failed: %v", err)
+	}
+	if job.JobState != JobStateCancelled {
+		t.Errorf("Expected the job to stay cancelled, got %s", job.JobState)
+	}
+}
+
+func TestJobLogs_WriteAndRead(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	client.FlushDB(context.Background())
+
+	stdout := &jobLogWriter{client: client, ctx: context.Background(), log: log, jobID: "job_1", stream: "stdout"}
+	stderr := &jobLogWriter{client: client, ctx: context.Background(), log: log, jobID: "job_1", stream: "stderr"}
+	stdout.Write([]byte("epoch 1\r\nepo"))
+	stderr.Write([]byte("warning\n"))
+	stdout.Write([]byte("ch 2\ndone"))
+	stdout.Flush()
+
+	lines, err := readJobLogs(context.Background(), client, "job_1", "0", 2, -1)
+	if err != nil {
+		t.Fatalf("Failed to read logs: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Line != "epoch 1" || lines[1].Line != "warning" || lines[1].Stream != "stderr" {
+		t.Fatalf("Unexpected first lines: %+v", lines)
+	}
+	rest, err := readJobLogs(context.Background(), client, "job_1", lines[1].ID, 10, -1)
+	if err != nil {
+		t.Fatalf("Failed to read logs: %v", err)
+	}
+	if len(rest) != 2 || rest[0].Line != "epoch 2" || rest[1].Line != "done" {
+		t.Fatalf("Unexpected remaining lines: %+v", rest)
+	}
+
+	more, err := readJobLogs(context.Background(), client, "job_1", rest[len(rest)-1].ID, 10, -1)
+	if err != nil || len(more) != 0 {
+		t.Errorf("Expected no more lines, got %+v, %v", more, err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"mist/docker"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -24,10 +26,11 @@ func TestJobEnqueueAndSupervisor(t *testing.T) {
 
 	// Scheduler
 	scheduler := NewScheduler(redisAddr, log)
+	scheduler.Start()
 	defer scheduler.Close()
 
 	// Supervisor
-	supervisor := NewSupervisor(redisAddr, "test_worker_001", "AMD", log)
+	supervisor := NewSupervisor(redisAddr, "test_worker_001", "AMD", log, WithRuntime(newFakeRuntime(nil)))
 	if err := supervisor.Start(); err != nil {
 		t.Fatalf("Failed to start supervisor: %v", err)
 	}
@@ -43,3 +46,160 @@ func TestJobEnqueueAndSupervisor(t *testing.T) {
 
 	time.Sleep(3 * time.Second)
 }
+
+func TestSupervisorReclaimsStuckJob(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	defer scheduler.Close()
+
+	supervisor := NewSupervisor(redisAddr, "test_worker_live", "", log, WithRuntime(newFakeRuntime(nil)), WithClaimIdleTimeout(300*time.Millisecond))
+	if err := supervisor.createConsumerGroup(); err != nil {
+		t.Fatalf("Failed to create consumer group: %v", err)
+	}
+
+	if _, err := scheduler.Enqueue("test_job_type", "", map[string]interface{}{"task": 0}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	scheduler.dispatchPending()
+
+	// deliver the job to a consumer that never acknowledges it
+	if err := client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    ConsumerGroup,
+		Consumer: "test_worker_dead",
+		Streams:  []string{StreamName, ">"},
+		Count:    1,
+	}).Err(); err != nil {
+		t.Fatalf("Failed to read job as dead consumer: %v", err)
+	}
+
+	if err := supervisor.Start(); err != nil {
+		t.Fatalf("Failed to start supervisor: %v", err)
+	}
+	defer supervisor.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		pending, err := client.XPendingExt(context.Background(), &redis.XPendingExtArgs{
+			Stream:   StreamName,
+			Group:    ConsumerGroup,
+			Start:    "-",
+			End:      "+",
+			Count:    10,
+			Consumer: "test_worker_dead",
+		}).Result()
+		if err == nil && len(pending) == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("stuck job was not reclaimed from dead consumer")
+}
+
+func TestSupervisorConcurrency(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	supervisor := NewSupervisor("localhost:6379", "test_worker_pool", "AMD", log, WithRuntime(newFakeRuntime(nil)), WithConcurrency(4))
+	defer supervisor.redisClient.Close()
+
+	if cap(supervisor.slots) != supervisor.concurrency {
+		t.Errorf("Expected %d worker slots, got %d", supervisor.concurrency, cap(supervisor.slots))
+	}
+	if supervisor.concurrency > supervisor.runtime.ContainerLimit() {
+		t.Errorf("Concurrency %d exceeds container limit %d", supervisor.concurrency, supervisor.runtime.ContainerLimit())
+	}
+
+	// the slot pool bounds how many jobs run at once
+	for i := 0; i < supervisor.concurrency; i++ {
+		if !supervisor.acquireSlot(context.Background()) {
+			t.Fatalf("Failed to acquire slot %d", i)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if supervisor.acquireSlot(ctx) {
+		t.Error("Acquired more slots than the concurrency level")
+	}
+}
+
+func TestSupervisorStopWaitsForJobs(t *testing.T) {
+	redisAddr := "localhost:6379"
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	client.FlushDB(context.Background())
+
+	scheduler := NewScheduler(redisAddr, log)
+	scheduler.Start()
+	defer scheduler.Close()
+
+	// every container runs until release is closed
+	release := make(chan struct{})
+	rt := newFakeRuntime(func(docker.ContainerSpec) fakeRun {
+		return fakeRun{Block: true, Until: release}
+	})
+	const n = 3
+	supervisor := NewSupervisor(redisAddr, "test_worker_drain", "", log, WithRuntime(rt), WithConcurrency(n))
+	if err := supervisor.Start(); err != nil {
+		t.Fatalf("Failed to start supervisor: %v", err)
+	}
+
+	var jobIDs []string
+	for i := 0; i < n; i++ {
+		jobID, err := scheduler.Enqueue("test_job_type", "", map[string]interface{}{"args": []interface{}{"true"}})
+		if err != nil {
+			t.Fatalf("Failed to enqueue job %d: %v", i, err)
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for rt.startedCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d running containers, got %d", n, rt.startedCount())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		supervisor.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while jobs were still running")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop did not return after the jobs finished")
+	}
+
+	for _, jobID := range jobIDs {
+		state, err := client.HGet(context.Background(), jobKey(jobID), "job_state").Result()
+		if err != nil {
+			t.Fatalf("Failed to read state of job %s: %v", jobID, err)
+		}
+		if state != string(JobStateSuccess) {
+			t.Errorf("Expected job %s to succeed before Stop returned, got %s", jobID, state)
+		}
+	}
+}
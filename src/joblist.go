@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultJobListLimit = 50
+	MaxJobListLimit     = 500
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// JobFilter selects the jobs returned by ListJobs. Zero values match
+// everything.
+type JobFilter struct {
+	States      []JobState
+	Type        string
+	RequiredGPU string
+	Owner       string
+	Since       time.Time // created at or after
+	Until       time.Time // created at or before
+	Limit       int
+	Cursor      string // opaque cursor from a previous page
+	Ascending   bool   // oldest first; newest first by default
+}
+
+func (f JobFilter) matches(job *Job) bool {
+	if len(f.States) > 0 {
+		found := false
+		for _, state := range f.States {
+			if job.JobState == state {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Type != "" && job.Type != f.Type {
+		return false
+	}
+	if f.RequiredGPU != "" && !strings.EqualFold(job.RequiredGPU, f.RequiredGPU) {
+		return false
+	}
+	if f.Owner != "" && job.Owner != f.Owner {
+		return false
+	}
+	return true
+}
+
+// jobCursor marks the last job of a page: its position in the index and its
+// ID to break ties between jobs created in the same millisecond.
+type jobCursor struct {
+	score int64
+	jobID string
+}
+
+func encodeJobCursor(c jobCursor) string {
+	raw := fmt.Sprintf("%d:%s", c.score, c.jobID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeJobCursor(s string) (jobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return jobCursor{}, ErrInvalidCursor
+	}
+	scoreStr, jobID, ok := strings.Cut(string(raw), ":")
+	if !ok || jobID == "" {
+		return jobCursor{}, ErrInvalidCursor
+	}
+	score, err := strconv.ParseInt(scoreStr, 10, 64)
+	if err != nil {
+		return jobCursor{}, ErrInvalidCursor
+	}
+	return jobCursor{score: score, jobID: jobID}, nil
+}
+
+// ListJobs returns the jobs matching filter ordered by creation time, and the
+// cursor of the next page, empty when there are no more jobs.
+func (sr *StatusRegistry) ListJobs(filter JobFilter) ([]Job, string, error) {
+	ctx := context.Background()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultJobListLimit
+	}
+	if limit > MaxJobListLimit {
+		limit = MaxJobListLimit
+	}
+
+	min, max := "-inf", "+inf"
+	if !filter.Since.IsZero() {
+		min = strconv.FormatInt(filter.Since.UnixMilli(), 10)
+	}
+	if !filter.Until.IsZero() {
+		max = strconv.FormatInt(filter.Until.UnixMilli(), 10)
+	}
+
+	var after *jobCursor
+	if filter.Cursor != "" {
+		c, err := decodeJobCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &c
+		// resume at the cursor's score; ties are skipped below
+		if filter.Ascending {
+			min = strconv.FormatInt(c.score, 10)
+		} else {
+			max = strconv.FormatInt(c.score, 10)
+		}
+	}
+
+	// Filters are applied to the job records, so scan the index in batches
+	// until the page is full or the index is exhausted.
+	batch := int64(limit * 2)
+	jobs := make([]Job, 0, limit)
+	var offset int64
+	for {
+		entries, err := sr.redisClient.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+			Key:     JobIndexKey,
+			Start:   min,
+			Stop:    max,
+			ByScore: true,
+			Rev:     !filter.Ascending,
+			Offset:  offset,
+			Count:   batch,
+		}).Result()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read job index: %w", err)
+		}
+		offset += int64(len(entries))
+
+		pipe := sr.redisClient.Pipeline()
+		cmds := make([]*redis.MapStringStringCmd, len(entries))
+		for i, entry := range entries {
+			cmds[i] = pipe.HGetAll(ctx, jobKey(entry.Member.(string)))
+		}
+		if len(entries) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return nil, "", fmt.Errorf("failed to read jobs: %w", err)
+			}
+		}
+
+		for i, entry := range entries {
+			jobID := entry.Member.(string)
+			score := int64(entry.Score)
+			if after != nil && score == after.score {
+				if filter.Ascending && jobID <= after.jobID {
+					continue
+				}
+				if !filter.Ascending && jobID >= after.jobID {
+					continue
+				}
+			}
+
+			fields := cmds[i].Val()
+			if len(fields) == 0 {
+				continue // record expired or was removed
+			}
+			job, err := jobFromFields(jobID, fields)
+			if err != nil {
+				sr.log.Error("skipping unreadable job record", "job_id", jobID, "error", err)
+				continue
+			}
+			if !filter.matches(job) {
+				continue
+			}
+
+			jobs = append(jobs, *job)
+			if len(jobs) == limit {
+				return jobs, encodeJobCursor(jobCursor{score: score, jobID: jobID}), nil
+			}
+		}
+
+		if int64(len(entries)) < batch {
+			return jobs, "", nil
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestJobCursorRoundTrip(t *testing.T) {
+	want := jobCursor{score: 1700000000123, jobID: "job_1700000000123_42"}
+	got, err := decodeJobCursor(encodeJobCursor(want))
+	if err != nil {
+		t.Fatalf("decodeJobCursor failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	for _, bad := range []string{"not base64!", encodeJobCursor(jobCursor{}), "MTIz"} {
+		if _, err := decodeJobCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestParseJobFilter(t *testing.T) {
+	query := url.Values{
+		"state": {"Scheduled, InProgress"},
+		"gpu":   {"AMD"},
+		"owner": {"alice"},
+		"since": {"2025-01-02T03:04:05Z"},
+		"limit": {"10"},
+		"order": {"asc"},
+	}
+	filter, err := parseJobFilter(query)
+	if err != nil {
+		t.Fatalf("parseJobFilter failed: %v", err)
+	}
+	if len(filter.States) != 2 || filter.States[0] != JobStateScheduled || filter.States[1] != JobStateInProgress {
+		t.Errorf("Unexpected states %v", filter.States)
+	}
+	if filter.RequiredGPU != "AMD" || filter.Owner != "alice" || filter.Limit != 10 || !filter.Ascending {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+	if !filter.Since.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected since %v", filter.Since)
+	}
+
+	for _, bad := range []url.Values{
+		{"since": {"yesterday"}},
+		{"limit": {"-1"}},
+		{"order": {"sideways"}},
+	} {
+		if _, err := parseJobFilter(bad); err == nil {
+			t.Errorf("Expected error for %v", bad)
+		}
+	}
+}
+
+func TestJobFilterMatches(t *testing.T) {
+	job := &Job{Type: "train", RequiredGPU: "AMD", Owner: "alice", JobState: JobStateInProgress}
+
+	cases := []struct {
+		filter JobFilter
+		want   bool
+	}{
+		{JobFilter{}, true},
+		{JobFilter{States: []JobState{JobStateScheduled, JobStateInProgress}}, true},
+		{JobFilter{States: []JobState{JobStateSuccess}}, false},
+		{JobFilter{RequiredGPU: "amd"}, true},
+		{JobFilter{Type: "eval"}, false},
+		{JobFilter{Owner: "bob"}, false},
+	}
+	for _, c := range cases {
+		if got := c.filter.matches(job); got != c.want {
+			t.Errorf("matches(%+v) = %v, want %v", c.filter, got, c.want)
+		}
+	}
+}
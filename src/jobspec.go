@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"mist/docker"
+
+	"github.com/docker/go-units"
+)
+
+// JobSpec describes the workload a job runs. It is carried in the job payload
+// alongside any other user supplied fields.
+type JobSpec struct {
+	Image   string            `json:"image,omitempty"`
+	Command []string          `json:"command,omitempty"` // overrides the image entrypoint
+	Args    []string          `json:"args,omitempty"`    // overrides the image command
+	Env     map[string]string `json:"env,omitempty"`
+
+	// OutputDir is a directory in the container, e.g. /data/outputs, whose
+	// files are kept as the job's artifacts once the container exits.
+	OutputDir string `json:"output_dir,omitempty"`
+
+	// Inputs are uploaded files copied into /data before the container starts.
+	Inputs []JobInput `json:"inputs,omitempty"`
+
+	// Resources overrides the limits of the job's accelerator profile.
+	Resources JobResources `json:"resources,omitempty"`
+}
+
+// JobResources limits what a job may use. Sizes are written as for docker run,
+// e.g. "16g" or "512m", and the timeout as a duration, e.g. "90m". A job that
+// runs out of memory or time is killed and fails without being retried.
+type JobResources struct {
+	CPUs      float64 `json:"cpus,omitempty"`
+	Memory    string  `json:"memory,omitempty"`
+	ShmSize   string  `json:"shm_size,omitempty"`
+	PidsLimit int64   `json:"pids_limit,omitempty"`
+	Timeout   string  `json:"timeout,omitempty"` // wall-clock time from the container start
+}
+
+func (r JobResources) validate() error {
+	if r.CPUs < 0 {
+		return fmt.Errorf("cpus must not be negative")
+	}
+	if r.PidsLimit < 0 {
+		return fmt.Errorf("pids_limit must not be negative")
+	}
+	if _, err := parseSize(r.Memory); err != nil {
+		return fmt.Errorf("memory: %w", err)
+	}
+	if _, err := parseSize(r.ShmSize); err != nil {
+		return fmt.Errorf("shm_size: %w", err)
+	}
+	if _, err := r.timeout(); err != nil {
+		return fmt.Errorf("timeout: %w", err)
+	}
+	return nil
+}
+
+// withDefaults fills the limits r leaves out from def.
+func (r JobResources) withDefaults(def JobResources) JobResources {
+	if r.CPUs == 0 {
+		r.CPUs = def.CPUs
+	}
+	if r.Memory == "" {
+		r.Memory = def.Memory
+	}
+	if r.ShmSize == "" {
+		r.ShmSize = def.ShmSize
+	}
+	if r.PidsLimit == 0 {
+		r.PidsLimit = def.PidsLimit
+	}
+	if r.Timeout == "" {
+		r.Timeout = def.Timeout
+	}
+	return r
+}
+
+// timeout returns the job's time limit, or 0 if it has none.
+func (r JobResources) timeout() (time.Duration, error) {
+	if r.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(r.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s is not positive", r.Timeout)
+	}
+	return d, nil
+}
+
+// parseSize returns the size s in bytes, or 0 if s is empty.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("%s is not positive", s)
+	}
+	return n, nil
+}
+
+// acceleratorProfile holds the container defaults used for an accelerator type.
+type acceleratorProfile struct {
+	Image   string
+	Runtime string
+	Devices []string
+
+	// Resources are the limits of jobs that do not set their own. GPU jobs
+	// get a large /dev/shm since data loader workers share batches through it.
+	Resources JobResources
+}
+
+var acceleratorProfiles = map[string]acceleratorProfile{
+	"CPU": {
+		Image: CPUImage, Runtime: CPURuntime,
+		Resources: JobResources{CPUs: 2, Memory: "4g", ShmSize: "256m", PidsLimit: 1024, Timeout: "12h"},
+	},
+	"AMD": {
+		Image: "pytorch-rocm", Runtime: "runc", Devices: []string{"/dev/kfd", "/dev/dri"},
+		Resources: JobResources{CPUs: 8, Memory: "32g", ShmSize: "8g", PidsLimit: 4096, Timeout: "24h"},
+	},
+	"NVIDIA": {
+		Image: "pytorch-cuda", Runtime: "nvidia",
+		Resources: JobResources{CPUs: 8, Memory: "32g", ShmSize: "8g", PidsLimit: 4096, Timeout: "24h"},
+	},
+	"TT": {
+		Image:   "ghcr.io/tenstorrent/tt-metal/tt-metalium/ubuntu-22.04-dev-amd64:latest",
+		Runtime: "runc", Devices: []string{"/dev/tenstorrent"},
+		Resources: JobResources{CPUs: 8, Memory: "32g", ShmSize: "2g", PidsLimit: 4096, Timeout: "24h"},
+	},
+}
+
+// parseJobSpec extracts the workload description from a job payload.
+func parseJobSpec(payload map[string]interface{}) (JobSpec, error) {
+	var spec JobSpec
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return spec, fmt.Errorf("marshal payload: %w", err)
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("invalid job spec: %w", err)
+	}
+	if spec.OutputDir != "" && !path.IsAbs(spec.OutputDir) {
+		return spec, fmt.Errorf("invalid job spec: output_dir %q is not an absolute path", spec.OutputDir)
+	}
+	for _, in := range spec.Inputs {
+		if err := in.validate(); err != nil {
+			return spec, fmt.Errorf("invalid job spec: %w", err)
+		}
+	}
+	if err := spec.Resources.validate(); err != nil {
+		return spec, fmt.Errorf("invalid job spec: resources: %w", err)
+	}
+	return spec, nil
+}
+
+// profileFor returns the accelerator profile of the job's GPU type.
+func profileFor(job Job) (string, acceleratorProfile, error) {
+	gpu := strings.ToUpper(job.RequiredGPU)
+	if gpu == "" {
+		gpu = "CPU"
+	}
+	profile, ok := acceleratorProfiles[gpu]
+	if !ok {
+		return gpu, acceleratorProfile{}, fmt.Errorf("unsupported gpu type %q", job.RequiredGPU)
+	}
+	return gpu, profile, nil
+}
+
+// jobTimeout returns how long the job's container may run, or 0 for no limit.
+func jobTimeout(job Job, spec JobSpec) (time.Duration, error) {
+	_, profile, err := profileFor(job)
+	if err != nil {
+		return 0, err
+	}
+	return spec.Resources.withDefaults(profile.Resources).timeout()
+}
+
+// containerSpecFor builds the container to run for job, filling in the image,
+// runtime, devices and resource limits of the job's accelerator where the spec
+// leaves them out.
+func containerSpecFor(job Job, spec JobSpec, volumeName string) (docker.ContainerSpec, error) {
+	gpu, profile, err := profileFor(job)
+	if err != nil {
+		return docker.ContainerSpec{}, err
+	}
+
+	image := spec.Image
+	if image == "" {
+		image = profile.Image
+	}
+	if image == "" {
+		return docker.ContainerSpec{}, fmt.Errorf("no image specified for %s job", gpu)
+	}
+
+	env := make([]string, 0, len(spec.Env))
+	for k, v := range spec.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+
+	res := spec.Resources.withDefaults(profile.Resources)
+	memory, err := parseSize(res.Memory)
+	if err != nil {
+		return docker.ContainerSpec{}, fmt.Errorf("memory: %w", err)
+	}
+	shmSize, err := parseSize(res.ShmSize)
+	if err != nil {
+		return docker.ContainerSpec{}, fmt.Errorf("shm_size: %w", err)
+	}
+
+	return docker.ContainerSpec{
+		Image:      image,
+		Runtime:    profile.Runtime,
+		Volume:     volumeName,
+		Entrypoint: spec.Command,
+		Cmd:        spec.Args,
+		Env:        env,
+		Devices:    profile.Devices,
+		NanoCPUs:   int64(res.CPUs * 1e9),
+		Memory:     memory,
+		ShmSize:    shmSize,
+		PidsLimit:  res.PidsLimit,
+	}, nil
+}
+
+// specError reports that a job cannot run as submitted, e.g. because its spec
+// is invalid, its GPU type is unsupported or it has no image. It is not
+// retried, since another attempt would fail the same way.
+type specError struct {
+	err error
+}
+
+func (e *specError) Error() string {
+	return e.err.Error()
+}
+
+func (e *specError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseJobSpec(t *testing.T) {
+	payload := map[string]interface{}{
+		"image":   "python:3.12",
+		"command": []interface{}{"python"},
+		"args":    []interface{}{"train.py", "--epochs", "3"},
+		"env":     map[string]interface{}{"SEED": "42"},
+		"task":    "ignored",
+	}
+
+	spec, err := parseJobSpec(payload)
+	if err != nil {
+		t.Fatalf("parseJobSpec failed: %v", err)
+	}
+	if spec.Image != "python:3.12" {
+		t.Errorf("Image: got %q", spec.Image)
+	}
+	if !reflect.DeepEqual(spec.Args, []string{"train.py", "--epochs", "3"}) {
+		t.Errorf("Args: got %v", spec.Args)
+	}
+	if spec.Env["SEED"] != "42" {
+		t.Errorf("Env: got %v", spec.Env)
+	}
+
+	if _, err := parseJobSpec(map[string]interface{}{"command": "not a list"}); err == nil {
+		t.Error("expected error for malformed command")
+	}
+	if _, err := parseJobSpec(map[string]interface{}{"output_dir": "outputs"}); err == nil {
+		t.Error("expected error for relative output_dir")
+	}
+	for _, res := range []map[string]interface{}{
+		{"memory": "lots"},
+		{"shm_size": "-1g"},
+		{"cpus": -1},
+		{"timeout": "2 hours"},
+		{"timeout": "-5m"},
+	} {
+		if _, err := parseJobSpec(map[string]interface{}{"resources": res}); err == nil {
+			t.Errorf("expected error for resources %v", res)
+		}
+	}
+}
+
+func TestContainerSpecFor(t *testing.T) {
+	spec := JobSpec{Args: []string{"echo", "hi"}, Env: map[string]string{"B": "2", "A": "1"}}
+
+	cs, err := containerSpecFor(Job{ID: "job_1"}, spec, "job_1_data")
+	if err != nil {
+		t.Fatalf("containerSpecFor failed: %v", err)
+	}
+	if cs.Image != CPUImage || cs.Runtime != CPURuntime {
+		t.Errorf("expected CPU defaults, got image=%q runtime=%q", cs.Image, cs.Runtime)
+	}
+	if !reflect.DeepEqual(cs.Env, []string{"A=1", "B=2"}) {
+		t.Errorf("Env: got %v", cs.Env)
+	}
+
+	cs, err = containerSpecFor(Job{ID: "job_2", RequiredGPU: "amd"}, JobSpec{Image: "custom"}, "job_2_data")
+	if err != nil {
+		t.Fatalf("containerSpecFor failed: %v", err)
+	}
+	if cs.Image != "custom" || len(cs.Devices) == 0 {
+		t.Errorf("expected custom image with AMD devices, got %+v", cs)
+	}
+
+	cs, err = containerSpecFor(Job{ID: "job_3", RequiredGPU: "TT"}, JobSpec{}, "job_3_data")
+	if err != nil || cs.Image != acceleratorProfiles["TT"].Image {
+		t.Errorf("expected the default TT image, got %q, %v", cs.Image, err)
+	}
+	if _, err := containerSpecFor(Job{ID: "job_4", RequiredGPU: "FPGA"}, spec, "job_4_data"); err == nil {
+		t.Error("expected error for unsupported gpu type")
+	}
+}
+
+func TestContainerSpecForResources(t *testing.T) {
+	cs, err := containerSpecFor(Job{ID: "job_1"}, JobSpec{}, "job_1_data")
+	if err != nil {
+		t.Fatalf("containerSpecFor failed: %v", err)
+	}
+	if cs.NanoCPUs != 2e9 || cs.Memory != 4<<30 || cs.ShmSize != 256<<20 || cs.PidsLimit != 1024 {
+		t.Errorf("expected CPU profile limits, got %+v", cs)
+	}
+
+	spec := JobSpec{Image: "custom", Resources: JobResources{CPUs: 0.5, Memory: "512m"}}
+	cs, err = containerSpecFor(Job{ID: "job_2", RequiredGPU: "AMD"}, spec, "job_2_data")
+	if err != nil {
+		t.Fatalf("containerSpecFor failed: %v", err)
+	}
+	if cs.NanoCPUs != 5e8 || cs.Memory != 512<<20 {
+		t.Errorf("expected limits from spec, got cpus=%d memory=%d", cs.NanoCPUs, cs.Memory)
+	}
+	if cs.ShmSize != 8<<30 {
+		t.Errorf("expected AMD profile shm size, got %d", cs.ShmSize)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	timeout, err := jobTimeout(Job{RequiredGPU: "TT"}, JobSpec{})
+	if err != nil || timeout != 24*time.Hour {
+		t.Errorf("default TT timeout: got %v, %v", timeout, err)
+	}
+	timeout, err = jobTimeout(Job{}, JobSpec{Resources: JobResources{Timeout: "90m"}})
+	if err != nil || timeout != 90*time.Minute {
+		t.Errorf("timeout from spec: got %v, %v", timeout, err)
+	}
+	if _, err := jobTimeout(Job{RequiredGPU: "FPGA"}, JobSpec{}); err == nil {
+		t.Error("expected error for unsupported gpu type")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Job records live in a Redis hash per job. The hash is the single source of
+// truth for a job: the scheduler creates it, supervisors and the event
+// listener update individual fields, and StatusRegistry reads it back.
+
+// jobKey returns the key of the hash holding the record of jobID.
+func jobKey(jobID string) string {
+	return fmt.Sprintf("job:%s", jobID)
+}
+
+// jobFields flattens job into the fields stored in its hash. Optional fields
+// that are unset are left out.
+func jobFields(job Job) (map[string]interface{}, error) {
+	payloadJSON, err := json.Marshal(job.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		"type":         job.Type,
+		"payload":      string(payloadJSON),
+		"retries":      job.Retries,
+		"created":      job.Created.Format(time.RFC3339Nano),
+		"required_gpu": job.RequiredGPU,
+		"owner":        job.Owner,
+		"team":         job.Team,
+		"priority":     string(job.Priority),
+		"job_state":    string(job.JobState),
+	}
+
+	if job.Schedule != "" {
+		fields["schedule_id"] = job.Schedule
+	}
+	if len(job.DependsOn) > 0 {
+		fields["depends_on"] = strings.Join(job.DependsOn, ",")
+	}
+	if job.Workflow != "" {
+		fields["workflow_id"] = job.Workflow
+	}
+	if job.RunAfter != nil {
+		fields["run_after"] = job.RunAfter.Format(time.RFC3339Nano)
+	}
+	if job.ConsumerID != nil {
+		fields["consumer_id"] = *job.ConsumerID
+	}
+	if job.TimeAssigned != nil {
+		fields["time_assigned"] = job.TimeAssigned.Format(time.RFC3339Nano)
+	}
+	if job.TimeStarted != nil {
+		fields["time_started"] = job.TimeStarted.Format(time.RFC3339Nano)
+	}
+	if job.TimeCompleted != nil {
+		fields["time_completed"] = job.TimeCompleted.Format(time.RFC3339Nano)
+	}
+	if job.Result != nil {
+		resultJSON, err := json.Marshal(job.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal job result: %w", err)
+		}
+		fields["result"] = string(resultJSON)
+	}
+	if job.Error != nil {
+		fields["error"] = *job.Error
+	}
+
+	return fields, nil
+}
+
+// jobFromFields rebuilds a Job from the fields of its hash.
+func jobFromFields(jobID string, fields map[string]string) (*Job, error) {
+	job := &Job{
+		ID:          jobID,
+		Type:        fields["type"],
+		RequiredGPU: fields["required_gpu"],
+		Owner:       fields["owner"],
+		Team:        fields["team"],
+		Priority:    JobPriority(fields["priority"]),
+		Schedule:    fields["schedule_id"],
+		Workflow:    fields["workflow_id"],
+		JobState:    JobState(fields["job_state"]),
+	}
+
+	if v := fields["payload"]; v != "" {
+		if err := json.Unmarshal([]byte(v), &job.Payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job payload: %w", err)
+		}
+	}
+	if v := fields["retries"]; v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retries %q: %w", v, err)
+		}
+		job.Retries = retries
+	}
+	if v := fields["created"]; v != "" {
+		created, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created time %q: %w", v, err)
+		}
+		job.Created = created
+	}
+
+	if v, ok := fields["consumer_id"]; ok && v != "" {
+		job.ConsumerID = &v
+	}
+	if v := fields["depends_on"]; v != "" {
+		job.DependsOn = strings.Split(v, ",")
+	}
+	job.RunAfter = parseOptionalTime(fields["run_after"])
+	job.TimeAssigned = parseOptionalTime(fields["time_assigned"])
+	job.TimeStarted = parseOptionalTime(fields["time_started"])
+	job.TimeCompleted = parseOptionalTime(fields["time_completed"])
+
+	if v := fields["result"]; v != "" {
+		if err := json.Unmarshal([]byte(v), &job.Result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job result: %w", err)
+		}
+	}
+	if v, ok := fields["error"]; ok && v != "" {
+		job.Error = &v
+	}
+
+	return job, nil
+}
+
+func parseOptionalTime(v string) *time.Time {
+	if v == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
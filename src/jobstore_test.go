@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJobFieldsRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	consumer := "worker_1"
+	errMsg := "container exited with code 1"
+
+	job := Job{
+		ID:            "job_1",
+		Type:          "train",
+		Payload:       map[string]interface{}{"image": "pytorch-cpu"},
+		Retries:       2,
+		Created:       now,
+		RequiredGPU:   "AMD",
+		JobState:      JobStateFailure,
+		ConsumerID:    &consumer,
+		TimeAssigned:  &now,
+		TimeStarted:   &now,
+		TimeCompleted: &now,
+		Result:        map[string]interface{}{"exit_code": float64(1)},
+		Error:         &errMsg,
+	}
+
+	fields, err := jobFields(job)
+	if err != nil {
+		t.Fatalf("jobFields failed: %v", err)
+	}
+
+	// redis hands every field back as a string
+	raw := make(map[string]string, len(fields))
+	for k, v := range fields {
+		raw[k] = fmt.Sprint(v)
+	}
+
+	got, err := jobFromFields(job.ID, raw)
+	if err != nil {
+		t.Fatalf("jobFromFields failed: %v", err)
+	}
+
+	if got.Type != job.Type || got.Retries != job.Retries || got.RequiredGPU != job.RequiredGPU || got.JobState != job.JobState {
+		t.Errorf("basic fields differ: got %+v", got)
+	}
+	if !got.Created.Equal(job.Created) {
+		t.Errorf("Created: got %v want %v", got.Created, job.Created)
+	}
+	if got.ConsumerID == nil || *got.ConsumerID != consumer {
+		t.Errorf("ConsumerID: got %v", got.ConsumerID)
+	}
+	if got.TimeCompleted == nil || !got.TimeCompleted.Equal(now) {
+		t.Errorf("TimeCompleted: got %v", got.TimeCompleted)
+	}
+	if got.Result["exit_code"] != float64(1) {
+		t.Errorf("Result: got %v", got.Result)
+	}
+	if got.Error == nil || *got.Error != errMsg {
+		t.Errorf("Error: got %v", got.Error)
+	}
+	if got.Payload["image"] != "pytorch-cpu" {
+		t.Errorf("Payload: got %v", got.Payload)
+	}
+}
+
+func TestJobFromFieldsOptional(t *testing.T) {
+	got, err := jobFromFields("job_2", map[string]string{
+		"type":      "eval",
+		"job_state": string(JobStateScheduled),
+		"created":   time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("jobFromFields failed: %v", err)
+	}
+	if got.ConsumerID != nil || got.TimeStarted != nil || got.Result != nil || got.Error != nil {
+		t.Errorf("expected unset optional fields, got %+v", got)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// What a job's container writes to stdout and stderr is kept in the Redis
+// stream job:<id>:logs, one entry per line, so it can be read while the job
+// runs and after its container is gone. The stream is capped at
+// JobLogMaxLines and expires JobLogTTL after the job's last attempt.
+
+const (
+	JobLogMaxLines   = 10000
+	JobLogMaxLineLen = 16 * 1024
+	JobLogTTL        = 7 * 24 * time.Hour
+	logDrainTimeout  = 10 * time.Second
+	logPollInterval  = 2 * time.Second
+	logBatchSize     = 500
+)
+
+func jobLogKey(jobID string) string {
+	return fmt.Sprintf("job:%s:logs", jobID)
+}
+
+// JobLogLine is one line a job wrote to stdout or stderr.
+type JobLogLine struct {
+	ID     string // ID of the stream entry, to read on from
+	Stream string // stdout or stderr
+	Line   string
+}
+
+// jobLogWriter appends what is written to it to a job's log, splitting it
+// into lines. Lines longer than JobLogMaxLineLen are split too.
+type jobLogWriter struct {
+	client *redis.Client
+	ctx    context.Context
+	log    *slog.Logger
+	jobID  string
+	stream string
+	buf    []byte
+}
+
+func (w *jobLogWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	rest := w.buf
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		w.append(rest[:i])
+		rest = rest[i+1:]
+	}
+	for len(rest) >= JobLogMaxLineLen {
+		w.append(rest[:JobLogMaxLineLen])
+		rest = rest[JobLogMaxLineLen:]
+	}
+	w.buf = append(w.buf[:0], rest...)
+	// never fail: the container's output must be drained either way
+	return len(p), nil
+}
+
+// Flush appends a last line not terminated by a newline.
+func (w *jobLogWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.append(w.buf)
+		w.buf = w.buf[:0]
+	}
+}
+
+func (w *jobLogWriter) append(line []byte) {
+	if len(line) > JobLogMaxLineLen {
+		line = line[:JobLogMaxLineLen]
+	}
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if err := w.client.XAdd(w.ctx, &redis.XAddArgs{
+		Stream: jobLogKey(w.jobID),
+		MaxLen: JobLogMaxLines,
+		Approx: true,
+		Values: map[string]interface{}{"stream": w.stream, "line": string(line)},
+	}).Err(); err != nil {
+		w.log.Warn("failed to store job log line", "job_id", w.jobID, "error", err)
+	}
+}
+
+// captureLogs stores the output of the job's container in the job's log until
+// the container exits. The returned channel is closed once all of it is stored.
+func (s *Supervisor) captureLogs(jobID, containerID string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stdout := &jobLogWriter{client: s.redisClient, ctx: s.workCtx, log: s.log, jobID: jobID, stream: "stdout"}
+		stderr := &jobLogWriter{client: s.redisClient, ctx: s.workCtx, log: s.log, jobID: jobID, stream: "stderr"}
+
+		if err := s.runtime.StreamLogs(s.workCtx, containerID, stdout, stderr); err != nil {
+			s.log.Warn("failed to capture job logs", "job_id", jobID, "container_id", containerID, "error", err)
+		}
+		stdout.Flush()
+		stderr.Flush()
+
+		if err := s.redisClient.Expire(s.workCtx, jobLogKey(jobID), JobLogTTL).Err(); err != nil {
+			s.log.Warn("failed to set job log expiry", "job_id", jobID, "error", err)
+		}
+	}()
+	return done
+}
+
+// readJobLogs returns up to count lines of the job's log after the entry with
+// ID after ("0" for the start). If there are none it waits up to block for
+// more; a negative block returns at once.
+func readJobLogs(ctx context.Context, client *redis.Client, jobID, after string, count int64, block time.Duration) ([]JobLogLine, error) {
+	streams, err := client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{jobLogKey(jobID), after},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []JobLogLine
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			stdStream, _ := msg.Values["stream"].(string)
+			line, _ := msg.Values["line"].(string)
+			lines = append(lines, JobLogLine{ID: msg.ID, Stream: stdStream, Line: line})
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Username string
+	Role     string
+	Team     string
+}
+
+func (id *Identity) IsAdmin() bool {
+	return id.Role == RoleAdmin
+}
+
+// CanAccess reports whether the caller may see or change job: admins can
+// access every job, users only their own.
+func (id *Identity) CanAccess(job *Job) bool {
+	return id.IsAdmin() || job.Owner == id.Username
+}
+
+type identityKey struct{}
+
+func withIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// identityFrom returns the caller attached to ctx by requireAuth.
+func identityFrom(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// requireAuth rejects requests without a valid bearer access token and
+// attaches the caller's identity to the request context.
+func (a *App) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mist"`)
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := a.auth.VerifyAccessToken(strings.TrimSpace(token))
+		if errors.Is(err, ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mist", error="invalid_token"`)
+			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			a.log.Error("failed to verify access token", "err", err)
+			http.Error(w, "authentication failed", http.StatusInternalServerError)
+			return
+		}
+
+		id := &Identity{Username: claims.Subject, Role: claims.Role, Team: claims.Team}
+		next(w, r.WithContext(withIdentity(r.Context(), id)))
+	}
+}
+
+// requireAdmin is requireAuth for endpoints only admins may use.
+func (a *App) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return a.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if id, _ := identityFrom(r.Context()); !id.IsAdmin() {
+			http.Error(w, "admin role required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRequireAuth(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	app := &App{auth: NewAuthenticator(nil, []byte("secret"), log), log: log}
+
+	var seen *Identity
+	handler := app.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = identityFrom(r.Context())
+	})
+
+	now := time.Now()
+	valid, _ := app.auth.signToken(Claims{Subject: "alice", Role: RoleUser, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+	expired, _ := app.auth.signToken(Claims{Subject: "alice", Role: RoleUser, ExpiresAt: now.Add(-time.Minute).Unix()})
+
+	cases := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + valid, http.StatusUnauthorized},
+		{"expired", "Bearer " + expired, http.StatusUnauthorized},
+		{"garbage", "Bearer not.a.token", http.StatusUnauthorized},
+		{"valid", "Bearer " + valid, http.StatusOK},
+	}
+	for _, c := range cases {
+		seen = nil
+		req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != c.want {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.want, rec.Code)
+		}
+		if c.want == http.StatusOK && (seen == nil || seen.Username != "alice") {
+			t.Errorf("%s: expected alice in the request context, got %+v", c.name, seen)
+		}
+		if c.want != http.StatusOK && seen != nil {
+			t.Errorf("%s: handler should not have run", c.name)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	app := &App{auth: NewAuthenticator(nil, []byte("secret"), log), log: log}
+	handler := app.requireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+
+	for role, want := range map[string]int{RoleUser: http.StatusForbidden, RoleAdmin: http.StatusOK} {
+		token, _ := app.auth.signToken(Claims{Subject: "bob", Role: role, ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			t.Errorf("role %s: expected status %d, got %d", role, want, rec.Code)
+		}
+	}
+}
+
+func TestIdentityCanAccess(t *testing.T) {
+	job := &Job{ID: "job_1", Owner: "alice"}
+	if !(&Identity{Username: "alice", Role: RoleUser}).CanAccess(job) {
+		t.Error("Expected the owner to access the job")
+	}
+	if (&Identity{Username: "bob", Role: RoleUser}).CanAccess(job) {
+		t.Error("Expected another user to be denied")
+	}
+	if !(&Identity{Username: "root", Role: RoleAdmin}).CanAccess(job) {
+		t.Error("Expected an admin to access the job")
+	}
+}
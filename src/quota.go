@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Quotas limit how much of the cluster a user or a team can hold. A policy
+// is stored per subject in quota:<kind>:<name>; subjects without one fall
+// back to quota:default:<kind>, and a zero limit means unlimited.
+//
+// Usage is tracked by the scheduler as job states change: the IDs of
+// scheduled and running jobs are kept in sets per subject, and the GPU time
+// of finished jobs is recorded in a ledger sorted by completion time.
+
+const (
+	QuotaKindUser = "user"
+	QuotaKindTeam = "team"
+
+	GPUHoursWindow = 7 * 24 * time.Hour
+
+	// how often Reserve retries when concurrent submissions change the
+	// usage it checked
+	maxReserveAttempts = 10
+)
+
+var (
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrInvalidQuota  = errors.New("invalid quota")
+)
+
+type QuotaPolicy struct {
+	MaxConcurrentJobs int     `json:"max_concurrent_jobs"` // released to a supervisor or running
+	MaxQueuedJobs     int     `json:"max_queued_jobs"`     // waiting or scheduled
+	GPUHoursPerWeek   float64 `json:"gpu_hours_per_week"`  // over the last 7 days
+}
+
+func (p QuotaPolicy) validate() error {
+	if p.MaxConcurrentJobs < 0 || p.MaxQueuedJobs < 0 || p.GPUHoursPerWeek < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidQuota)
+	}
+	return nil
+}
+
+type QuotaUsage struct {
+	QueuedJobs  int     `json:"queued_jobs"`
+	RunningJobs int     `json:"running_jobs"`
+	GPUHours    float64 `json:"gpu_hours"`
+}
+
+// QuotaError explains which limit a job submission ran into.
+type QuotaError struct {
+	Kind   string
+	Name   string
+	Limit  string
+	Reason string
+	Status int // HTTP status to answer with
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota exceeded for %s %s: %s", e.Limit, e.Kind, e.Name, e.Reason)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+func quotaPolicyKey(kind, name string) string {
+	return fmt.Sprintf("quota:%s:%s", kind, name)
+}
+
+func quotaDefaultKey(kind string) string {
+	return fmt.Sprintf("quota:default:%s", kind)
+}
+
+func queuedJobsKey(kind, name string) string {
+	return fmt.Sprintf("quota:queued:%s:%s", kind, name)
+}
+
+func runningJobsKey(kind, name string) string {
+	return fmt.Sprintf("quota:running:%s:%s", kind, name)
+}
+
+func gpuUsageKey(kind, name string) string {
+	return fmt.Sprintf("usage:%s:%s", kind, name)
+}
+
+// isGPUJob reports whether a job requiring gpuType counts towards GPU hours.
+func isGPUJob(gpuType string) bool {
+	return gpuType != "" && !strings.EqualFold(gpuType, "CPU")
+}
+
+// quotaSubjects returns the (kind, name) pairs a job of owner and team is
+// accounted to.
+func quotaSubjects(owner, team string) [][2]string {
+	var subjects [][2]string
+	if owner != "" {
+		subjects = append(subjects, [2]string{QuotaKindUser, owner})
+	}
+	if team != "" {
+		subjects = append(subjects, [2]string{QuotaKindTeam, team})
+	}
+	return subjects
+}
+
+type QuotaManager struct {
+	client *redis.Client
+	log    *slog.Logger
+}
+
+func NewQuotaManager(client *redis.Client, log *slog.Logger) *QuotaManager {
+	return &QuotaManager{
+		client: client,
+		log:    log,
+	}
+}
+
+// GetPolicy returns the policy of a subject, or the default policy of its
+// kind if it has none.
+func (q *QuotaManager) GetPolicy(kind, name string) (QuotaPolicy, error) {
+	ctx := context.Background()
+	for _, key := range []string{quotaPolicyKey(kind, name), quotaDefaultKey(kind)} {
+		fields, err := q.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return QuotaPolicy{}, fmt.Errorf("failed to get quota policy: %w", err)
+		}
+		if len(fields) > 0 {
+			return policyFromFields(fields), nil
+		}
+	}
+	return QuotaPolicy{}, nil
+}
+
+// SetPolicy stores the policy of a subject. An empty name sets the default
+// policy of the kind.
+func (q *QuotaManager) SetPolicy(kind, name string, policy QuotaPolicy) error {
+	if kind != QuotaKindUser && kind != QuotaKindTeam {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidQuota, kind)
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	key := quotaDefaultKey(kind)
+	if name != "" {
+		key = quotaPolicyKey(kind, name)
+	}
+	if err := q.client.HSet(context.Background(), key,
+		"max_concurrent_jobs", policy.MaxConcurrentJobs,
+		"max_queued_jobs", policy.MaxQueuedJobs,
+		"gpu_hours_per_week", policy.GPUHoursPerWeek,
+	).Err(); err != nil {
+		return fmt.Errorf("failed to set quota policy: %w", err)
+	}
+
+	q.log.Info("quota policy updated", "kind", kind, "name", name, "policy", policy)
+	return nil
+}
+
+func policyFromFields(fields map[string]string) QuotaPolicy {
+	var policy QuotaPolicy
+	policy.MaxConcurrentJobs, _ = strconv.Atoi(fields["max_concurrent_jobs"])
+	policy.MaxQueuedJobs, _ = strconv.Atoi(fields["max_queued_jobs"])
+	policy.GPUHoursPerWeek, _ = strconv.ParseFloat(fields["gpu_hours_per_week"], 64)
+	return policy
+}
+
+// Usage returns the current usage of a subject.
+func (q *QuotaManager) Usage(kind, name string) (QuotaUsage, error) {
+	ctx := context.Background()
+	since := time.Now().Add(-GPUHoursWindow)
+
+	pipe := q.client.Pipeline()
+	queued := pipe.SCard(ctx, queuedJobsKey(kind, name))
+	running := pipe.SCard(ctx, runningJobsKey(kind, name))
+	// entries older than the window are no longer needed
+	pipe.ZRemRangeByScore(ctx, gpuUsageKey(kind, name), "-inf", "("+strconv.FormatInt(since.Unix(), 10))
+	ledger := pipe.ZRange(ctx, gpuUsageKey(kind, name), 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return QuotaUsage{}, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	usage := QuotaUsage{
+		QueuedJobs:  int(queued.Val()),
+		RunningJobs: int(running.Val()),
+	}
+	var seconds float64
+	for _, entry := range ledger.Val() {
+		seconds += ledgerSeconds(entry)
+	}
+	usage.GPUHours = seconds / 3600
+	return usage, nil
+}
+
+// Check returns a *QuotaError if owner or their team cannot submit another
+// job requiring gpuType.
+func (q *QuotaManager) Check(owner, team, gpuType string) error {
+	for _, subject := range quotaSubjects(owner, team) {
+		kind, name := subject[0], subject[1]
+		policy, err := q.GetPolicy(kind, name)
+		if err != nil {
+			return err
+		}
+		if policy == (QuotaPolicy{}) {
+			continue
+		}
+		usage, err := q.Usage(kind, name)
+		if err != nil {
+			return err
+		}
+		if err := checkQuota(kind, name, policy, usage, gpuType, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reserve checks that owner and their team can submit jobs and counts them as
+// queued in the same transaction, so concurrent submissions cannot both take
+// the last slot. The jobs need their IDs already; enqueueing them adds them to
+// the same sets again. Returns a *QuotaError if a limit would be exceeded.
+// Release undoes the reservation of jobs that were not enqueued after all.
+func (q *QuotaManager) Reserve(owner, team string, jobs []Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	var gpuType string
+	ids := make([]interface{}, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+		if isGPUJob(job.RequiredGPU) {
+			gpuType = job.RequiredGPU
+		}
+	}
+
+	subjects := quotaSubjects(owner, team)
+	policies := make([]QuotaPolicy, len(subjects))
+	var keys []string
+	for i, subject := range subjects {
+		kind, name := subject[0], subject[1]
+		policy, err := q.GetPolicy(kind, name)
+		if err != nil {
+			return err
+		}
+		policies[i] = policy
+		keys = append(keys, queuedJobsKey(kind, name), runningJobsKey(kind, name))
+	}
+
+	reserve := func(tx *redis.Tx) error {
+		for i, subject := range subjects {
+			kind, name := subject[0], subject[1]
+			if policies[i] == (QuotaPolicy{}) {
+				continue
+			}
+			usage, err := q.Usage(kind, name)
+			if err != nil {
+				return err
+			}
+			if err := checkQuota(kind, name, policies[i], usage, gpuType, len(jobs)); err != nil {
+				return err
+			}
+		}
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, subject := range subjects {
+				pipe.SAdd(ctx, queuedJobsKey(subject[0], subject[1]), ids...)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxReserveAttempts; attempt++ {
+		if err := q.client.Watch(ctx, reserve, keys...); !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to reserve quota: usage kept changing")
+}
+
+// Release stops counting jobs reserved with Reserve that were not enqueued.
+func (q *QuotaManager) Release(owner, team string, jobIDs ...string) {
+	if len(jobIDs) == 0 {
+		return
+	}
+	ctx := context.Background()
+	ids := make([]interface{}, len(jobIDs))
+	for i, id := range jobIDs {
+		ids[i] = id
+	}
+
+	pipe := q.client.Pipeline()
+	for _, subject := range quotaSubjects(owner, team) {
+		pipe.SRem(ctx, queuedJobsKey(subject[0], subject[1]), ids...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		q.log.Error("failed to release quota reservation", "owner", owner, "jobs", jobIDs, "error", err)
+	}
+}
+
+// checkQuota returns a *QuotaError if a subject with policy and usage cannot
+// take n more jobs, requiring gpuType if any of them needs a GPU. The limit on
+// concurrent jobs is not checked here; it holds jobs back at dispatch instead.
+func checkQuota(kind, name string, policy QuotaPolicy, usage QuotaUsage, gpuType string, n int) error {
+	if policy.MaxQueuedJobs > 0 && usage.QueuedJobs+n > policy.MaxQueuedJobs {
+		return &QuotaError{
+			Kind: kind, Name: name, Limit: "queued jobs",
+			Reason: fmt.Sprintf("%d of %d jobs queued", usage.QueuedJobs, policy.MaxQueuedJobs),
+			Status: http.StatusTooManyRequests,
+		}
+	}
+	if isGPUJob(gpuType) && policy.GPUHoursPerWeek > 0 && usage.GPUHours >= policy.GPUHoursPerWeek {
+		return &QuotaError{
+			Kind: kind, Name: name, Limit: "GPU hours",
+			Reason: fmt.Sprintf("%.1f of %.1f GPU hours used in the last 7 days", usage.GPUHours, policy.GPUHoursPerWeek),
+			Status: http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+// CheckConcurrent returns a *QuotaError if owner or their team already have
+// as many jobs running as their policy allows, in which case the dispatcher
+// holds their next job back.
+func (q *QuotaManager) CheckConcurrent(owner, team string) error {
+	ctx := context.Background()
+	for _, subject := range quotaSubjects(owner, team) {
+		kind, name := subject[0], subject[1]
+		policy, err := q.GetPolicy(kind, name)
+		if err != nil {
+			return err
+		}
+		if policy.MaxConcurrentJobs == 0 {
+			continue
+		}
+		running, err := q.client.SCard(ctx, runningJobsKey(kind, name)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get quota usage: %w", err)
+		}
+		if err := checkConcurrent(kind, name, policy, int(running)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkConcurrent(kind, name string, policy QuotaPolicy, running int) error {
+	if policy.MaxConcurrentJobs > 0 && running >= policy.MaxConcurrentJobs {
+		return &QuotaError{
+			Kind: kind, Name: name, Limit: "concurrent jobs",
+			Reason: fmt.Sprintf("%d of %d jobs running", running, policy.MaxConcurrentJobs),
+			Status: http.StatusTooManyRequests,
+		}
+	}
+	return nil
+}
+
+// ledgerEntry is the member recorded in the GPU usage ledger for a finished
+// job. Including the job ID keeps it unique, so recording it again is a no-op.
+func ledgerEntry(jobID string, seconds float64) string {
+	return fmt.Sprintf("%s:%s", jobID, strconv.FormatFloat(seconds, 'f', 3, 64))
+}
+
+func ledgerSeconds(entry string) float64 {
+	i := strings.LastIndex(entry, ":")
+	if i < 0 {
+		return 0
+	}
+	seconds, _ := strconv.ParseFloat(entry[i+1:], 64)
+	return seconds
+}
+
+// trackQuotaUsage queues the updates of a job's usage after it moved to
+// state. Every update is idempotent so replayed events do no harm.
+func trackQuotaUsage(ctx context.Context, pipe redis.Pipeliner, jobID string, fields map[string]string, state JobState, completed time.Time) {
+	for _, subject := range quotaSubjects(fields["owner"], fields["team"]) {
+		kind, name := subject[0], subject[1]
+		queued, running := queuedJobsKey(kind, name), runningJobsKey(kind, name)
+
+		switch {
+		case state == JobStateScheduled || state == JobStateWaiting:
+			pipe.SRem(ctx, running, jobID)
+			pipe.SAdd(ctx, queued, jobID)
+		case state == JobStateInProgress:
+			pipe.SRem(ctx, queued, jobID)
+			pipe.SAdd(ctx, running, jobID)
+		case isTerminalState(state):
+			pipe.SRem(ctx, queued, jobID)
+			pipe.SRem(ctx, running, jobID)
+
+			started := parseOptionalTime(fields["time_started"])
+			if started == nil || !isGPUJob(fields["required_gpu"]) {
+				continue
+			}
+			seconds := completed.Sub(*started).Seconds()
+			if seconds < 0 {
+				seconds = 0
+			}
+			pipe.ZAdd(ctx, gpuUsageKey(kind, name), redis.Z{
+				Score:  float64(completed.Unix()),
+				Member: ledgerEntry(jobID, seconds),
+			})
+		}
+	}
+}
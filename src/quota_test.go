@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCheckQuota(t *testing.T) {
+	policy := QuotaPolicy{MaxConcurrentJobs: 4, MaxQueuedJobs: 2, GPUHoursPerWeek: 10}
+
+	cases := []struct {
+		name   string
+		usage  QuotaUsage
+		gpu    string
+		status int // 0 if the job is allowed
+	}{
+		{"within limits", QuotaUsage{QueuedJobs: 1, RunningJobs: 1, GPUHours: 5}, "AMD", 0},
+		{"running jobs do not count as queued", QuotaUsage{QueuedJobs: 1, RunningJobs: 4}, "AMD", 0},
+		{"too many queued", QuotaUsage{QueuedJobs: 2}, "AMD", http.StatusTooManyRequests},
+		{"out of gpu hours", QuotaUsage{GPUHours: 10}, "TT", http.StatusForbidden},
+		{"cpu jobs ignore gpu hours", QuotaUsage{GPUHours: 10}, "CPU", 0},
+	}
+	for _, c := range cases {
+		err := checkQuota(QuotaKindUser, "alice", policy, c.usage, c.gpu, 1)
+		if c.status == 0 {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", c.name, err)
+			}
+			continue
+		}
+		var quotaErr *QuotaError
+		if !errors.As(err, &quotaErr) || quotaErr.Status != c.status {
+			t.Errorf("%s: expected a quota error with status %d, got %v", c.name, c.status, err)
+		}
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("%s: expected ErrQuotaExceeded, got %v", c.name, err)
+		}
+	}
+
+	if err := checkQuota(QuotaKindTeam, "club", QuotaPolicy{}, QuotaUsage{QueuedJobs: 100, GPUHours: 1000}, "AMD", 1); err != nil {
+		t.Errorf("Expected an empty policy to be unlimited, got %v", err)
+	}
+
+	// a batch must fit as a whole
+	if err := checkQuota(QuotaKindUser, "alice", policy, QuotaUsage{RunningJobs: 1}, "", 2); err != nil {
+		t.Errorf("Expected 2 more jobs to fit, got %v", err)
+	}
+	if err := checkQuota(QuotaKindUser, "alice", policy, QuotaUsage{QueuedJobs: 1}, "", 2); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected 2 more jobs to exceed the queue limit, got %v", err)
+	}
+}
+
+func TestQuotaLimitsDiffer(t *testing.T) {
+	// more jobs may wait than run at once
+	policy := QuotaPolicy{MaxConcurrentJobs: 1, MaxQueuedJobs: 3}
+
+	if err := checkQuota(QuotaKindUser, "alice", policy, QuotaUsage{QueuedJobs: 2, RunningJobs: 1}, "", 1); err != nil {
+		t.Errorf("Expected a third queued job to be allowed while one runs, got %v", err)
+	}
+	if err := checkQuota(QuotaKindUser, "alice", policy, QuotaUsage{QueuedJobs: 3}, "", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected a fourth queued job to be rejected, got %v", err)
+	}
+
+	if err := checkConcurrent(QuotaKindUser, "alice", policy, 0); err != nil {
+		t.Errorf("Expected a job to start with none running, got %v", err)
+	}
+	var quotaErr *QuotaError
+	if err := checkConcurrent(QuotaKindUser, "alice", policy, 1); !errors.As(err, &quotaErr) || quotaErr.Limit != "concurrent jobs" {
+		t.Errorf("Expected the concurrent job limit to hold the job back, got %v", err)
+	}
+}
+
+func TestLedgerEntry(t *testing.T) {
+	entry := ledgerEntry("job_1", 5400)
+	if got := ledgerSeconds(entry); got != 5400 {
+		t.Errorf("Expected 5400 seconds from %q, got %v", entry, got)
+	}
+	if got := ledgerSeconds("garbage"); got != 0 {
+		t.Errorf("Expected 0 seconds for a malformed entry, got %v", got)
+	}
+}
+
+func TestQuotaSubjects(t *testing.T) {
+	if got := quotaSubjects("alice", "club"); len(got) != 2 || got[0] != [2]string{QuotaKindUser, "alice"} || got[1] != [2]string{QuotaKindTeam, "club"} {
+		t.Errorf("Unexpected subjects %v", got)
+	}
+	if got := quotaSubjects("alice", ""); len(got) != 1 {
+		t.Errorf("Expected only the user without a team, got %v", got)
+	}
+}
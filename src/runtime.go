@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"mist/docker"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// Runtime runs the workloads of jobs in containers, each with a volume
+// mounted at /data. *docker.DockerMgr is the runtime used in production.
+type Runtime interface {
+	// ContainerLimit returns how many containers the runtime runs at once.
+	ContainerLimit() int
+
+	CreateVolume(volumeName string) (volume.Volume, error)
+	RemoveVolume(volumeName string, force bool) error
+
+	// RunContainer starts a container and returns its ID.
+	RunContainer(spec docker.ContainerSpec) (string, error)
+	// WaitContainer blocks until the container exits and returns its exit code.
+	WaitContainer(containerID string) (int64, error)
+	// InspectExit returns how the exited container stopped.
+	InspectExit(containerID string) (docker.ExitInfo, error)
+	StopContainer(containerID string) error
+	RemoveContainer(containerID string) error
+
+	// StreamLogs copies the container's output to stdout and stderr from its
+	// start until it exits or ctx is cancelled.
+	StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error
+	// CopyFromContainer returns a tar archive of path in the container, with
+	// entries named relative to the parent of path.
+	CopyFromContainer(containerID, path string) (io.ReadCloser, error)
+}
+
+var _ Runtime = (*docker.DockerMgr)(nil)
+
+// Container and volume limits of the runtimes created from the environment.
+const (
+	DefaultContainerLimit = 10
+	DefaultVolumeLimit    = 100
+)
+
+// runtimeFromEnv returns the runtime named by MIST_RUNTIME: "docker", the
+// default, or "process" to run jobs as plain processes on hosts without Docker.
+// Docker must be reachable; there is no silent fallback to another runtime.
+func runtimeFromEnv(log *slog.Logger) (Runtime, error) {
+	switch name := os.Getenv("MIST_RUNTIME"); name {
+	case "", "docker":
+		cli, err := client.NewClientWithOpts(client.FromEnv)
+		if err != nil {
+			return nil, fmt.Errorf("docker client: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := cli.Ping(ctx); err != nil {
+			cli.Close()
+			return nil, fmt.Errorf("docker daemon not reachable, set MIST_RUNTIME=process to run jobs without it: %w", err)
+		}
+		log.Info("Docker client initialized for container execution")
+		return docker.NewDockerMgr(cli, DefaultContainerLimit, DefaultVolumeLimit), nil
+	case "process":
+		dir := os.Getenv("MIST_PROCESS_DIR")
+		if dir == "" {
+			dir = DefaultProcessDir
+		}
+		log.Warn("running jobs as local processes without isolation", "dir", dir)
+		return NewProcessRuntime(dir, DefaultContainerLimit), nil
+	default:
+		return nil, fmt.Errorf("unknown MIST_RUNTIME %q, want docker or process", name)
+	}
+}
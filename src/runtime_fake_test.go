@@ -0,0 +1,244 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"mist/docker"
+
+	"github.com/docker/docker/api/types/volume"
+)
+
+// fakeRuntime is an in-memory Runtime for tests. Containers behave as the
+// run function says: by default they exit with code 0 at once.
+type fakeRuntime struct {
+	run func(spec docker.ContainerSpec) fakeRun
+
+	mu         sync.Mutex
+	volumes    map[string]bool
+	containers map[string]*fakeContainer
+	started    []*fakeContainer
+}
+
+// fakeRun is what a fake container does.
+type fakeRun struct {
+	ExitCode  int64
+	OOMKilled bool
+	Stdout    string
+	Stderr    string
+	Files     map[string]string // left behind in the container, by absolute path
+	Block     bool              // keep running until stopped
+	Until     <-chan struct{}   // with Block, also exit with code 0 once closed
+}
+
+type fakeContainer struct {
+	spec    docker.ContainerSpec
+	run     fakeRun
+	inputs  map[string]string // files unpacked from spec.Inputs, by path under /data
+	stopped bool
+	done    chan struct{}
+	exit    docker.ExitInfo
+}
+
+func newFakeRuntime(run func(spec docker.ContainerSpec) fakeRun) *fakeRuntime {
+	if run == nil {
+		run = func(docker.ContainerSpec) fakeRun { return fakeRun{} }
+	}
+	return &fakeRuntime{
+		run:        run,
+		volumes:    make(map[string]bool),
+		containers: make(map[string]*fakeContainer),
+	}
+}
+
+func (rt *fakeRuntime) ContainerLimit() int {
+	return DefaultContainerLimit
+}
+
+func (rt *fakeRuntime) CreateVolume(volumeName string) (volume.Volume, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.volumes[volumeName] = true
+	return volume.Volume{Name: volumeName}, nil
+}
+
+func (rt *fakeRuntime) RemoveVolume(volumeName string, force bool) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if !rt.volumes[volumeName] {
+		return fmt.Errorf("volume %s does not exist", volumeName)
+	}
+	delete(rt.volumes, volumeName)
+	return nil
+}
+
+func (rt *fakeRuntime) RunContainer(spec docker.ContainerSpec) (string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if !rt.volumes[spec.Volume] {
+		return "", fmt.Errorf("volume %s does not exist", spec.Volume)
+	}
+
+	c := &fakeContainer{spec: spec, run: rt.run(spec), inputs: make(map[string]string), done: make(chan struct{})}
+	if spec.Inputs != nil {
+		tr := tar.NewReader(spec.Inputs)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", fmt.Errorf("copy inputs: %w", err)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return "", fmt.Errorf("copy inputs: %w", err)
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				c.inputs[hdr.Name] = string(data)
+			}
+		}
+	}
+	if !c.run.Block {
+		c.exit = docker.ExitInfo{ExitCode: c.run.ExitCode, OOMKilled: c.run.OOMKilled}
+		close(c.done)
+	}
+
+	if c.run.Block && c.run.Until != nil {
+		go func() {
+			select {
+			case <-c.run.Until:
+				rt.mu.Lock()
+				rt.exit(c, docker.ExitInfo{})
+				rt.mu.Unlock()
+			case <-c.done:
+			}
+		}()
+	}
+
+	id := fmt.Sprintf("fake_%d", len(rt.started))
+	rt.containers[id] = c
+	rt.started = append(rt.started, c)
+	return id, nil
+}
+
+// exit makes a blocked container exit with info. rt.mu must be held.
+func (rt *fakeRuntime) exit(c *fakeContainer, info docker.ExitInfo) {
+	select {
+	case <-c.done:
+	default:
+		c.exit = info
+		close(c.done)
+	}
+}
+
+func (rt *fakeRuntime) container(containerID string) (*fakeContainer, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	c, ok := rt.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+	return c, nil
+}
+
+func (rt *fakeRuntime) WaitContainer(containerID string) (int64, error) {
+	c, err := rt.container(containerID)
+	if err != nil {
+		return -1, err
+	}
+	<-c.done
+	return c.exit.ExitCode, nil
+}
+
+func (rt *fakeRuntime) InspectExit(containerID string) (docker.ExitInfo, error) {
+	c, err := rt.container(containerID)
+	if err != nil {
+		return docker.ExitInfo{}, err
+	}
+	<-c.done
+	return c.exit, nil
+}
+
+// StopContainer makes a running container exit as if it got SIGTERM.
+func (rt *fakeRuntime) StopContainer(containerID string) error {
+	c, err := rt.container(containerID)
+	if err != nil {
+		return err
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if !c.stopped {
+		c.stopped = true
+		rt.exit(c, docker.ExitInfo{ExitCode: 143})
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) RemoveContainer(containerID string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.containers[containerID]; !ok {
+		return fmt.Errorf("no such container: %s", containerID)
+	}
+	delete(rt.containers, containerID)
+	return nil
+}
+
+func (rt *fakeRuntime) StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	c, err := rt.container(containerID)
+	if err != nil {
+		return err
+	}
+	io.WriteString(stdout, c.run.Stdout)
+	io.WriteString(stderr, c.run.Stderr)
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func (rt *fakeRuntime) CopyFromContainer(containerID, dir string) (io.ReadCloser, error) {
+	c, err := rt.container(containerID)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		for name, content := range c.run.Files {
+			rel, ok := strings.CutPrefix(name, dir+"/")
+			if !ok {
+				continue
+			}
+			hdr := &tar.Header{Typeflag: tar.TypeReg, Name: path.Join(path.Base(dir), rel), Mode: 0o644, Size: int64(len(content))}
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			io.WriteString(tw, content)
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	return pr, nil
+}
+
+// startedCount returns how many containers have been started.
+func (rt *fakeRuntime) startedCount() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.started)
+}
+
+// leftovers returns how many containers and volumes were not removed.
+func (rt *fakeRuntime) leftovers() (containers, volumes int) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.containers), len(rt.volumes)
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"mist/docker"
+
+	"github.com/docker/docker/api/types/volume"
+)
+
+// ProcessRuntime runs the workloads of jobs as plain processes on the
+// supervisor's host, for machines without Docker. It isolates nothing: the
+// image, runtime, devices and resource limits of a container spec are
+// ignored, except for the timeout, which the supervisor enforces itself.
+// Volumes are directories under the runtime's directory. A process runs in
+// its volume with MIST_DATA_DIR pointing there, and arguments under /data are
+// rewritten to the volume, so "python /data/train.py" runs the uploaded script.
+// Processes do not inherit the supervisor's environment, which holds secrets
+// such as MIST_AUTH_SECRET, only the variables in processEnvAllowlist.
+
+const DefaultProcessDir = "runs"
+
+const (
+	// how long a stopped process has to exit before it is killed
+	processStopTimeout = 10 * time.Second
+	// how often the output files of a running process are checked for more
+	processLogPollInterval = 100 * time.Millisecond
+)
+
+// processEnvAllowlist names the variables of the supervisor's environment
+// that job processes get.
+var processEnvAllowlist = []string{"PATH", "HOME", "LANG", "TMPDIR"}
+
+// ProcessRuntime implements Runtime with local processes.
+type ProcessRuntime struct {
+	dir            string
+	containerLimit int
+	procs          map[string]*process
+	mu             sync.Mutex
+}
+
+// process is a started workload, known by its container ID.
+type process struct {
+	cmd    *exec.Cmd
+	volume string // directory the process runs in
+	dir    string // holds its stdout and stderr
+	done   chan struct{}
+	exit   docker.ExitInfo // set once done is closed
+}
+
+func NewProcessRuntime(dir string, containerLimit int) *ProcessRuntime {
+	return &ProcessRuntime{
+		dir:            dir,
+		containerLimit: containerLimit,
+		procs:          make(map[string]*process),
+	}
+}
+
+// ContainerLimit returns the maximum number of processes the runtime runs at once.
+func (rt *ProcessRuntime) ContainerLimit() int {
+	return rt.containerLimit
+}
+
+func (rt *ProcessRuntime) volumePath(volumeName string) (string, error) {
+	if volumeName == "" || volumeName == "." || volumeName == ".." || filepath.Base(volumeName) != volumeName {
+		return "", fmt.Errorf("invalid volume name %q", volumeName)
+	}
+	return filepath.Abs(filepath.Join(rt.dir, "volumes", volumeName))
+}
+
+// CreateVolume creates the directory of a volume.
+func (rt *ProcessRuntime) CreateVolume(volumeName string) (volume.Volume, error) {
+	dir, err := rt.volumePath(volumeName)
+	if err != nil {
+		return volume.Volume{}, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return volume.Volume{}, err
+	}
+	return volume.Volume{Name: volumeName, Driver: "local", Mountpoint: dir}, nil
+}
+
+// RemoveVolume deletes the directory of a volume. Unless force is true it
+// fails while a process runs in it.
+func (rt *ProcessRuntime) RemoveVolume(volumeName string, force bool) error {
+	dir, err := rt.volumePath(volumeName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("volume %s does not exist", volumeName)
+	}
+	if !force {
+		rt.mu.Lock()
+		for _, p := range rt.procs {
+			if p.volume == dir && !p.exited() {
+				rt.mu.Unlock()
+				return fmt.Errorf("volume %s is in use", volumeName)
+			}
+		}
+		rt.mu.Unlock()
+	}
+	return os.RemoveAll(dir)
+}
+
+// RunContainer starts the entrypoint and command of spec as a process in
+// its volume, after unpacking the spec's inputs there.
+func (rt *ProcessRuntime) RunContainer(spec docker.ContainerSpec) (string, error) {
+	argv := append(append([]string{}, spec.Entrypoint...), spec.Cmd...)
+	if len(argv) == 0 {
+		return "", fmt.Errorf("no command to run: processes have no image to take one from")
+	}
+	data, err := rt.volumePath(spec.Volume)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(data); err != nil {
+		return "", fmt.Errorf("volume %s does not exist", spec.Volume)
+	}
+	// unpacking can take a while, so it is done without holding up other calls
+	if spec.Inputs != nil {
+		if err := unpackTar(data, spec.Inputs); err != nil {
+			return "", fmt.Errorf("copy inputs: %w", err)
+		}
+	}
+	for i, arg := range argv {
+		argv[i] = dataPath(data, arg)
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.procs) >= rt.containerLimit {
+		return "", fmt.Errorf("container limit reached")
+	}
+
+	id := "proc_" + newULID(time.Now())
+	dir := filepath.Join(rt.dir, "processes", id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	stderr, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		stdout.Close()
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = data
+	cmd.Env = append(processEnv(spec.Env), "MIST_DATA_DIR="+data)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		stderr.Close()
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	p := &process{cmd: cmd, volume: data, dir: dir, done: make(chan struct{})}
+	rt.procs[id] = p
+	go func() {
+		err := cmd.Wait()
+		// the workload is over once its first process is; stop what it left behind
+		signalProcessGroup(cmd.Process, syscall.SIGKILL)
+		stdout.Close()
+		stderr.Close()
+		p.exit = processExit(cmd.ProcessState, err)
+		close(p.done)
+	}()
+	return id, nil
+}
+
+// processEnv returns the allowed variables of the supervisor's environment
+// followed by env.
+func processEnv(env []string) []string {
+	var vars []string
+	for _, name := range processEnvAllowlist {
+		if value, ok := os.LookupEnv(name); ok {
+			vars = append(vars, name+"="+value)
+		}
+	}
+	return append(vars, env...)
+}
+
+// dataPath rewrites arg to point into the volume data if it is under /data.
+func dataPath(data, arg string) string {
+	if arg == "/data" {
+		return data
+	}
+	if rest, ok := strings.CutPrefix(arg, "/data/"); ok {
+		return filepath.Join(data, filepath.FromSlash(rest))
+	}
+	return arg
+}
+
+// processExit reports how a process stopped, with the exit code a shell
+// would give it: 128 plus the signal number if it was killed by one.
+func processExit(state *os.ProcessState, err error) docker.ExitInfo {
+	if state == nil {
+		return docker.ExitInfo{ExitCode: -1, Error: err.Error()}
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return docker.ExitInfo{ExitCode: 128 + int64(status.Signal())}
+	}
+	return docker.ExitInfo{ExitCode: int64(state.ExitCode())}
+}
+
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (rt *ProcessRuntime) process(containerID string) (*process, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	p, ok := rt.procs[containerID]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+	return p, nil
+}
+
+// WaitContainer blocks until the process exits and returns its exit code.
+func (rt *ProcessRuntime) WaitContainer(containerID string) (int64, error) {
+	p, err := rt.process(containerID)
+	if err != nil {
+		return -1, err
+	}
+	<-p.done
+	return p.exit.ExitCode, nil
+}
+
+// InspectExit returns how the exited process stopped.
+func (rt *ProcessRuntime) InspectExit(containerID string) (docker.ExitInfo, error) {
+	p, err := rt.process(containerID)
+	if err != nil {
+		return docker.ExitInfo{}, err
+	}
+	if !p.exited() {
+		return docker.ExitInfo{}, fmt.Errorf("container %s is still running", containerID)
+	}
+	return p.exit, nil
+}
+
+// StopContainer asks the process and the processes it started to terminate,
+// and kills them if the process has not exited after processStopTimeout.
+func (rt *ProcessRuntime) StopContainer(containerID string) error {
+	p, err := rt.process(containerID)
+	if err != nil {
+		return err
+	}
+	if p.exited() {
+		return nil
+	}
+	if err := signalProcessGroup(p.cmd.Process, syscall.SIGTERM); err != nil {
+		signalProcessGroup(p.cmd.Process, syscall.SIGKILL)
+	}
+	select {
+	case <-p.done:
+	case <-time.After(processStopTimeout):
+		signalProcessGroup(p.cmd.Process, syscall.SIGKILL)
+		<-p.done
+	}
+	return nil
+}
+
+// RemoveContainer forgets the exited process and deletes its output.
+func (rt *ProcessRuntime) RemoveContainer(containerID string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	p, ok := rt.procs[containerID]
+	if !ok {
+		return fmt.Errorf("no such container: %s", containerID)
+	}
+	if !p.exited() {
+		return fmt.Errorf("container %s is still running", containerID)
+	}
+	delete(rt.procs, containerID)
+	return os.RemoveAll(p.dir)
+}
+
+// StreamLogs copies what the process writes to stdout and stderr to the
+// given writers until it exits or ctx is cancelled.
+func (rt *ProcessRuntime) StreamLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	p, err := rt.process(containerID)
+	if err != nil {
+		return err
+	}
+	outFile, err := os.Open(filepath.Join(p.dir, "stdout"))
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+	errFile, err := os.Open(filepath.Join(p.dir, "stderr"))
+	if err != nil {
+		return err
+	}
+	defer errFile.Close()
+
+	ticker := time.NewTicker(processLogPollInterval)
+	defer ticker.Stop()
+	for {
+		// checked first so that all output written before the exit is copied
+		exited := p.exited()
+		if _, err := io.Copy(stdout, outFile); err != nil {
+			return err
+		}
+		if _, err := io.Copy(stderr, errFile); err != nil {
+			return err
+		}
+		if exited {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.done:
+		case <-ticker.C:
+		}
+	}
+}
+
+// CopyFromContainer returns a tar archive of path, which must be under /data.
+func (rt *ProcessRuntime) CopyFromContainer(containerID, containerPath string) (io.ReadCloser, error) {
+	p, err := rt.process(containerID)
+	if err != nil {
+		return nil, err
+	}
+	clean := path.Clean(containerPath)
+	if clean != "/data" && !strings.HasPrefix(clean, "/data/") {
+		return nil, fmt.Errorf("%s is outside of /data, the only directory a process shares", containerPath)
+	}
+	src := dataPath(p.volume, clean)
+	if _, err := os.Stat(src); err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(packTar(pw, src, path.Base(clean)))
+	}()
+	return pr, nil
+}
+
+// packTar writes the directories and regular files under src to w, named
+// after name like the archives Docker copies out of containers.
+func packTar(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// unpackTar writes the directories and regular files of the tar archive r
+// into dir, refusing entries that would end up outside of it.
+func unpackTar(dir string, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive entry %q is outside of the volume", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		mode := os.FileMode(hdr.Mode) & os.ModePerm
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mist/docker"
+)
+
+func TestProcessRuntimeRunsCommand(t *testing.T) {
+	rt := NewProcessRuntime(t.TempDir(), 2)
+	vol, err := rt.CreateVolume("job_1_data")
+	if err != nil {
+		t.Fatalf("CreateVolume failed: %v", err)
+	}
+
+	var inputs bytes.Buffer
+	tw := tar.NewWriter(&inputs)
+	script := "echo out; echo err >&2; mkdir -p outputs; echo 1 > outputs/result.txt; exit 3\n"
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "scripts/", Mode: 0o777})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "scripts/run.sh", Mode: 0o644, Size: int64(len(script))})
+	io.WriteString(tw, script)
+	tw.Close()
+
+	id, err := rt.RunContainer(docker.ContainerSpec{
+		Volume: "job_1_data",
+		Cmd:    []string{"sh", "/data/scripts/run.sh"},
+		Inputs: &inputs,
+	})
+	if err != nil {
+		t.Fatalf("RunContainer failed: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := rt.StreamLogs(context.Background(), id, &stdout, &stderr); err != nil {
+		t.Fatalf("StreamLogs failed: %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("unexpected output %q and %q", stdout.String(), stderr.String())
+	}
+	if code, err := rt.WaitContainer(id); err != nil || code != 3 {
+		t.Errorf("WaitContainer: got %d, %v", code, err)
+	}
+
+	archive, err := rt.CopyFromContainer(id, "/data/outputs")
+	if err != nil {
+		t.Fatalf("CopyFromContainer failed: %v", err)
+	}
+	defer archive.Close()
+	var names []string
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading archive: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if strings.Join(names, ",") != "outputs/,outputs/result.txt" {
+		t.Errorf("unexpected archive entries %v", names)
+	}
+	if _, err := rt.CopyFromContainer(id, "/etc"); err == nil {
+		t.Error("expected error copying from outside of /data")
+	}
+
+	if err := rt.RemoveContainer(id); err != nil {
+		t.Errorf("RemoveContainer failed: %v", err)
+	}
+	if err := rt.RemoveVolume("job_1_data", false); err != nil {
+		t.Errorf("RemoveVolume failed: %v", err)
+	}
+	if _, err := os.Stat(vol.Mountpoint); !os.IsNotExist(err) {
+		t.Errorf("expected volume directory to be removed, got %v", err)
+	}
+}
+
+func TestProcessRuntimeEnvironment(t *testing.T) {
+	t.Setenv("MIST_AUTH_SECRET", "supervisor-secret")
+	rt := NewProcessRuntime(t.TempDir(), 1)
+	if _, err := rt.CreateVolume("job_1_data"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := rt.RunContainer(docker.ContainerSpec{
+		Volume: "job_1_data",
+		Cmd:    []string{"sh", "-c", `echo "secret=$MIST_AUTH_SECRET seed=$SEED"; test -n "$MIST_DATA_DIR"`},
+		Env:    []string{"SEED=42"},
+	})
+	if err != nil {
+		t.Fatalf("RunContainer failed: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	if err := rt.StreamLogs(context.Background(), id, &stdout, io.Discard); err != nil {
+		t.Fatalf("StreamLogs failed: %v", err)
+	}
+	if got := stdout.String(); got != "secret= seed=42\n" {
+		t.Errorf("unexpected environment %q", got)
+	}
+	if code, _ := rt.WaitContainer(id); code != 0 {
+		t.Errorf("expected MIST_DATA_DIR to be set, exit code %d", code)
+	}
+}
+
+func TestProcessRuntimeStop(t *testing.T) {
+	rt := NewProcessRuntime(t.TempDir(), 2)
+	if _, err := rt.CreateVolume("job_1_data"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := rt.RunContainer(docker.ContainerSpec{Volume: "job_1_data", Cmd: []string{"sleep", "60"}})
+	if err != nil {
+		t.Fatalf("RunContainer failed: %v", err)
+	}
+	if err := rt.RemoveContainer(id); err == nil {
+		t.Error("expected error removing a running process")
+	}
+
+	if err := rt.StopContainer(id); err != nil {
+		t.Fatalf("StopContainer failed: %v", err)
+	}
+	info, err := rt.InspectExit(id)
+	if err != nil {
+		t.Fatalf("InspectExit failed: %v", err)
+	}
+	if st := exitStatus(info, false, "", ""); st.Signal != "SIGTERM" {
+		t.Errorf("expected the process to be terminated, got %+v", info)
+	}
+}
+
+func TestProcessRuntimeStopsChildren(t *testing.T) {
+	rt := NewProcessRuntime(t.TempDir(), 1)
+	vol, err := rt.CreateVolume("job_1_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := rt.RunContainer(docker.ContainerSpec{
+		Volume: "job_1_data",
+		Cmd:    []string{"sh", "-c", "(sleep 1; touch survived) & wait"},
+	})
+	if err != nil {
+		t.Fatalf("RunContainer failed: %v", err)
+	}
+	if err := rt.StopContainer(id); err != nil {
+		t.Fatalf("StopContainer failed: %v", err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(vol.Mountpoint, "survived")); !os.IsNotExist(err) {
+		t.Errorf("a process started by the job outlived it: %v", err)
+	}
+}
+
+func TestProcessRuntimeRejectsBadInput(t *testing.T) {
+	dir := t.TempDir()
+	rt := NewProcessRuntime(dir, 1)
+	if _, err := rt.CreateVolume("../escape"); err == nil {
+		t.Error("expected error for a volume name with a path")
+	}
+	if _, err := rt.CreateVolume("job_1_data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.RunContainer(docker.ContainerSpec{Volume: "job_1_data"}); err == nil {
+		t.Error("expected error without a command")
+	}
+
+	var inputs bytes.Buffer
+	tw := tar.NewWriter(&inputs)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../../evil.sh", Mode: 0o644})
+	tw.Close()
+	if _, err := rt.RunContainer(docker.ContainerSpec{Volume: "job_1_data", Cmd: []string{"true"}, Inputs: &inputs}); err == nil {
+		t.Error("expected error for an input outside of the volume")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.sh")); !os.IsNotExist(err) {
+		t.Errorf("input was written outside of the volume: %v", err)
+	}
+}
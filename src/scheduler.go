@@ -2,19 +2,22 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log/slog"
+	"sync"
 	"time"
-	"errors"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type Scheduler struct {
-	client *redis.Client
-	ctx    context.Context
-	log    *slog.Logger
+	client         *redis.Client
+	quotas         *QuotaManager
+	dispatchWindow int
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+	log            *slog.Logger
 }
 
 func NewScheduler(redisAddr string, log *slog.Logger) *Scheduler {
@@ -22,82 +25,200 @@ func NewScheduler(redisAddr string, log *slog.Logger) *Scheduler {
 		Addr: redisAddr,
 	})
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Scheduler{
-		client: client,
-		ctx:    context.Background(),
-		log:    log,
+		client:         client,
+		quotas:         NewQuotaManager(client, log),
+		dispatchWindow: DefaultDispatchWindow,
+		ctx:            ctx,
+		cancel:         cancel,
+		log:            log,
 	}
 }
 
 func (s *Scheduler) Enqueue(jobType string, requiredGPU string, payload map[string]interface{}) (string, error) {
-	// create a new job
-	job := Job{
-		ID:          generateJobID(),
+	return s.EnqueueJob(Job{
 		Type:        jobType,
 		Payload:     payload,
-		Retries:     0,
-		Created:     time.Now(),
 		RequiredGPU: requiredGPU,
-		JobState:    JobStateScheduled,
+	})
+}
+
+// EnqueueJob schedules job, taking its type, payload, GPU requirement,
+// priority, owner, run_after and dependencies from the caller. The creation
+// time and state are set here, and the ID unless the caller reserved one. The job waits for its dependencies,
+// then in its owner's pending queue, or among the delayed jobs until its
+// run_after, before the dispatcher releases it to the supervisors.
+func (s *Scheduler) EnqueueJob(job Job) (string, error) {
+	if job.ID == "" {
+		job.ID = generateJobID()
+	}
+	job.Retries = 0
+	job.Created = time.Now()
+	job.JobState = JobStateScheduled
+	if job.Priority == "" {
+		job.Priority = PriorityNormal
 	}
 
-	if ok, err := s.JobExists(job.ID); err != nil {
+	fields, err := jobFields(job)
+	if err != nil {
+		s.log.Error("failed to build job record", "error", err)
 		return "", err
-	} else if ok {
-		s.log.Warn("duplicate job skipped", "job_id", job.ID)
-		return job.ID, nil
 	}
+	stream := streamForGPU(job.RequiredGPU)
+	fields["stream"] = stream
 
-	// marshal the payload
-	payloadJSON, err := json.Marshal(job.Payload)
+	if len(job.DependsOn) > 0 {
+		// hold the job until its upstream jobs have succeeded
+		err = s.enqueueDependent(&job, fields)
+	} else {
+		// start redis transaction, the dispatcher watches the pending queue
+		pipe := s.client.TxPipeline()
+		s.addJob(pipe, job, fields)
+		_, err = pipe.Exec(s.ctx)
+	}
 	if err != nil {
-		s.log.Error("failed to marshal job payload", "error", err)
+		s.log.Error("failed to enqueue job", "error", err)
 		return "", err
 	}
 
-	// start redis pipeline
-	pipe := s.client.Pipeline()
+	s.log.Info("enqueued job", "job_id", job.ID, "job_type", job.Type, "gpu", job.RequiredGPU,
+		"owner", job.Owner, "priority", job.Priority, "run_after", job.RunAfter, "state", job.JobState)
+	return job.ID, nil
+}
 
-	// add payload to redis stream
-	pipe.XAdd(s.ctx, &redis.XAddArgs{
-		Stream: StreamName,
-		Values: map[string]interface{}{
-			"job_id":  job.ID,
-			"payload": string(payloadJSON),
-			"job_state": string(job.JobState),
-		},
-	})
+// addJob queues the commands storing a new job and putting it in line: a
+// waiting job stays put until its dependencies are done, a delayed one waits
+// for its run_after and any other goes to its owner's pending queue.
+func (s *Scheduler) addJob(pipe redis.Pipeliner, job Job, fields map[string]interface{}) {
+	// store the job record in a redis hash
+	pipe.HSet(s.ctx, jobKey(job.ID), fields)
 
-	// store metadata in a redis hash
-	metadataKey := fmt.Sprintf("job:%s", job.ID)
-	pipe.HSet(s.ctx, metadataKey, map[string]interface{}{
-		"type":         job.Type,
-		"retries":      job.Retries,
-		"created": job.Created.Format(time.RFC3339),
-		"required_gpu": job.RequiredGPU,
-		"job_state":    string(job.JobState),
-	})
+	switch {
+	case job.JobState == JobStateWaiting:
+		pipe.SAdd(s.ctx, WaitingJobsKey, job.ID)
+	case job.RunAfter != nil:
+		// hold the job back until it is due
+		pipe.ZAdd(s.ctx, DelayedJobsKey, redis.Z{Score: float64(job.RunAfter.UnixMilli()), Member: job.ID})
+	default:
+		// wait in the owner's queue for the stream of the accelerator the job needs
+		queueJob(s.ctx, pipe, job, streamForGPU(job.RequiredGPU))
+	}
 
-	// execute pipeline
-	if _, err := pipe.Exec(s.ctx); err != nil {
-		s.log.Error("failed to enqueue job", "error", err)
-		return "", err
+	// index by creation time for listing
+	pipe.ZAdd(s.ctx, JobIndexKey, redis.Z{Score: float64(job.Created.UnixMilli()), Member: job.ID})
+
+	// count the job against its owner's quotas
+	trackQuotaUsage(s.ctx, pipe, job.ID, map[string]string{"owner": job.Owner, "team": job.Team}, job.JobState, job.Created)
+}
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Cancel cancels a job that has not finished yet. A scheduled job is removed
+// from its stream; for a running job the owning supervisor is signalled to stop
+// and remove its container. Returns ErrJobNotFound or ErrJobFinished if the
+// job cannot be cancelled.
+func (s *Scheduler) Cancel(jobID string) error {
+	return s.cancelJob(jobID, "cancelled by user")
+}
+
+// cancelJob cancels a job, recording reason as its error.
+func (s *Scheduler) cancelJob(jobID, reason string) error {
+	metadataKey := jobKey(jobID)
+	var metadata map[string]string
+
+	// only flip the state if no one else finished the job in the meantime
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		var err error
+		metadata, err = tx.HGetAll(s.ctx, metadataKey).Result()
+		if err != nil {
+			return err
+		}
+		if len(metadata) == 0 {
+			return ErrJobNotFound
+		}
+		if isTerminalState(JobState(metadata["job_state"])) {
+			return ErrJobFinished
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(s.ctx, metadataKey,
+				"job_state", string(JobStateCancelled),
+				"error", reason,
+				"time_completed", time.Now().Format(time.RFC3339Nano),
+			)
+			return nil
+		})
+		return err
+	}, metadataKey)
+	if err != nil {
+		return err
 	}
 
-	s.log.Info("enqueued job", "job_id", job.ID, "job_type", job.Type, "gpu", requiredGPU)
-	return job.ID, nil
+	previousState := JobState(metadata["job_state"])
+	if previousState == JobStateWaiting {
+		pipe := s.client.Pipeline()
+		pipe.Del(s.ctx, waitingOnKey(jobID))
+		pipe.SRem(s.ctx, WaitingJobsKey, jobID)
+		pipe.Exec(s.ctx)
+	}
+	if previousState == JobStateScheduled {
+		// the job is either delayed, still pending or already released to its stream
+		pipe := s.client.Pipeline()
+		pipe.ZRem(s.ctx, DelayedJobsKey, jobID)
+		pipe.ZRem(s.ctx, pendingKey(metadata["stream"], metadata["owner"]), jobID)
+		if metadata["message_id"] != "" {
+			pipe.XDel(s.ctx, metadata["stream"], metadata["message_id"])
+		}
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			s.log.Warn("failed to remove cancelled job from its queue", "job_id", jobID, "error", err)
+		}
+	}
+
+	// always signal supervisors: the job may have been picked up after we read its state
+	if err := s.client.Publish(s.ctx, CancelChannel, jobID).Err(); err != nil {
+		s.log.Error("failed to publish job cancellation", "job_id", jobID, "error", err)
+	}
+
+	s.emitJobEvent(jobID, JobStateCancelled)
+	s.log.Info("job cancelled", "job_id", jobID, "previous_state", previousState, "reason", reason)
+	return nil
 }
 
-func (s *Scheduler) Close() error {
-	return s.client.Close()
+func (s *Scheduler) emitJobEvent(jobID string, state JobState) {
+	if err := s.client.XAdd(s.ctx, &redis.XAddArgs{
+		Stream: JobEventStream,
+		Values: map[string]interface{}{
+			"job_id":    jobID,
+			"state":     string(state),
+			"timestamp": time.Now().Format(time.RFC3339),
+		},
+	}).Err(); err != nil {
+		s.log.Error("failed to emit job event", "job_id", jobID, "state", state, "error", err)
+	}
 }
 
-func (s *Scheduler) JobExists(jobID string) (bool, error) {
-    exists, err := s.client.Exists(s.ctx, "job:"+jobID).Result()
-    if err != nil {
-        return false, err
-    }
-    return exists > 0, nil
+// Start launches the scheduler's background loops. They run until Close is called.
+func (s *Scheduler) Start() {
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.ListenForEvents()
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.dispatch()
+	}()
+}
+
+func (s *Scheduler) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return s.client.Close()
 }
 
 func (s *Scheduler) ListenForEvents() {
@@ -105,7 +226,7 @@ func (s *Scheduler) ListenForEvents() {
 
     lastID := "$"
 
-    for {
+    for s.ctx.Err() == nil {
         result, err := s.client.XRead(s.ctx, &redis.XReadArgs{
             Streams: []string{JobEventStream, lastID},
             Count:   10,
@@ -113,8 +234,8 @@ func (s *Scheduler) ListenForEvents() {
         }).Result()
 
         if err != nil {
-            if errors.Is(err, redis.Nil) {
-                continue // no new messages
+            if errors.Is(err, redis.Nil) || s.ctx.Err() != nil {
+                continue // no new messages or shutting down
             }
             s.log.Error("error reading from event stream", "error", err)
 			time.Sleep(time.Second)
@@ -135,20 +256,77 @@ func (s *Scheduler) handleEventMessage(msg redis.XMessage) {
     state, _ := msg.Values["state"].(string)
     timestamp, _ := msg.Values["timestamp"].(string)
     supervisor, _ := msg.Values["supervisor"].(string)
+    event, _ := msg.Values["event"].(string)
 
     if jobID == "" {
         s.log.Warn("received event with missing job_id", "message_id", msg.ID)
         return
     }
 
-    metadataKey := fmt.Sprintf("job:%s", jobID)
+    metadataKey := jobKey(jobID)
 
-    // Update job state in Redis
-    if err := s.client.HSet(s.ctx, metadataKey, "job_state", state, "updated_at", timestamp).Err(); err != nil {
+    // Update the job record, never moving a finished job back to a
+    // non-terminal state because of a stale event, nor a cancelled job to
+    // any other state
+    stale := false
+    err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+        fields, err := tx.HGetAll(s.ctx, metadataKey).Result()
+        if err != nil {
+            return err
+        }
+        current := JobState(fields["job_state"])
+        if isTerminalState(current) && !isTerminalState(JobState(state)) ||
+            current == JobStateCancelled && JobState(state) != JobStateCancelled {
+            stale = true
+            return nil
+        }
+
+        completed := time.Now()
+        if t := parseOptionalTime(fields["time_completed"]); t != nil {
+            completed = *t
+        } else if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+            completed = t
+        }
+
+        _, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+            pipe.HSet(s.ctx, metadataKey, "job_state", state, "updated_at", timestamp)
+            if JobState(state) == JobStateInProgress && supervisor != "" {
+                pipe.HSet(s.ctx, metadataKey, "consumer_id", supervisor)
+            }
+            if isTerminalState(JobState(state)) {
+                pipe.HSetNX(s.ctx, metadataKey, "time_completed", timestamp)
+            }
+            trackQuotaUsage(s.ctx, pipe, jobID, fields, JobState(state), completed)
+            if JobState(state) != JobStateScheduled && fields["stream"] != "" {
+                // picked up or finished, it no longer holds a dispatch slot
+                pipe.SRem(s.ctx, releasedKey(fields["stream"]), jobID)
+            }
+            return nil
+        })
+        return err
+    }, metadataKey)
+    if err != nil {
         s.log.Error("failed to update job metadata", "job_id", jobID, "error", err)
         return
     }
 
+    if stale {
+        s.log.Info("ignoring stale job event", "job_id", jobID, "state", state)
+        return
+    }
+
+    if isTerminalState(JobState(state)) {
+        s.resolveDependents(jobID, JobState(state))
+    }
+
+    if event == "reclaimed" {
+        previous, _ := msg.Values["previous_supervisor"].(string)
+        s.log.Warn("job handed over to another supervisor",
+            "job_id", jobID,
+            "previous_supervisor", previous,
+            "supervisor", supervisor)
+    }
+
     s.log.Info("job state updated",
         "job_id", jobID,
         "state", state,
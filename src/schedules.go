@@ -0,0 +1,441 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Schedules submit a job from a template every time their cron expression
+// fires. Each one is a hash at schedule:<id>; active schedules are also kept
+// in schedules:due, scored by their next run, which the dispatcher checks on
+// every tick. The scheduler that advances a schedule's next run under WATCH
+// is the one that submits the job, so every run happens once.
+//
+// If the scheduler was down when a run was due, the schedule's missed run
+// policy decides what happens once it is back: skip drops the missed runs,
+// run_once submits a single job for all of them.
+
+const (
+	ScheduleIndexKey = "schedules:index"
+	ScheduleDueKey   = "schedules:due"
+
+	// a run this late counts as missed
+	ScheduleMissedAfter = time.Minute
+)
+
+type MissedRunPolicy string
+
+const (
+	MissedRunSkip MissedRunPolicy = "skip"
+	MissedRunOnce MissedRunPolicy = "run_once"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+type Schedule struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name,omitempty"`
+	Cron       string           `json:"cron"`
+	Timezone   string           `json:"timezone,omitempty"` // IANA name, UTC if empty
+	Job        CreateJobRequest `json:"job"`                // template of the submitted jobs
+	MissedRuns MissedRunPolicy  `json:"missed_runs"`
+	Owner      string           `json:"owner,omitempty"`
+	Team       string           `json:"team,omitempty"`
+	Paused     bool             `json:"paused"`
+	Created    time.Time        `json:"created"`
+	NextRun    *time.Time       `json:"next_run,omitempty"` // unset while paused
+
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastJobID    string     `json:"last_job_id,omitempty"`
+	LastJobState JobState   `json:"last_job_state,omitempty"`
+	LastError    string     `json:"last_error,omitempty"` // why the last run did not submit a job
+	Missed       int        `json:"missed"`               // runs skipped so far
+}
+
+func scheduleKey(id string) string {
+	return fmt.Sprintf("schedule:%s", id)
+}
+
+func parseMissedRunPolicy(s string) (MissedRunPolicy, error) {
+	switch p := MissedRunPolicy(s); p {
+	case "":
+		return MissedRunSkip, nil
+	case MissedRunSkip, MissedRunOnce:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: missed_runs must be skip or run_once, got %q", ErrInvalidSchedule, s)
+}
+
+// scheduleLocation returns the location the cron expression of a schedule is
+// evaluated in.
+func scheduleLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, timezone)
+	}
+	return loc, nil
+}
+
+// validate checks sched and returns its parsed cron expression and location.
+func (sched *Schedule) validate() (*CronSchedule, *time.Location, error) {
+	cron, err := ParseCron(sched.Cron)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	loc, err := scheduleLocation(sched.Timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sched.MissedRuns, err = parseMissedRunPolicy(string(sched.MissedRuns)); err != nil {
+		return nil, nil, err
+	}
+
+	if sched.Job.RunAfter != nil || sched.Job.Delay != "" || len(sched.Job.DependsOn) > 0 {
+		return nil, nil, fmt.Errorf("%w: job template cannot set run_after, delay or depends_on", ErrInvalidSchedule)
+	}
+	// the template must make a job that could be submitted on its own
+	if _, err := jobFromRequest(sched.Job, &Identity{Username: sched.Owner, Team: sched.Team}); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	return cron, loc, nil
+}
+
+// planScheduleRun reports whether a run that was due at due should submit a
+// job at now, and whether the run was missed.
+func planScheduleRun(policy MissedRunPolicy, due, now time.Time) (run, missed bool) {
+	if now.Sub(due) <= ScheduleMissedAfter {
+		return true, false
+	}
+	return policy == MissedRunOnce, true
+}
+
+func scheduleFields(sched *Schedule) (map[string]interface{}, error) {
+	template, err := json.Marshal(sched.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job template: %w", err)
+	}
+	fields := map[string]interface{}{
+		"name":        sched.Name,
+		"cron":        sched.Cron,
+		"timezone":    sched.Timezone,
+		"job":         string(template),
+		"missed_runs": string(sched.MissedRuns),
+		"owner":       sched.Owner,
+		"team":        sched.Team,
+		"paused":      strconv.FormatBool(sched.Paused),
+		"created":     sched.Created.Format(time.RFC3339Nano),
+		"missed":      sched.Missed,
+	}
+	if sched.NextRun != nil {
+		fields["next_run"] = sched.NextRun.Format(time.RFC3339Nano)
+	}
+	return fields, nil
+}
+
+func scheduleFromFields(id string, fields map[string]string) (*Schedule, error) {
+	sched := &Schedule{
+		ID:         id,
+		Name:       fields["name"],
+		Cron:       fields["cron"],
+		Timezone:   fields["timezone"],
+		MissedRuns: MissedRunPolicy(fields["missed_runs"]),
+		Owner:      fields["owner"],
+		Team:       fields["team"],
+		Paused:     fields["paused"] == "true",
+		NextRun:    parseOptionalTime(fields["next_run"]),
+		LastRun:    parseOptionalTime(fields["last_run"]),
+		LastJobID:  fields["last_job_id"],
+		LastError:  fields["last_error"],
+	}
+	sched.Missed, _ = strconv.Atoi(fields["missed"])
+	if created := parseOptionalTime(fields["created"]); created != nil {
+		sched.Created = *created
+	}
+	if err := json.Unmarshal([]byte(fields["job"]), &sched.Job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job template: %w", err)
+	}
+	return sched, nil
+}
+
+// CreateSchedule validates and stores sched, taking its name, cron
+// expression, timezone, job template, missed run policy and owner from the
+// caller.
+func (s *Scheduler) CreateSchedule(sched Schedule) (*Schedule, error) {
+	cron, loc, err := sched.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sched.ID = generateScheduleID()
+	sched.Created = now
+	sched.Paused = false
+	next := cron.Next(now.In(loc))
+	if next.IsZero() {
+		return nil, fmt.Errorf("%w: %q never runs", ErrInvalidSchedule, sched.Cron)
+	}
+	sched.NextRun = &next
+
+	fields, err := scheduleFields(&sched)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, scheduleKey(sched.ID), fields)
+	pipe.ZAdd(s.ctx, ScheduleIndexKey, redis.Z{Score: float64(now.UnixMilli()), Member: sched.ID})
+	pipe.ZAdd(s.ctx, ScheduleDueKey, redis.Z{Score: float64(next.UnixMilli()), Member: sched.ID})
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to store schedule: %w", err)
+	}
+
+	s.log.Info("schedule created", "schedule_id", sched.ID, "cron", sched.Cron, "owner", sched.Owner, "next_run", next)
+	return &sched, nil
+}
+
+// GetSchedule returns a schedule along with the state of its last job.
+func (s *Scheduler) GetSchedule(id string) (*Schedule, error) {
+	fields, err := s.client.HGetAll(s.ctx, scheduleKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrScheduleNotFound
+	}
+	sched, err := scheduleFromFields(id, fields)
+	if err != nil {
+		return nil, err
+	}
+	if sched.LastJobID != "" {
+		state, _ := s.client.HGet(s.ctx, jobKey(sched.LastJobID), "job_state").Result()
+		sched.LastJobState = JobState(state)
+	}
+	return sched, nil
+}
+
+// ListSchedules returns the schedules of owner, or every schedule if owner
+// is empty, oldest first.
+func (s *Scheduler) ListSchedules(owner string) ([]Schedule, error) {
+	ids, err := s.client.ZRange(s.ctx, ScheduleIndexKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(s.ctx, scheduleKey(id))
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	schedules := []Schedule{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 || (owner != "" && fields["owner"] != owner) {
+			continue
+		}
+		sched, err := scheduleFromFields(ids[i], fields)
+		if err != nil {
+			s.log.Warn("skipping unreadable schedule", "schedule_id", ids[i], "error", err)
+			continue
+		}
+		schedules = append(schedules, *sched)
+	}
+	return schedules, nil
+}
+
+// SetSchedulePaused pauses or resumes a schedule. A resumed schedule runs at
+// the next time its cron expression fires; runs due while it was paused are
+// not made up.
+func (s *Scheduler) SetSchedulePaused(id string, paused bool) (*Schedule, error) {
+	key := scheduleKey(id)
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(s.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return ErrScheduleNotFound
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			if paused {
+				pipe.HSet(s.ctx, key, "paused", "true")
+				pipe.HDel(s.ctx, key, "next_run")
+				pipe.ZRem(s.ctx, ScheduleDueKey, id)
+				return nil
+			}
+
+			cron, err := ParseCron(fields["cron"])
+			if err != nil {
+				return err
+			}
+			loc, err := scheduleLocation(fields["timezone"])
+			if err != nil {
+				return err
+			}
+			next := cron.Next(time.Now().In(loc))
+			pipe.HSet(s.ctx, key, "paused", "false", "next_run", next.Format(time.RFC3339Nano))
+			pipe.ZAdd(s.ctx, ScheduleDueKey, redis.Z{Score: float64(next.UnixMilli()), Member: id})
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("schedule updated", "schedule_id", id, "paused", paused)
+	return s.GetSchedule(id)
+}
+
+// DeleteSchedule removes a schedule. Jobs it already submitted are kept.
+func (s *Scheduler) DeleteSchedule(id string) error {
+	pipe := s.client.TxPipeline()
+	deleted := pipe.Del(s.ctx, scheduleKey(id))
+	pipe.ZRem(s.ctx, ScheduleIndexKey, id)
+	pipe.ZRem(s.ctx, ScheduleDueKey, id)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return ErrScheduleNotFound
+	}
+	s.log.Info("schedule deleted", "schedule_id", id)
+	return nil
+}
+
+// runSchedules submits the jobs of the schedules that are due.
+func (s *Scheduler) runSchedules() {
+	now := time.Now()
+	due, err := s.client.ZRangeByScore(s.ctx, ScheduleDueKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.log.Error("failed to read due schedules", "error", err)
+		}
+		return
+	}
+
+	for _, id := range due {
+		if err := s.runSchedule(id, now); err != nil && s.ctx.Err() == nil {
+			s.log.Error("failed to run schedule", "schedule_id", id, "error", err)
+		}
+	}
+}
+
+// runSchedule advances a due schedule to its next run and submits a job if
+// its missed run policy allows.
+func (s *Scheduler) runSchedule(id string, now time.Time) error {
+	key := scheduleKey(id)
+	var sched *Schedule
+	var run bool
+
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(s.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 || fields["paused"] == "true" {
+			return tx.ZRem(s.ctx, ScheduleDueKey, id).Err()
+		}
+		if sched, err = scheduleFromFields(id, fields); err != nil {
+			return err
+		}
+		if sched.NextRun == nil || sched.NextRun.After(now) {
+			// another scheduler got here first
+			return nil
+		}
+
+		cron, err := ParseCron(sched.Cron)
+		if err != nil {
+			return err
+		}
+		loc, err := scheduleLocation(sched.Timezone)
+		if err != nil {
+			return err
+		}
+
+		var missed bool
+		run, missed = planScheduleRun(sched.MissedRuns, *sched.NextRun, now)
+		next := cron.Next(now.In(loc))
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(s.ctx, key, "next_run", next.Format(time.RFC3339Nano))
+			if missed {
+				pipe.HIncrBy(s.ctx, key, "missed", 1)
+			}
+			if run {
+				pipe.HSet(s.ctx, key, "last_run", now.Format(time.RFC3339Nano))
+			} else {
+				pipe.HSet(s.ctx, key, "last_error", fmt.Sprintf("missed run due at %s", sched.NextRun.Format(time.RFC3339)))
+			}
+			pipe.ZAdd(s.ctx, ScheduleDueKey, redis.Z{Score: float64(next.UnixMilli()), Member: id})
+			return nil
+		})
+		if errors.Is(err, redis.TxFailedErr) {
+			// the schedule changed meanwhile; it is looked at again next tick
+			run = false
+			return nil
+		}
+		return err
+	}, key)
+	if err != nil || !run {
+		return err
+	}
+
+	jobID, err := s.submitScheduledJob(sched)
+	if err != nil {
+		s.log.Warn("scheduled run did not submit a job", "schedule_id", id, "error", err)
+		return s.client.HSet(s.ctx, key, "last_error", err.Error()).Err()
+	}
+	return s.client.HSet(s.ctx, key, "last_job_id", jobID, "last_error", "").Err()
+}
+
+// submitScheduledJob submits the job of one run of sched, subject to the
+// quotas of its owner.
+func (s *Scheduler) submitScheduledJob(sched *Schedule) (string, error) {
+	priority, err := parsePriority(sched.Job.Priority)
+	if err != nil {
+		return "", err
+	}
+	job := Job{
+		ID:          generateJobID(),
+		Type:        sched.Job.Type,
+		Payload:     sched.Job.Payload,
+		RequiredGPU: sched.Job.RequiredGPU,
+		Owner:       sched.Owner,
+		Team:        sched.Team,
+		Priority:    priority,
+		Schedule:    sched.ID,
+	}
+
+	// the job counts against the quota from here on unless enqueueing fails
+	if err := s.quotas.Reserve(sched.Owner, sched.Team, []Job{job}); err != nil {
+		return "", err
+	}
+	jobID, err := s.EnqueueJob(job)
+	if err != nil {
+		s.quotas.Release(sched.Owner, sched.Team, job.ID)
+		return "", err
+	}
+	s.log.Info("scheduled job submitted", "schedule_id", sched.ID, "job_id", jobID)
+	return jobID, nil
+}
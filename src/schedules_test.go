@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	valid := Schedule{Cron: "0 2 * * *", Job: CreateJobRequest{Type: "eval"}}
+	if _, _, err := valid.validate(); err != nil {
+		t.Fatalf("Expected a valid schedule, got %v", err)
+	}
+	if valid.MissedRuns != MissedRunSkip {
+		t.Errorf("Expected missed runs to default to skip, got %q", valid.MissedRuns)
+	}
+
+	later := time.Now().Add(time.Hour)
+	invalid := map[string]Schedule{
+		"bad cron":      {Cron: "0 25 * * *", Job: CreateJobRequest{Type: "eval"}},
+		"bad timezone":  {Cron: "@daily", Timezone: "Mars/Olympus", Job: CreateJobRequest{Type: "eval"}},
+		"bad policy":    {Cron: "@daily", MissedRuns: "run_all", Job: CreateJobRequest{Type: "eval"}},
+		"no job type":   {Cron: "@daily"},
+		"bad priority":  {Cron: "@daily", Job: CreateJobRequest{Type: "eval", Priority: "urgent"}},
+		"run_after set": {Cron: "@daily", Job: CreateJobRequest{Type: "eval", RunAfter: &later}},
+		"bad gpu type":  {Cron: "@daily", Job: CreateJobRequest{Type: "eval", RequiredGPU: "FPGA"}},
+	}
+	for name, sched := range invalid {
+		if _, _, err := sched.validate(); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s: expected ErrInvalidSchedule, got %v", name, err)
+		}
+	}
+}
+
+func TestPlanScheduleRun(t *testing.T) {
+	due := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy      MissedRunPolicy
+		now         time.Time
+		run, missed bool
+	}{
+		{MissedRunSkip, due.Add(time.Second), true, false},
+		{MissedRunOnce, due.Add(time.Second), true, false},
+		{MissedRunSkip, due.Add(8 * time.Hour), false, true},
+		{MissedRunOnce, due.Add(8 * time.Hour), true, true},
+	}
+	for _, tt := range tests {
+		run, missed := planScheduleRun(tt.policy, due, tt.now)
+		if run != tt.run || missed != tt.missed {
+			t.Errorf("%s at %v: expected run=%v missed=%v, got run=%v missed=%v",
+				tt.policy, tt.now.Sub(due), tt.run, tt.missed, run, missed)
+		}
+	}
+}
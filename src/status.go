@@ -3,9 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -89,32 +89,78 @@ func (sr *StatusRegistry) UpdateStatus(consumerID string, status SupervisorStatu
 	return nil
 }
 
+// MarkStaleSupervisors flips active supervisors whose last heartbeat is older
+// than timeout to failed. Returns the consumer IDs that were marked.
+func (sr *StatusRegistry) MarkStaleSupervisors(timeout time.Duration) ([]string, error) {
+	supervisors, err := sr.GetActiveSupervisors()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-timeout)
+	var marked []string
+	for _, supervisor := range supervisors {
+		if supervisor.LastSeen.After(cutoff) {
+			continue
+		}
+		supervisor.Status = SupervisorStateFailed
+		if err := sr.UpdateStatus(supervisor.ConsumerID, supervisor); err != nil {
+			return marked, err
+		}
+		sr.log.Warn("supervisor heartbeat lapsed, marked failed",
+			"consumer_id", supervisor.ConsumerID, "last_seen", supervisor.LastSeen)
+		marked = append(marked, supervisor.ConsumerID)
+	}
+
+	return marked, nil
+}
+
+// SweepSupervisors runs MarkStaleSupervisors every interval until ctx is done.
+func (sr *StatusRegistry) SweepSupervisors(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sr.MarkStaleSupervisors(timeout); err != nil {
+				sr.log.Error("failed to sweep supervisors", "error", err)
+			}
+		}
+	}
+}
+
+// GetJobStatus returns the record of jobID. Returns an error wrapping
+// ErrJobNotFound if there is no such job.
 func (sr *StatusRegistry) GetJobStatus(jobID string) (*Job, error) {
 	ctx := context.Background()
-	result := sr.redisClient.HGet(ctx, JobStatusKey, jobID)
+	result := sr.redisClient.HGetAll(ctx, jobKey(jobID))
 	if result.Err() != nil {
-		if errors.Is(result.Err(), redis.Nil) {
-			return nil, fmt.Errorf("job not found: %s", jobID)
-		}
 		return nil, fmt.Errorf("failed to get job status: %w", result.Err())
 	}
+	if len(result.Val()) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, jobID)
+	}
 
-	var job Job
-	if err := json.Unmarshal([]byte(result.Val()), &job); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal job status: %w", err)
+	job, err := jobFromFields(jobID, result.Val())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job status: %w", err)
 	}
 
-	return &job, nil
+	return job, nil
 }
 
+// UpdateJobStatus writes the fields of job to its record.
 func (sr *StatusRegistry) UpdateJobStatus(jobID string, job Job) error {
 	ctx := context.Background()
-	statusJSON, err := json.Marshal(job)
+	fields, err := jobFields(job)
 	if err != nil {
-		return fmt.Errorf("failed to marshal job status: %w", err)
+		return err
 	}
 
-	result := sr.redisClient.HSet(ctx, JobStatusKey, jobID, string(statusJSON))
+	result := sr.redisClient.HSet(ctx, jobKey(jobID), fields)
 	if result.Err() != nil {
 		return fmt.Errorf("failed to update job status: %w", result.Err())
 	}
@@ -6,80 +6,228 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"mist/docker"
 
-	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
 	"github.com/redis/go-redis/v9"
 )
 
 // CPUImage and CPURuntime are used when running CPU-only jobs (no GPU).
 const (
-	CPUImage  = "pytorch-cpu"
+	CPUImage   = "pytorch-cpu"
 	CPURuntime = "runc"
 )
 
 type Supervisor struct {
-	redisClient   *redis.Client
-	ctx           context.Context
-	cancel        context.CancelFunc
-	consumerID    string
-	gpuType       string
-	dockerMgr     *docker.DockerMgr
-	wg            sync.WaitGroup
-	log           *slog.Logger
+	redisClient *redis.Client
+	ctx         context.Context // cancelled on Stop to stop taking new jobs
+	cancel      context.CancelFunc
+	workCtx     context.Context // cancelled once in-flight jobs have drained
+	stopWork    context.CancelFunc
+	consumerID  string
+	gpuType     string
+	runtime     Runtime
+	runtimeErr  error // why no runtime could be set up
+	wg          sync.WaitGroup
+	jobs        sync.WaitGroup
+	jobsMu      sync.Mutex // guards stopping and jobs.Add against Stop's jobs.Wait
+	stopping    bool
+	slots       chan struct{}
+	concurrency int
+	log         *slog.Logger
+	streams     []string
+	claimIdle   time.Duration
+	inFlight    map[inFlightKey]struct{}
+	inFlightMu  sync.Mutex
+	running     map[string]context.CancelFunc
+	runningMu   sync.Mutex
+	status      *StatusRegistry
+	startedAt   time.Time
+	artifacts   *ArtifactStore
+	uploads     *UploadStore
 }
 
-func NewSupervisor(redisAddr, consumerID, gpuType string, log *slog.Logger) *Supervisor {
+// inFlightKey identifies a message being worked on; message IDs are only
+// unique within a single stream.
+type inFlightKey struct {
+	stream    string
+	messageID string
+}
+
+// SupervisorOption configures optional Supervisor behaviour.
+type SupervisorOption func(*Supervisor)
+
+// WithConcurrency sets how many jobs the supervisor runs in parallel. It is
+// capped at the container limit of the supervisor's runtime.
+func WithConcurrency(n int) SupervisorOption {
+	return func(s *Supervisor) {
+		s.concurrency = n
+	}
+}
+
+// WithClaimIdleTimeout sets how long a message may sit unacknowledged in another
+// consumer's pending entries list before this supervisor takes it over.
+func WithClaimIdleTimeout(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.claimIdle = d
+	}
+}
+
+// WithRuntime sets the runtime that runs the supervisor's jobs instead of
+// the one named by MIST_RUNTIME.
+func WithRuntime(rt Runtime) SupervisorOption {
+	return func(s *Supervisor) {
+		s.runtime = rt
+	}
+}
+
+// WithArtifactStore sets where the supervisor keeps the outputs of jobs.
+func WithArtifactStore(store *ArtifactStore) SupervisorOption {
+	return func(s *Supervisor) {
+		s.artifacts = store
+	}
+}
+
+// WithUploadStore sets where the supervisor reads the inputs of jobs from.
+func WithUploadStore(store *UploadStore) SupervisorOption {
+	return func(s *Supervisor) {
+		s.uploads = store
+	}
+}
+
+func NewSupervisor(redisAddr, consumerID, gpuType string, log *slog.Logger, opts ...SupervisorOption) *Supervisor {
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: redisAddr,
 	})
 
 	ctx, cancel := context.WithCancel(context.Background())
-
-	var dockerMgr *docker.DockerMgr
-	dockerCli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		log.Warn("Docker client unavailable, containers will not be started", "error", err)
-	} else {
-		dockerMgr = docker.NewDockerMgr(dockerCli, 10, 100)
-		log.Info("Docker client initialized for container execution")
+	workCtx, stopWork := context.WithCancel(context.Background())
+
+	s := &Supervisor{
+		redisClient: redisClient,
+		ctx:         ctx,
+		cancel:      cancel,
+		workCtx:     workCtx,
+		stopWork:    stopWork,
+		concurrency: DefaultConcurrency,
+		consumerID:  consumerID,
+		gpuType:     gpuType,
+		log:         log,
+		streams:     supervisorStreams(gpuType),
+		claimIdle:   ClaimIdleTimeout,
+		inFlight:    make(map[inFlightKey]struct{}),
+		running:     make(map[string]context.CancelFunc),
+		status:      NewStatusRegistry(redisClient, log),
+		artifacts:   artifactStoreFromEnv(),
+		uploads:     uploadStoreFromEnv(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.runtime == nil {
+		s.runtime, s.runtimeErr = runtimeFromEnv(log)
+		if s.runtimeErr != nil {
+			log.Error("no container runtime, jobs cannot be run", "error", s.runtimeErr)
+		}
 	}
 
-	return &Supervisor{
-		redisClient:  redisClient,
-		ctx:          ctx,
-		cancel:       cancel,
-		consumerID:   consumerID,
-		gpuType:      gpuType,
-		dockerMgr: dockerMgr,
-		log:          log,
+	if s.concurrency < 1 {
+		s.concurrency = 1
+	}
+	if s.runtime != nil && s.concurrency > s.runtime.ContainerLimit() {
+		log.Warn("concurrency exceeds container limit, capping",
+			"concurrency", s.concurrency, "container_limit", s.runtime.ContainerLimit())
+		s.concurrency = s.runtime.ContainerLimit()
 	}
+	s.slots = make(chan struct{}, s.concurrency)
+
+	return s
 }
 
 func (s *Supervisor) Start() error {
+	if s.runtime == nil {
+		return fmt.Errorf("no container runtime: %w", s.runtimeErr)
+	}
+
 	// Create consumer group if it doesn't exist
 	err := s.createConsumerGroup()
 	if err != nil {
 		return fmt.Errorf("failed to create consumer group: %w", err)
 	}
 
-	s.wg.Add(1)
+	s.startedAt = time.Now()
+	if err := s.reportStatus(SupervisorStateActive); err != nil {
+		return fmt.Errorf("failed to register supervisor: %w", err)
+	}
+
+	s.wg.Add(4)
 	go s.processJobs()
+	go s.reclaimJobs()
+	go s.listenForCancellations()
+	go s.heartbeat()
 
-	s.log.Info("supervisor started", "consumer_id", s.consumerID, "gpu_type", s.gpuType)
+	s.log.Info("supervisor started", "consumer_id", s.consumerID, "gpu_type", s.gpuType, "concurrency", s.concurrency)
 	return nil
 }
 
+// reportStatus publishes this supervisor's state to the status registry,
+// refreshing its last seen time.
+func (s *Supervisor) reportStatus(state SupervisorState) error {
+	return s.status.UpdateStatus(s.consumerID, SupervisorStatus{
+		ConsumerID: s.consumerID,
+		GPUType:    s.gpuType,
+		Status:     state,
+		LastSeen:   time.Now(),
+		StartedAt:  s.startedAt,
+	})
+}
+
+// heartbeat periodically refreshes this supervisor's entry in the status
+// registry so that it is not considered failed.
+func (s *Supervisor) heartbeat() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.workCtx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reportStatus(SupervisorStateActive); err != nil {
+				s.log.Error("failed to send heartbeat", "consumer_id", s.consumerID, "error", err)
+			}
+		}
+	}
+}
+
+// supervisorStreams returns the streams a supervisor for gpuType consumes: the
+// stream dedicated to its accelerator plus the shared stream for jobs without
+// a GPU requirement.
+func supervisorStreams(gpuType string) []string {
+	own := streamForGPU(gpuType)
+	if own == StreamName {
+		return []string{StreamName}
+	}
+	return []string{own, StreamName}
+}
+
 func (s *Supervisor) createConsumerGroup() error {
-	result := s.redisClient.XGroupCreateMkStream(s.ctx, StreamName, ConsumerGroup, "$")
-	if result.Err() != nil {
-		if result.Err().Error() != "BUSYGROUP Consumer Group name already exists" {
-			// in this case the group already exists
-			return result.Err()
+	for _, stream := range s.streams {
+		// start from the beginning so jobs enqueued before the first supervisor
+		// for this accelerator came up are not skipped
+		result := s.redisClient.XGroupCreateMkStream(s.ctx, stream, ConsumerGroup, "0")
+		if result.Err() != nil {
+			if result.Err().Error() != "BUSYGROUP Consumer Group name already exists" {
+				// in this case the group already exists
+				return result.Err()
+			}
 		}
 	}
 	return nil
@@ -90,113 +238,439 @@ func (s *Supervisor) processJobs() {
 	s.log.Info("job processor started", "consumer_id", s.consumerID)
 	defer s.log.Info("job processor stopped", "consumer_id", s.consumerID)
 
+	// one ">" per stream: only messages never delivered to another consumer
+	streams := append([]string{}, s.streams...)
+	for range s.streams {
+		streams = append(streams, ">")
+	}
+
+	for {
+		// wait for a free worker before taking more jobs off the stream
+		if !s.acquireSlot(s.ctx) {
+			return
+		}
+		// one slot is held; read as many jobs as there are free workers
+		free := 1 + cap(s.slots) - len(s.slots)
+
+		// Read from stream with blocking
+		result := s.redisClient.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+			Group:    ConsumerGroup,
+			Consumer: s.consumerID,
+			Streams:  streams,
+			Count:    int64(free),
+			Block:    time.Second * 5,
+		})
+
+		if result.Err() != nil {
+			s.releaseSlot()
+			if !errors.Is(result.Err(), redis.Nil) && s.ctx.Err() == nil {
+				s.log.Error("error reading from stream", "error", result.Err())
+			}
+			continue
+		}
+
+		// Process each message on its own worker
+		acquired := true
+		for _, stream := range result.Val() {
+			for _, message := range stream.Messages {
+				// the reclaimer may have taken the workers that were free when
+				// the messages were read, so this can wait for a job to finish
+				if !acquired && !s.acquireSlot(s.workCtx) {
+					// stopped: leave the message pending for another supervisor
+					continue
+				}
+				acquired = false
+				s.runJob(stream.Stream, message)
+			}
+		}
+		if acquired {
+			s.releaseSlot()
+		}
+	}
+}
+
+// acquireSlot blocks until a worker is free or ctx is done. Returns false if ctx is done.
+func (s *Supervisor) acquireSlot(ctx context.Context) bool {
+	// select picks randomly when both are ready; never start work once stopped
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Supervisor) releaseSlot() {
+	<-s.slots
+}
+
+// runJob handles message on a worker whose slot the caller already holds.
+// The slot is released once the job finishes. Once Stop has been called the
+// message is left pending for another supervisor instead.
+func (s *Supervisor) runJob(stream string, message redis.XMessage) {
+	s.jobsMu.Lock()
+	if s.stopping {
+		s.jobsMu.Unlock()
+		s.releaseSlot()
+		return
+	}
+	s.jobs.Add(1)
+	s.jobsMu.Unlock()
+	go func() {
+		defer s.jobs.Done()
+		defer s.releaseSlot()
+		s.handleMessage(stream, message)
+	}()
+}
+
+// reclaimJobs periodically keeps this supervisor's in-flight messages fresh and
+// takes over messages that have been idle in another consumer's pending entries
+// list for longer than claimIdle, e.g. because that supervisor crashed mid-job.
+func (s *Supervisor) reclaimJobs() {
+	defer s.wg.Done()
+	s.log.Info("job reclaimer started", "consumer_id", s.consumerID, "claim_idle", s.claimIdle)
+	defer s.log.Info("job reclaimer stopped", "consumer_id", s.consumerID)
+
+	// refresh in-flight messages well before they could be considered idle
+	ticker := time.NewTicker(s.claimIdle / 3)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-s.ctx.Done():
+		case <-s.workCtx.Done():
 			return
-		default:
-			// Read from stream with blocking
-			result := s.redisClient.XReadGroup(s.ctx, &redis.XReadGroupArgs{
-				Group:    ConsumerGroup,
-				Consumer: s.consumerID,
-				Streams:  []string{StreamName, ">"},
-				Count:    1,
-				Block:    time.Second * 5,
+		case <-ticker.C:
+			s.touchInFlight()
+			// while draining on Stop only keep our own messages fresh
+			if s.ctx.Err() != nil {
+				continue
+			}
+			for _, stream := range s.streams {
+				s.reclaimIdleMessages(stream)
+			}
+		}
+	}
+}
+
+// touchInFlight resets the idle time of messages this supervisor is still
+// working on so that other supervisors do not reclaim long-running jobs.
+func (s *Supervisor) touchInFlight() {
+	s.inFlightMu.Lock()
+	idsByStream := make(map[string][]string)
+	for key := range s.inFlight {
+		idsByStream[key.stream] = append(idsByStream[key.stream], key.messageID)
+	}
+	s.inFlightMu.Unlock()
+
+	for stream, ids := range idsByStream {
+		if err := s.redisClient.XClaimJustID(s.workCtx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    ConsumerGroup,
+			Consumer: s.consumerID,
+			MinIdle:  0,
+			Messages: ids,
+		}).Err(); err != nil && !errors.Is(err, redis.Nil) {
+			s.log.Error("failed to refresh in-flight messages", "stream", stream, "error", err)
+		}
+	}
+}
+
+func (s *Supervisor) reclaimIdleMessages(stream string) {
+	pending, err := s.redisClient.XPendingExt(s.ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  ConsumerGroup,
+		Idle:   s.claimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  10,
+	}).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) && s.ctx.Err() == nil {
+			s.log.Error("failed to read pending entries", "error", err)
+		}
+		return
+	}
+
+	for _, entry := range pending {
+		if s.isInFlight(stream, entry.ID) {
+			continue
+		}
+
+		// MinIdle makes the claim a no-op if another supervisor got there first
+		messages, err := s.redisClient.XClaim(s.ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    ConsumerGroup,
+			Consumer: s.consumerID,
+			MinIdle:  s.claimIdle,
+			Messages: []string{entry.ID},
+		}).Result()
+		if err != nil {
+			s.log.Error("failed to claim pending message", "message_id", entry.ID, "error", err)
+			continue
+		}
+
+		for _, message := range messages {
+			jobID, _ := message.Values["job_id"].(string)
+			s.log.Warn("reclaimed stuck job",
+				"job_id", jobID, "message_id", message.ID, "previous_supervisor", entry.Consumer,
+				"idle", entry.Idle, "deliveries", entry.RetryCount)
+
+			// this supervisor holds the job now, the handover does not put it back in the queue
+			s.emitJobEventWith(jobID, JobStateInProgress, map[string]interface{}{
+				"event":               "reclaimed",
+				"previous_supervisor": entry.Consumer,
+				"deliveries":          entry.RetryCount,
 			})
 
-			if result.Err() != nil {
-				if !errors.Is(result.Err(), redis.Nil) {
-					s.log.Error("error reading from stream", "error", result.Err())
+			// a message that keeps taking supervisors down should not be retried forever
+			if entry.RetryCount > MaxRetries+1 {
+				s.log.Error("giving up on repeatedly reclaimed job", "job_id", jobID, "deliveries", entry.RetryCount)
+				if jobID != "" {
+					s.completeJob(jobID, JobStateFailure, nil, errors.New("supervisor lost too many times while running job"))
+					s.emitJobEvent(jobID, JobStateFailure)
 				}
+				s.ackMessage(stream, message.ID)
 				continue
 			}
 
-			// Process each message
-			for _, stream := range result.Val() {
-				for _, message := range stream.Messages {
-					s.handleMessage(message)
-				}
+			if !s.acquireSlot(s.ctx) {
+				// stopping: leave the message pending for another supervisor
+				return
+			}
+			s.runJob(stream, message)
+		}
+	}
+}
+
+// listenForCancellations stops jobs running on this supervisor when the
+// scheduler publishes their cancellation.
+func (s *Supervisor) listenForCancellations() {
+	defer s.wg.Done()
+
+	pubsub := s.redisClient.Subscribe(s.workCtx, CancelChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-s.workCtx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.runningMu.Lock()
+			cancel, found := s.running[msg.Payload]
+			s.runningMu.Unlock()
+			if found {
+				s.log.Info("cancelling running job", "job_id", msg.Payload)
+				cancel()
 			}
 		}
 	}
 }
 
-func (s *Supervisor) handleMessage(message redis.XMessage) {
+func (s *Supervisor) isInFlight(stream, messageID string) bool {
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+	_, ok := s.inFlight[inFlightKey{stream, messageID}]
+	return ok
+}
+
+func (s *Supervisor) handleMessage(stream string, message redis.XMessage) {
+	key := inFlightKey{stream, message.ID}
+	s.inFlightMu.Lock()
+	s.inFlight[key] = struct{}{}
+	s.inFlightMu.Unlock()
+	defer func() {
+		s.inFlightMu.Lock()
+		delete(s.inFlight, key)
+		s.inFlightMu.Unlock()
+	}()
+
 	jobID, ok := message.Values["job_id"].(string)
 	if !ok {
 		s.log.Error("invalid job_id in message", "message_id", message.ID)
-		s.ackMessage(message.ID)
+		s.ackMessage(stream, message.ID)
 		return
 	}
 
+	// register before reading the job state so a cancellation published in
+	// between is not missed
+	jobCtx, cancelJob := context.WithCancel(context.Background())
+	defer cancelJob()
+	s.runningMu.Lock()
+	s.running[jobID] = cancelJob
+	s.runningMu.Unlock()
+	defer func() {
+		s.runningMu.Lock()
+		delete(s.running, jobID)
+		s.runningMu.Unlock()
+	}()
+
 	payloadData, ok := message.Values["payload"].(string)
 	if !ok {
 		s.log.Error("invalid payload in message", "message_id", message.ID)
-		s.ackMessage(message.ID)
+		s.ackMessage(stream, message.ID)
 		return
 	}
 
 	var payload map[string]interface{}
 	if err := json.Unmarshal([]byte(payloadData), &payload); err != nil {
 		s.log.Error("failed to unmarshal payload data", "error", err, "message_id", message.ID)
-		s.ackMessage(message.ID)
+		s.ackMessage(stream, message.ID)
 		return
 	}
 
-	jobKey := fmt.Sprintf("job:%s", jobID)
-	metadata, err := s.redisClient.HGetAll(s.ctx, jobKey).Result()
+	metadata, err := s.redisClient.HGetAll(s.workCtx, jobKey(jobID)).Result()
 	if err != nil {
 		s.log.Error("failed to fetch job metadata", "job_id", jobID, "error", err)
-		s.ackMessage(message.ID)
+		s.ackMessage(stream, message.ID)
 		return
 	}
 
 	if len(metadata) == 0 {
-    s.log.Error("job metadata not found", "job_id", jobID)
-    s.ackMessage(message.ID)
-    return
+		s.log.Error("job metadata not found", "job_id", jobID)
+		s.ackMessage(stream, message.ID)
+		return
 	}
 
-	jobType := metadata["type"]
-	requiredGPU := metadata["required_gpu"]
-	jobState := metadata["job_state"]
-
-	createdTime, _ := time.Parse(time.RFC3339, metadata["created"])
-	retries, _ := strconv.Atoi(metadata["retries"])
-	
-	job := Job{
-		ID:          jobID,
-		Type:        jobType,
-		Payload:     payload,
-		Retries:     retries,
-		Created:     createdTime,
-		RequiredGPU: requiredGPU,
-		JobState:    JobState(jobState),
+	record, err := jobFromFields(jobID, metadata)
+	if err != nil {
+		s.log.Error("invalid job metadata", "job_id", jobID, "error", err)
+		s.ackMessage(stream, message.ID)
+		return
+	}
+	job := *record
+	job.Payload = payload
+
+	// a reclaimed message may belong to a job that already finished before its
+	// supervisor died without acknowledging it, or the job was cancelled
+	if isTerminalState(job.JobState) {
+		s.log.Info("skipping already finished job", "job_id", job.ID, "job_state", job.JobState)
+		s.ackMessage(stream, message.ID)
+		return
 	}
 
-	// certain jobs require a specific GPU
+	// jobs are routed to per-GPU streams, so a mismatch means the message was
+	// put on the wrong stream; move it where a matching supervisor will see it
 	if !s.canHandleJob(job) {
-		s.log.Info("skipping job due to GPU mismatch",
+		s.log.Warn("rerouting job due to GPU mismatch",
 			"job_id", job.ID, "required_gpu", job.RequiredGPU, "supervisor_gpu", s.gpuType)
-		// let another supervisor can pick it up
+		target := streamForGPU(job.RequiredGPU)
+		messageID, err := s.redisClient.XAdd(s.workCtx, &redis.XAddArgs{
+			Stream: target,
+			Values: message.Values,
+		}).Result()
+		if err != nil {
+			s.log.Error("failed to reroute job", "job_id", job.ID, "error", err)
+			return
+		}
+		s.redisClient.HSet(s.workCtx, jobKey(job.ID), "stream", target, "message_id", messageID)
+		s.ackMessage(stream, message.ID)
 		return
 	}
 
+	s.assignJob(job.ID)
+	// outputs of an earlier attempt would pass for this one's
+	if err := s.artifacts.Remove(job.ID); err != nil {
+		s.log.Warn("failed to remove artifacts of earlier attempt", "job_id", job.ID, "error", err)
+	}
 	s.emitJobEvent(job.ID, JobStateInProgress)
 
-	success := s.processJob(job)
+	result, err := s.processJob(jobCtx, job)
+	if errors.Is(err, errJobCancelled) {
+		// the scheduler already marked the job cancelled and recorded why
+		s.completeJob(job.ID, JobStateCancelled, result, nil)
+		s.ackMessage(stream, message.ID)
+		s.log.Info("job cancelled", "job_id", job.ID)
+		return
+	}
+	if err != nil {
+		// until the retry or the failure is stored the message stays pending,
+		// so the job can still be reclaimed
+		if s.handleJobFailure(job, result, err) == nil {
+			s.ackMessage(stream, message.ID)
+		}
+		return
+	}
 
-	if success {
-		s.emitJobEvent(job.ID, JobStateSuccess)
-		s.updateJobState(job.ID, JobStateSuccess)
-		s.ackMessage(message.ID)
-		s.log.Info("job completed successfully", "job_id", job.ID)
-	} else {
+	if err := s.completeJob(job.ID, JobStateSuccess, result, nil); errors.Is(err, errJobCancelled) {
+		// cancelled just as it finished, the cancellation stands
+		s.ackMessage(stream, message.ID)
+		return
+	} else if err != nil {
+		return
+	}
+	s.emitJobEvent(job.ID, JobStateSuccess)
+	s.ackMessage(stream, message.ID)
+	s.log.Info("job completed successfully", "job_id", job.ID)
+}
+
+// handleJobFailure either schedules another attempt of a failed job with
+// exponential backoff or, once MaxRetries is exhausted or the failure would
+// only repeat itself, marks it as failed. The last error is always recorded on the job.
+// Returns an error if neither could be stored.
+func (s *Supervisor) handleJobFailure(job Job, result map[string]interface{}, jobErr error) error {
+	var exitErr *exitError
+	var specErr *specError
+	if job.Retries >= MaxRetries || (errors.As(jobErr, &exitErr) && !exitErr.retryable()) || errors.As(jobErr, &specErr) {
+		if err := s.completeJob(job.ID, JobStateFailure, result, jobErr); errors.Is(err, errJobCancelled) {
+			return nil
+		} else if err != nil {
+			return err
+		}
 		s.emitJobEvent(job.ID, JobStateFailure)
-		s.updateJobState(job.ID, JobStateFailure)
-		s.ackMessage(message.ID)
-		s.log.Error("job failed", "job_id", job.ID)
+		s.log.Error("job failed", "job_id", job.ID, "retries", job.Retries, "error", jobErr)
+		return nil
 	}
+
+	job.Retries++
+	delay := retryBackoff(job.Retries)
+
+	// the retry waits among the delayed jobs, so it survives this supervisor
+	// stopping and the dispatcher releases it like any other job once due
+	key := jobKey(job.ID)
+	scheduled := false
+	err := s.redisClient.Watch(s.workCtx, func(tx *redis.Tx) error {
+		state, err := tx.HGet(s.workCtx, key, "job_state").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if isTerminalState(JobState(state)) {
+			return nil
+		}
+		_, err = tx.TxPipelined(s.workCtx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(s.workCtx, key,
+				"retries", job.Retries,
+				"error", jobErr.Error(),
+				"job_state", string(JobStateScheduled),
+			)
+			pipe.ZAdd(s.workCtx, DelayedJobsKey, redis.Z{
+				Score:  float64(time.Now().Add(delay).UnixMilli()),
+				Member: job.ID,
+			})
+			return nil
+		})
+		scheduled = err == nil
+		return err
+	}, key)
+	if err != nil {
+		s.log.Error("failed to schedule job retry", "job_id", job.ID, "error", err)
+		return err
+	}
+	if !scheduled {
+		s.log.Info("not retrying finished job", "job_id", job.ID)
+		return nil
+	}
+	s.emitJobEvent(job.ID, JobStateScheduled)
+
+	s.log.Warn("job failed, scheduling retry",
+		"job_id", job.ID, "attempt", job.Retries, "max_retries", MaxRetries, "delay", delay, "error", jobErr)
+	return nil
 }
 
 // canHandleJob checks if this supervisor can handle the given job based on GPU requirements
@@ -207,75 +681,169 @@ func (s *Supervisor) canHandleJob(job Job) bool {
 	}
 
 	// Job must match supervisor's GPU type
-	return job.RequiredGPU == s.gpuType
+	return strings.EqualFold(job.RequiredGPU, s.gpuType)
 }
 
-// processJob executes the job by starting a container. For CPU jobs only (no GPU).
-// Returns true if the job completed successfully.
-func (s *Supervisor) processJob(job Job) bool {
-	// Only run CPU containers on this machine (no GPU support)
-	if !s.isCPUJob(job) {
-		s.log.Info("skipping container start for GPU job on CPU-only machine", "job_id", job.ID)
-		return true // Ack without running - let GPU supervisor handle
+// errJobCancelled is returned by processJob when the job was cancelled while running.
+var errJobCancelled = errors.New("job cancelled")
+
+// processJob runs the job's workload in a container and waits for it to exit.
+// Cancelling ctx or running past the job's timeout stops the container. Returns the job result and a non-nil
+// error if the job did not complete successfully.
+func (s *Supervisor) processJob(ctx context.Context, job Job) (map[string]interface{}, error) {
+	if ctx.Err() != nil {
+		return nil, errJobCancelled
 	}
 
-	if s.dockerMgr == nil {
-		s.log.Warn("no container manager, simulating job success", "job_id", job.ID)
-		return true
+	spec, err := parseJobSpec(job.Payload)
+	if err != nil {
+		return nil, &specError{err}
 	}
 
 	volumeName := fmt.Sprintf("job_%s_data", job.ID)
-	_, err := s.dockerMgr.CreateVolume(volumeName)
+	containerSpec, err := containerSpecFor(job, spec, volumeName)
+	if err != nil {
+		return nil, &specError{err}
+	}
+	timeout, err := jobTimeout(job, spec)
+	if err != nil {
+		return nil, &specError{err}
+	}
+	if len(spec.Inputs) > 0 {
+		inputs := s.uploads.Archive(spec.Inputs)
+		defer inputs.Close()
+		containerSpec.Inputs = inputs
+	}
+
+	_, err = s.runtime.CreateVolume(volumeName)
 	if err != nil {
 		s.log.Error("failed to create volume for job", "job_id", job.ID, "error", err)
-		return false
+		return nil, fmt.Errorf("create volume: %w", err)
 	}
+	defer func() {
+		if err := s.runtime.RemoveVolume(volumeName, true); err != nil {
+			s.log.Warn("failed to remove volume", "job_id", job.ID, "volume", volumeName, "error", err)
+		}
+	}()
 
-	containerID, err := s.dockerMgr.RunContainer(CPUImage, CPURuntime, volumeName)
+	containerID, err := s.runtime.RunContainer(containerSpec)
 	if err != nil {
 		s.log.Error("failed to run container for job", "job_id", job.ID, "error", err)
-		_ = s.dockerMgr.RemoveVolume(volumeName, true)
-		return false
+		return nil, fmt.Errorf("run container: %w", err)
 	}
+	defer func() {
+		if err := s.runtime.RemoveContainer(containerID); err != nil {
+			s.log.Error("failed to remove container", "job_id", job.ID, "container_id", containerID, "error", err)
+		}
+	}()
+
+	s.markJobStarted(job.ID)
+	s.log.Info("job container started", "job_id", job.ID, "container_id", containerID, "image", containerSpec.Image)
+	logsDone := s.captureLogs(job.ID, containerID)
+
+	exited := make(chan struct{})
+	defer close(exited)
+	var timedOut atomic.Bool
+	go func() {
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-expired:
+			s.log.Warn("job timed out, stopping container", "job_id", job.ID, "container_id", containerID, "timeout", timeout)
+			timedOut.Store(true)
+		case <-exited:
+			return
+		}
+		if err := s.runtime.StopContainer(containerID); err != nil {
+			s.log.Error("failed to stop container", "job_id", job.ID, "container_id", containerID, "error", err)
+		}
+	}()
 
-	// Run for a short time to simulate work, then clean up
-	time.Sleep(2 * time.Second)
+	exitCode, err := s.runtime.WaitContainer(containerID)
 
-	if err := s.dockerMgr.StopContainer(containerID); err != nil {
-		s.log.Error("failed to stop container", "job_id", job.ID, "container_id", containerID, "error", err)
+	// let the last output reach the log before the job is reported finished
+	select {
+	case <-logsDone:
+	case <-time.After(logDrainTimeout):
+		s.log.Warn("timed out storing job logs", "job_id", job.ID, "container_id", containerID)
 	}
-	if err := s.dockerMgr.RemoveContainer(containerID); err != nil {
-		s.log.Error("failed to remove container", "job_id", job.ID, "container_id", containerID, "error", err)
+	if ctx.Err() != nil {
+		return nil, errJobCancelled
 	}
-	if err := s.dockerMgr.RemoveVolume(volumeName, true); err != nil {
-		s.log.Warn("failed to remove volume", "job_id", job.ID, "volume", volumeName, "error", err)
+	if err != nil {
+		return nil, fmt.Errorf("wait for container: %w", err)
 	}
 
-	s.log.Info("job container completed", "job_id", job.ID, "container_id", containerID)
-	return true
-}
+	info := docker.ExitInfo{ExitCode: exitCode}
+	if exitCode != 0 || timedOut.Load() {
+		if info, err = s.runtime.InspectExit(containerID); err != nil {
+			s.log.Warn("failed to inspect exited container", "job_id", job.ID, "container_id", containerID, "error", err)
+			info = docker.ExitInfo{ExitCode: exitCode}
+		}
+	}
+	status := exitStatus(info, timedOut.Load(), units.BytesSize(float64(containerSpec.Memory)), timeout.String())
+	result := make(map[string]interface{})
+	status.recordIn(result)
+	s.log.Info("job container exited", "job_id", job.ID, "container_id", containerID,
+		"exit_code", status.ExitCode, "reason", status.Reason, "signal", status.Signal)
+
+	// keep outputs of failed runs too, they help to find out what went wrong
+	if spec.OutputDir != "" {
+		artifacts, err := s.saveArtifacts(job.ID, containerID, spec.OutputDir)
+		if err != nil {
+			s.log.Warn("failed to save job artifacts", "job_id", job.ID, "output_dir", spec.OutputDir, "error", err)
+			result["artifacts_error"] = err.Error()
+		} else {
+			result["artifacts"] = len(artifacts)
+		}
+	}
 
-// isCPUJob returns true if the job can run on CPU (no GPU required).
-func (s *Supervisor) isCPUJob(job Job) bool {
-	switch job.RequiredGPU {
-	case "", "CPU":
-		return true
-	default:
-		return false
+	if status.Reason != "" {
+		return result, &exitError{status: status}
 	}
+	return result, nil
 }
 
+// saveArtifacts copies dir out of the exited container into the artifact store.
+func (s *Supervisor) saveArtifacts(jobID, containerID, dir string) ([]Artifact, error) {
+	archive, err := s.runtime.CopyFromContainer(containerID, dir)
+	if err != nil {
+		return nil, fmt.Errorf("copy %s from container: %w", dir, err)
+	}
+	defer archive.Close()
+
+	artifacts, err := s.artifacts.Save(jobID, archive)
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("saved job artifacts", "job_id", jobID, "files", len(artifacts))
+	return artifacts, nil
+}
 
 func (s *Supervisor) emitJobEvent(jobID string, state JobState) {
+	s.emitJobEventWith(jobID, state, nil)
+}
+
+// emitJobEventWith emits a job event carrying additional fields, e.g. to
+// record a handover between supervisors in the job's event history.
+func (s *Supervisor) emitJobEventWith(jobID string, state JobState, extra map[string]interface{}) {
 	event := map[string]interface{}{
-		"job_id":  jobID,
-		"state":  string(state),
+		"job_id":     jobID,
+		"state":      string(state),
 		"timestamp":  time.Now().Format(time.RFC3339),
 		"supervisor": s.consumerID,
 		"gpu_type":   s.gpuType,
 	}
+	for k, v := range extra {
+		event[k] = v
+	}
 
-	if err := s.redisClient.XAdd(s.ctx, &redis.XAddArgs{
+	if err := s.redisClient.XAdd(s.workCtx, &redis.XAddArgs{
 		Stream: JobEventStream,
 		Values: event,
 	}).Err(); err != nil {
@@ -285,24 +853,101 @@ func (s *Supervisor) emitJobEvent(jobID string, state JobState) {
 	}
 }
 
+// assignJob records on the job that this supervisor has picked it up,
+// clearing timestamps left behind by a previous attempt.
+func (s *Supervisor) assignJob(jobID string) {
+	key := jobKey(jobID)
+	pipe := s.redisClient.TxPipeline()
+	pipe.HSet(s.workCtx, key,
+		"job_state", string(JobStateInProgress),
+		"consumer_id", s.consumerID,
+		"time_assigned", time.Now().Format(time.RFC3339Nano),
+	)
+	pipe.HDel(s.workCtx, key, "time_started", "time_completed")
+	if _, err := pipe.Exec(s.workCtx); err != nil {
+		s.log.Error("failed to record job assignment", "job_id", jobID, "error", err)
+	}
+}
 
-func (s *Supervisor) updateJobState(jobID string, state JobState) {
-	jobKey := fmt.Sprintf("job:%s", jobID)
-	if err := s.redisClient.HSet(s.ctx, jobKey, "job_state", string(state)).Err(); err != nil {
-		s.log.Error("failed to update job state", "job_id", jobID, "error", err)
+// markJobStarted records when the job's workload actually started running.
+func (s *Supervisor) markJobStarted(jobID string) {
+	if err := s.redisClient.HSet(s.workCtx, jobKey(jobID), "time_started", time.Now().Format(time.RFC3339Nano)).Err(); err != nil {
+		s.log.Error("failed to record job start", "job_id", jobID, "error", err)
 	}
 }
 
-func (s *Supervisor) ackMessage(messageID string) {
-	result := s.redisClient.XAck(s.ctx, StreamName, ConsumerGroup, messageID)
+// completeJob records the final state of a job together with its result and,
+// for failures, the error that caused it. It returns errJobCancelled instead
+// if the job was cancelled in the meantime, leaving the cancellation in place.
+func (s *Supervisor) completeJob(jobID string, state JobState, result map[string]interface{}, jobErr error) error {
+	fields := map[string]interface{}{
+		"job_state":      string(state),
+		"time_completed": time.Now().Format(time.RFC3339Nano),
+	}
+	if result != nil {
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			s.log.Error("failed to marshal job result", "job_id", jobID, "error", err)
+		} else {
+			fields["result"] = string(resultJSON)
+		}
+	}
+	if jobErr != nil {
+		fields["error"] = jobErr.Error()
+	}
+
+	key := jobKey(jobID)
+	err := s.redisClient.Watch(s.workCtx, func(tx *redis.Tx) error {
+		current, err := tx.HGet(s.workCtx, key, "job_state").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if JobState(current) == JobStateCancelled && state != JobStateCancelled {
+			return errJobCancelled
+		}
+
+		_, err = tx.TxPipelined(s.workCtx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(s.workCtx, key, fields)
+			if state == JobStateSuccess {
+				// drop errors of earlier failed attempts
+				pipe.HDel(s.workCtx, key, "error")
+			}
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, errJobCancelled) {
+		s.log.Info("not overwriting cancelled job", "job_id", jobID, "state", state)
+		return err
+	}
+	if err != nil {
+		s.log.Error("failed to record job completion", "job_id", jobID, "state", state, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Supervisor) ackMessage(stream, messageID string) {
+	result := s.redisClient.XAck(s.workCtx, stream, ConsumerGroup, messageID)
 	if result.Err() != nil {
 		s.log.Error("failed to ack message", "message_id", messageID, "error", result.Err())
 	}
 }
 
+// Stop stops taking new jobs, waits for in-flight jobs to finish and then
+// shuts the supervisor down.
 func (s *Supervisor) Stop() {
 	s.log.Info("stopping supervisor", "consumer_id", s.consumerID)
 	s.cancel()
+	// no job may be added once jobs.Wait has started
+	s.jobsMu.Lock()
+	s.stopping = true
+	s.jobsMu.Unlock()
+	s.jobs.Wait()
+	s.stopWork()
 	s.wg.Wait()
+	if err := s.reportStatus(SupervisorStateInactive); err != nil {
+		s.log.Error("failed to mark supervisor inactive", "consumer_id", s.consumerID, "error", err)
+	}
 	s.redisClient.Close()
 }
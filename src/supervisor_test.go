@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"mist/docker"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, RetryDelay},
+		{1, RetryDelay},
+		{2, 2 * RetryDelay},
+		{3, 4 * RetryDelay},
+	}
+
+	for _, c := range cases {
+		if got := retryBackoff(c.attempt); got != c.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestStreamForGPU(t *testing.T) {
+	cases := map[string]string{
+		"":    StreamName,
+		"AMD": StreamName + ":AMD",
+		"tt":  StreamName + ":TT",
+		"CPU": StreamName + ":CPU",
+	}
+
+	for gpu, want := range cases {
+		if got := streamForGPU(gpu); got != want {
+			t.Errorf("streamForGPU(%q) = %q, want %q", gpu, got, want)
+		}
+	}
+}
+
+func TestSupervisorStreams(t *testing.T) {
+	if got := supervisorStreams(""); len(got) != 1 || got[0] != StreamName {
+		t.Errorf("supervisorStreams(\"\") = %v, want [%s]", got, StreamName)
+	}
+
+	got := supervisorStreams("AMD")
+	if len(got) != 2 || got[0] != StreamName+":AMD" || got[1] != StreamName {
+		t.Errorf("supervisorStreams(\"AMD\") = %v", got)
+	}
+}
+
+// newTestSupervisor returns a supervisor running jobs on rt, with stores in
+// temporary directories. Its Redis is unreachable, so recording job state and
+// logs fails without affecting processJob.
+func newTestSupervisor(t *testing.T, rt Runtime) *Supervisor {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewSupervisor("127.0.0.1:1", "test_worker", "CPU", log,
+		WithRuntime(rt),
+		WithArtifactStore(NewArtifactStore(t.TempDir(), 1<<20)),
+		WithUploadStore(NewUploadStore(t.TempDir(), 1<<20)))
+	t.Cleanup(func() { s.redisClient.Close() })
+	return s
+}
+
+func TestProcessJobRunsContainer(t *testing.T) {
+	rt := newFakeRuntime(nil)
+	s := newTestSupervisor(t, rt)
+	digest, _, err := s.uploads.Put(strings.NewReader("print('hi')\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := Job{ID: "job_1", Payload: map[string]interface{}{
+		"args":   []interface{}{"python", "/data/train.py"},
+		"inputs": []interface{}{map[string]interface{}{"path": "train.py", "sha256": digest}},
+	}}
+	result, err := s.processJob(context.Background(), job)
+	if err != nil {
+		t.Fatalf("processJob failed: %v", err)
+	}
+	if result["exit_code"] != int64(0) || result["reason"] != nil {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	if len(rt.started) != 1 {
+		t.Fatalf("expected one container, got %d", len(rt.started))
+	}
+	c := rt.started[0]
+	if c.spec.Image != CPUImage || c.spec.Volume != "job_job_1_data" || c.spec.Memory != 4<<30 {
+		t.Errorf("unexpected container spec %+v", c.spec)
+	}
+	if c.inputs["train.py"] != "print('hi')\n" {
+		t.Errorf("inputs not copied into the container: %v", c.inputs)
+	}
+	if containers, volumes := rt.leftovers(); containers != 0 || volumes != 0 {
+		t.Errorf("expected container and volume to be removed, %d containers and %d volumes left", containers, volumes)
+	}
+}
+
+func TestProcessJobFailureReasons(t *testing.T) {
+	tests := []struct {
+		name   string
+		run    fakeRun
+		reason string
+	}{
+		{"exit code", fakeRun{ExitCode: 1}, FailureExit},
+		{"segfault", fakeRun{ExitCode: 139}, FailureSignal},
+		{"out of memory", fakeRun{ExitCode: 137, OOMKilled: true}, FailureOOM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newFakeRuntime(func(docker.ContainerSpec) fakeRun { return tt.run })
+			s := newTestSupervisor(t, rt)
+
+			result, err := s.processJob(context.Background(), Job{ID: "job_1", Payload: map[string]interface{}{"args": []interface{}{"true"}}})
+			var exitErr *exitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("expected an exitError, got %v", err)
+			}
+			if result["reason"] != tt.reason || exitErr.status.Reason != tt.reason {
+				t.Errorf("expected reason %q, got result %v and error %q", tt.reason, result, err)
+			}
+		})
+	}
+}
+
+func TestProcessJobRejectsBadSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+	}{
+		{"bad spec", Job{ID: "job_1", Payload: map[string]interface{}{"args": "true"}}},
+		{"unsupported gpu", Job{ID: "job_1", RequiredGPU: "TPU", Payload: map[string]interface{}{"args": []interface{}{"true"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newFakeRuntime(nil)
+			s := newTestSupervisor(t, rt)
+
+			_, err := s.processJob(context.Background(), tt.job)
+			var specErr *specError
+			if !errors.As(err, &specErr) {
+				t.Fatalf("expected a specError, got %v", err)
+			}
+			if len(rt.started) != 0 {
+				t.Error("expected no container to be started")
+			}
+		})
+	}
+}
+
+func TestProcessJobTimeout(t *testing.T) {
+	rt := newFakeRuntime(func(docker.ContainerSpec) fakeRun { return fakeRun{Block: true} })
+	s := newTestSupervisor(t, rt)
+
+	job := Job{ID: "job_1", Payload: map[string]interface{}{
+		"args":      []interface{}{"sleep", "1000"},
+		"resources": map[string]interface{}{"timeout": "50ms"},
+	}}
+	result, err := s.processJob(context.Background(), job)
+	if err == nil || result["reason"] != FailureTimeout {
+		t.Fatalf("expected a timeout, got result %v and error %v", result, err)
+	}
+	if !rt.started[0].stopped {
+		t.Error("expected the container to be stopped")
+	}
+}
+
+func TestProcessJobCancelled(t *testing.T) {
+	rt := newFakeRuntime(func(docker.ContainerSpec) fakeRun { return fakeRun{Block: true} })
+	s := newTestSupervisor(t, rt)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := s.processJob(ctx, Job{ID: "job_1", Payload: map[string]interface{}{"args": []interface{}{"sleep", "1000"}}})
+	if !errors.Is(err, errJobCancelled) {
+		t.Fatalf("expected errJobCancelled, got %v", err)
+	}
+	if containers, volumes := rt.leftovers(); containers != 0 || volumes != 0 {
+		t.Errorf("expected container and volume to be removed, %d containers and %d volumes left", containers, volumes)
+	}
+}
+
+func TestProcessJobSavesArtifacts(t *testing.T) {
+	rt := newFakeRuntime(func(docker.ContainerSpec) fakeRun {
+		return fakeRun{ExitCode: 1, Files: map[string]string{
+			"/data/outputs/model.pt": "weights",
+			"/data/train.py":         "not an output",
+		}}
+	})
+	s := newTestSupervisor(t, rt)
+
+	job := Job{ID: "job_1", Payload: map[string]interface{}{"args": []interface{}{"true"}, "output_dir": "/data/outputs"}}
+	result, err := s.processJob(context.Background(), job)
+	if err == nil {
+		t.Fatal("expected the job to fail")
+	}
+	if result["artifacts"] != 1 {
+		t.Errorf("expected one artifact in result, got %v", result)
+	}
+	artifacts, err := s.artifacts.List("job_1")
+	if err != nil || len(artifacts) != 1 || artifacts[0].Path != "model.pt" {
+		t.Errorf("unexpected artifacts %v, %v", artifacts, err)
+	}
+}
+
+func TestSupervisorStartWithoutRuntime(t *testing.T) {
+	t.Setenv("MIST_RUNTIME", "vm")
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewSupervisor("127.0.0.1:1", "test_worker", "CPU", log)
+	defer s.redisClient.Close()
+
+	if err := s.Start(); err == nil || !strings.Contains(err.Error(), "MIST_RUNTIME") {
+		t.Errorf("expected Start to fail naming MIST_RUNTIME, got %v", err)
+	}
+}
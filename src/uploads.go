@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Files a job needs, such as its script or a small dataset, are uploaded
+// before it is submitted and stored under their SHA-256 digest, so the same
+// file uploaded twice is only kept once. The job's spec lists them as inputs
+// with the path under /data they are copied to before the container starts.
+
+const (
+	DefaultUploadDir      = "uploads"
+	DefaultMaxUploadBytes = 100 << 20
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrUploadTooLarge = errors.New("upload too large")
+	ErrDigestMismatch = errors.New("upload does not match its digest")
+)
+
+var digestPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// JobInput is an uploaded file to copy into the job's volume.
+type JobInput struct {
+	Path   string `json:"path"` // relative to /data
+	SHA256 string `json:"sha256"`
+}
+
+// validate checks that the input names an upload and stays inside /data.
+func (in JobInput) validate() error {
+	if !digestPattern.MatchString(in.SHA256) {
+		return fmt.Errorf("input %q: invalid sha256 %q", in.Path, in.SHA256)
+	}
+	clean := path.Clean(in.Path)
+	if in.Path == "" || path.IsAbs(in.Path) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("input path %q must be relative to /data", in.Path)
+	}
+	return nil
+}
+
+// UploadStore keeps uploaded files in a local directory, named by digest.
+type UploadStore struct {
+	dir      string
+	maxBytes int64
+}
+
+func NewUploadStore(dir string, maxBytes int64) *UploadStore {
+	return &UploadStore{dir: dir, maxBytes: maxBytes}
+}
+
+// uploadStoreFromEnv returns the store in MIST_UPLOAD_DIR, accepting files
+// of up to MIST_UPLOAD_MAX_MB.
+func uploadStoreFromEnv() *UploadStore {
+	dir := os.Getenv("MIST_UPLOAD_DIR")
+	if dir == "" {
+		dir = DefaultUploadDir
+	}
+	return NewUploadStore(dir, int64(envInt("MIST_UPLOAD_MAX_MB", DefaultMaxUploadBytes>>20))<<20)
+}
+
+func (st *UploadStore) path(digest string) string {
+	return filepath.Join(st.dir, digest)
+}
+
+// Put stores the content of r and returns its digest and size. If want is
+// not empty the content must have that digest.
+func (st *UploadStore) Put(r io.Reader, want string) (string, int64, error) {
+	if err := os.MkdirAll(st.dir, 0o755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(st.dir, "upload-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, st.maxBytes+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if size > st.maxBytes {
+		return "", 0, fmt.Errorf("%w: more than %d bytes", ErrUploadTooLarge, st.maxBytes)
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if want != "" && want != digest {
+		return "", 0, fmt.Errorf("%w: got %s", ErrDigestMismatch, digest)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), st.path(digest)); err != nil {
+		return "", 0, err
+	}
+	return digest, size, nil
+}
+
+// Stat returns the size of the upload with digest.
+func (st *UploadStore) Stat(digest string) (int64, error) {
+	if !digestPattern.MatchString(digest) {
+		return 0, ErrUploadNotFound
+	}
+	info, err := os.Stat(st.path(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrUploadNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Archive returns a tar archive placing each input at its path, to be
+// unpacked into /data. Its directories are made accessible to any user.
+// Closing it before the end stops writing the archive.
+func (st *UploadStore) Archive(inputs []JobInput) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(st.writeArchive(pw, inputs))
+	}()
+	return pr
+}
+
+func (st *UploadStore) writeArchive(w io.Writer, inputs []JobInput) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	dirs := make(map[string]bool)
+	for _, in := range inputs {
+		name := path.Clean(in.Path)
+		var parents []string
+		for dir := path.Dir(name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			parents = append(parents, dir)
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: parents[i] + "/", Mode: 0o777, ModTime: now}); err != nil {
+				return err
+			}
+		}
+
+		if err := st.writeFile(tw, name, in.SHA256, now); err != nil {
+			return fmt.Errorf("input %s: %w", in.Path, err)
+		}
+	}
+	return tw.Close()
+}
+
+func (st *UploadStore) writeFile(tw *tar.Writer, name, digest string, modTime time.Time) error {
+	f, err := os.Open(st.path(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrUploadNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: info.Size(), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestJobInputValidate(t *testing.T) {
+	digest := strings.Repeat("a", 64)
+	for _, tc := range []struct {
+		input JobInput
+		valid bool
+	}{
+		{JobInput{Path: "train.py", SHA256: digest}, true},
+		{JobInput{Path: "datasets/train.csv", SHA256: digest}, true},
+		{JobInput{Path: "", SHA256: digest}, false},
+		{JobInput{Path: "/etc/passwd", SHA256: digest}, false},
+		{JobInput{Path: "../escape", SHA256: digest}, false},
+		{JobInput{Path: "a/../../escape", SHA256: digest}, false},
+		{JobInput{Path: "train.py", SHA256: "abc"}, false},
+		{JobInput{Path: "train.py", SHA256: strings.Repeat("A", 64)}, false},
+	} {
+		if err := tc.input.validate(); (err == nil) != tc.valid {
+			t.Errorf("validate(%+v) = %v, want valid %v", tc.input, err, tc.valid)
+		}
+	}
+}
+
+func TestUploadStorePut(t *testing.T) {
+	store := NewUploadStore(t.TempDir(), 16)
+	sum := sha256.Sum256([]byte("print('hi')"))
+	want := hex.EncodeToString(sum[:])
+
+	digest, size, err := store.Put(strings.NewReader("print('hi')"), "")
+	if err != nil || digest != want || size != 11 {
+		t.Fatalf("Put: got %s, %d, %v", digest, size, err)
+	}
+	// the same content is stored under the same name
+	if again, _, err := store.Put(strings.NewReader("print('hi')"), want); err != nil || again != digest {
+		t.Errorf("Expected the same digest, got %s, %v", again, err)
+	}
+	if size, err := store.Stat(digest); err != nil || size != 11 {
+		t.Errorf("Stat: got %d, %v", size, err)
+	}
+
+	if _, _, err := store.Put(strings.NewReader("print('bye')"), want); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Expected ErrDigestMismatch, got %v", err)
+	}
+	if _, _, err := store.Put(strings.NewReader(strings.Repeat("x", 17)), ""); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("Expected ErrUploadTooLarge, got %v", err)
+	}
+	if _, err := store.Stat(strings.Repeat("0", 64)); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound, got %v", err)
+	}
+	if _, err := store.Stat("../" + digest); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound for a malformed digest, got %v", err)
+	}
+}
+
+func TestUploadStoreArchive(t *testing.T) {
+	store := NewUploadStore(t.TempDir(), DefaultMaxUploadBytes)
+	script, _, _ := store.Put(strings.NewReader("print('hi')"), "")
+	data, _, _ := store.Put(strings.NewReader("a,b\n"), "")
+
+	archive := store.Archive([]JobInput{
+		{Path: "train.py", SHA256: script},
+		{Path: "data/sets/train.csv", SHA256: data},
+		{Path: "data/test.csv", SHA256: data},
+	})
+	defer archive.Close()
+
+	var names []string
+	contents := map[string]string{}
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		names = append(names, hdr.Name)
+		body, _ := io.ReadAll(tr)
+		contents[hdr.Name] = string(body)
+	}
+
+	want := []string{"train.py", "data/", "data/sets/", "data/sets/train.csv", "data/test.csv"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected entries %v, got %v", want, names)
+	}
+	if contents["train.py"] != "print('hi')" || contents["data/test.csv"] != "a,b\n" {
+		t.Errorf("Unexpected contents %v", contents)
+	}
+
+	missing := store.Archive([]JobInput{{Path: "x", SHA256: strings.Repeat("0", 64)}})
+	defer missing.Close()
+	if _, err := io.ReadAll(missing); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound, got %v", err)
+	}
+}
@@ -1,45 +1,70 @@
 package main
 
 import (
-	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	StreamName          = "jobs:stream"
 	ConsumerGroup       = "workers"
-	JobEventStream		= "jobs:events"
+	JobEventStream      = "jobs:events"
 	SupervisorStatusKey = "supervisors:status"
-	JobStatusKey        = "jobs:status"
+	JobIndexKey         = "jobs:index"
+	CancelChannel       = "jobs:cancel"
 	MaxRetries          = 3
 	RetryDelay          = 5 * time.Second
+	ClaimIdleTimeout    = 5 * time.Minute
+	HeartbeatInterval   = 10 * time.Second
+	SupervisorTimeout   = 3 * HeartbeatInterval
+	DefaultConcurrency  = 1
 )
 
 type JobState string
 
 const (
+	JobStateWaiting    JobState = "Waiting" // for the jobs it depends on
 	JobStateScheduled  JobState = "Scheduled"
 	JobStateInProgress JobState = "InProgress"
 	JobStateSuccess    JobState = "Success"
 	JobStateError      JobState = "Error"
 	JobStateFailure    JobState = "Failure"
+	JobStateCancelled  JobState = "Cancelled"
 )
 
+// isTerminalState reports whether a job in state will not run again.
+func isTerminalState(state JobState) bool {
+	switch state {
+	case JobStateSuccess, JobStateFailure, JobStateCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
 type Job struct {
-	ID           	 string                 `json:"id"`
-	Type         	 string                 `json:"type"`
-	Payload      	 map[string]interface{} `json:"payload"`
-	Retries      	 int                    `json:"retries"`
-	Created      	 time.Time              `json:"created"`
-	RequiredGPU  	 string                 `json:"required_gpu,omitempty"`
-	JobState     	 JobState               `json:"job_state"`
-	ConsumerID	     *string				`json:"consumer_id,omitempty"`
-	TimeAssigned     *time.Time				`json:"time_assigned,omitempty"`			
-	TimeStarted		 *time.Time				`json:"time_started,omitempty"` 
-	TimeCompleted	 *time.Time				`json:"time_completed,omitempty"`
-	Result		     map[string]interface{}	`json:"result,omitempty"`
-	Error		     *string				`json:"error,omitempty"`
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	Payload       map[string]interface{} `json:"payload"`
+	Retries       int                    `json:"retries"`
+	Created       time.Time              `json:"created"`
+	RequiredGPU   string                 `json:"required_gpu,omitempty"`
+	Owner         string                 `json:"owner,omitempty"`
+	Team          string                 `json:"team,omitempty"`
+	Priority      JobPriority            `json:"priority,omitempty"`
+	RunAfter      *time.Time             `json:"run_after,omitempty"`
+	Schedule      string                 `json:"schedule_id,omitempty"`
+	DependsOn     []string               `json:"depends_on,omitempty"`
+	Workflow      string                 `json:"workflow_id,omitempty"`
+	JobState      JobState               `json:"job_state"`
+	ConsumerID    *string                `json:"consumer_id,omitempty"`
+	TimeAssigned  *time.Time             `json:"time_assigned,omitempty"`
+	TimeStarted   *time.Time             `json:"time_started,omitempty"`
+	TimeCompleted *time.Time             `json:"time_completed,omitempty"`
+	Result        map[string]interface{} `json:"result,omitempty"`
+	Error         *string                `json:"error,omitempty"`
 }
 
 type SupervisorState string
@@ -58,6 +83,32 @@ type SupervisorStatus struct {
 	StartedAt  time.Time       `json:"started_at"`
 }
 
-func generateJobID() string {
-	return fmt.Sprintf("job_%d_%d", time.Now().UnixNano(), os.Getpid())
+// streamForGPU returns the stream jobs requiring gpuType are enqueued on. Each
+// accelerator type gets its own stream so that a job is only ever delivered to
+// supervisors that can run it; jobs without a requirement use StreamName,
+// which every supervisor consumes.
+func streamForGPU(gpuType string) string {
+	if gpuType == "" {
+		return StreamName
+	}
+	return StreamName + ":" + strings.ToUpper(gpuType)
+}
+
+// retryBackoff returns how long to wait before the given retry attempt
+// (starting at 1), doubling RetryDelay on every attempt.
+func retryBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return RetryDelay * time.Duration(1<<(attempt-1))
+}
+
+// envInt returns the integer value of the environment variable name, or def
+// if it is unset or not a valid integer.
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
 }
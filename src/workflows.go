@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A workflow submits a set of named jobs at once, with dependencies between
+// them given by name. The jobs are ordinary jobs with depends_on and
+// workflow_id set; the workflow hash at workflow:<id> only remembers which
+// job each name became, so its state is always read from the jobs.
+
+var (
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	ErrInvalidWorkflow  = errors.New("invalid workflow")
+)
+
+// WorkflowStep is one job of a workflow submission.
+type WorkflowStep struct {
+	Name      string
+	Job       Job
+	DependsOn []string // names of other steps
+}
+
+// WorkflowJob is the state of one job of a workflow.
+type WorkflowJob struct {
+	Name      string   `json:"name"`
+	JobID     string   `json:"job_id"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	JobState  JobState `json:"job_state"`
+}
+
+type Workflow struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name,omitempty"`
+	Owner    string        `json:"owner,omitempty"`
+	Created  time.Time     `json:"created"`
+	JobState JobState      `json:"job_state"`
+	Jobs     []WorkflowJob `json:"jobs"`
+}
+
+func workflowKey(id string) string {
+	return fmt.Sprintf("workflow:%s", id)
+}
+
+// planWorkflow checks that steps form a graph without cycles and returns
+// the order to submit them in, every step after the steps it depends on.
+func planWorkflow(steps []WorkflowStep) ([]int, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%w: no jobs", ErrInvalidWorkflow)
+	}
+
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("%w: job %d has no name", ErrInvalidWorkflow, i)
+		}
+		if _, ok := index[step.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate job name %q", ErrInvalidWorkflow, step.Name)
+		}
+		index[step.Name] = i
+	}
+
+	// Kahn's algorithm, taking ready steps in submission order
+	remaining := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	for i, step := range steps {
+		for _, dep := range step.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q depends on unknown job %q", ErrInvalidWorkflow, step.Name, dep)
+			}
+			if j == i {
+				return nil, fmt.Errorf("%w: %q depends on itself", ErrInvalidWorkflow, step.Name)
+			}
+			remaining[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var ready, order []int
+	for i := range steps {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, d := range dependents[i] {
+			if remaining[d]--; remaining[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) != len(steps) {
+		return nil, fmt.Errorf("%w: dependencies form a cycle", ErrInvalidWorkflow)
+	}
+	return order, nil
+}
+
+// workflowState sums up the states of the jobs of a workflow: Scheduled or
+// Waiting until one of them starts, InProgress while any is unfinished, and
+// once all are done Success, or Failure or Cancelled if any job ended so.
+func workflowState(states []JobState) JobState {
+	started, unfinished, failed, cancelled := false, false, false, false
+	waiting := true
+	for _, state := range states {
+		switch state {
+		case JobStateWaiting:
+			unfinished = true
+		case JobStateScheduled:
+			unfinished = true
+			waiting = false
+		case JobStateFailure:
+			started, failed = true, true
+		case JobStateCancelled:
+			cancelled = true
+		case JobStateSuccess:
+			started = true
+		default:
+			started, unfinished = true, true
+		}
+	}
+
+	switch {
+	case unfinished && started:
+		return JobStateInProgress
+	case unfinished && waiting:
+		return JobStateWaiting
+	case unfinished:
+		return JobStateScheduled
+	case failed:
+		return JobStateFailure
+	case cancelled:
+		return JobStateCancelled
+	}
+	return JobStateSuccess
+}
+
+// SubmitWorkflow enqueues the steps of a workflow for owner, each after the
+// steps it depends on. If a job cannot be enqueued the jobs submitted before
+// it are cancelled.
+func (s *Scheduler) SubmitWorkflow(name, owner string, steps []WorkflowStep) (*Workflow, error) {
+	order, err := planWorkflow(steps)
+	if err != nil {
+		return nil, err
+	}
+
+	wf := &Workflow{
+		ID:      generateWorkflowID(),
+		Name:    name,
+		Owner:   owner,
+		Created: time.Now(),
+		Jobs:    make([]WorkflowJob, len(steps)),
+	}
+	ids := make(map[string]string, len(steps))
+
+	for _, i := range order {
+		step := steps[i]
+		job := step.Job
+		job.Workflow = wf.ID
+		job.DependsOn = append([]string(nil), job.DependsOn...)
+		for _, dep := range step.DependsOn {
+			job.DependsOn = append(job.DependsOn, ids[dep])
+		}
+
+		jobID, err := s.EnqueueJob(job)
+		if err != nil {
+			for _, submitted := range ids {
+				if err := s.cancelJob(submitted, "workflow submission failed"); err != nil {
+					s.log.Warn("failed to cancel workflow job", "workflow_id", wf.ID, "job_id", submitted, "error", err)
+				}
+			}
+			return nil, fmt.Errorf("failed to submit job %q: %w", step.Name, err)
+		}
+		ids[step.Name] = jobID
+		wf.Jobs[i] = WorkflowJob{Name: step.Name, JobID: jobID, DependsOn: step.DependsOn}
+	}
+
+	jobs, err := json.Marshal(wf.Jobs)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.HSet(s.ctx, workflowKey(wf.ID),
+		"name", wf.Name,
+		"owner", wf.Owner,
+		"created", wf.Created.Format(time.RFC3339Nano),
+		"jobs", string(jobs),
+	).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store workflow: %w", err)
+	}
+
+	s.log.Info("workflow submitted", "workflow_id", wf.ID, "name", name, "owner", owner, "jobs", len(steps))
+	return s.GetWorkflow(wf.ID)
+}
+
+// GetWorkflow returns a workflow with the current state of its jobs.
+func (s *Scheduler) GetWorkflow(id string) (*Workflow, error) {
+	fields, err := s.client.HGetAll(s.ctx, workflowKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrWorkflowNotFound
+	}
+
+	wf := &Workflow{ID: id, Name: fields["name"], Owner: fields["owner"]}
+	if created := parseOptionalTime(fields["created"]); created != nil {
+		wf.Created = *created
+	}
+	if err := json.Unmarshal([]byte(fields["jobs"]), &wf.Jobs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow jobs: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(wf.Jobs))
+	for i, job := range wf.Jobs {
+		cmds[i] = pipe.HGet(s.ctx, jobKey(job.JobID), "job_state")
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	states := make([]JobState, len(wf.Jobs))
+	for i, cmd := range cmds {
+		wf.Jobs[i].JobState = JobState(cmd.Val())
+		states[i] = wf.Jobs[i].JobState
+	}
+	wf.JobState = workflowState(states)
+	return wf, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlanWorkflow(t *testing.T) {
+	steps := []WorkflowStep{
+		{Name: "evaluate", DependsOn: []string{"train"}},
+		{Name: "train", DependsOn: []string{"preprocess"}},
+		{Name: "preprocess"},
+		{Name: "report", DependsOn: []string{"evaluate", "preprocess"}},
+	}
+	order, err := planWorkflow(steps)
+	if err != nil {
+		t.Fatalf("planWorkflow failed: %v", err)
+	}
+
+	position := map[string]int{}
+	for i, step := range order {
+		position[steps[step].Name] = i
+	}
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			if position[dep] > position[step.Name] {
+				t.Errorf("%s is submitted before its dependency %s: %v", step.Name, dep, order)
+			}
+		}
+	}
+}
+
+func TestPlanWorkflowRejectsBadGraphs(t *testing.T) {
+	graphs := map[string][]WorkflowStep{
+		"empty":     nil,
+		"no name":   {{Name: ""}},
+		"duplicate": {{Name: "a"}, {Name: "a"}},
+		"unknown":   {{Name: "a", DependsOn: []string{"b"}}},
+		"self":      {{Name: "a", DependsOn: []string{"a"}}},
+		"cycle": {
+			{Name: "a", DependsOn: []string{"c"}},
+			{Name: "b", DependsOn: []string{"a"}},
+			{Name: "c", DependsOn: []string{"b"}},
+		},
+	}
+	for name, steps := range graphs {
+		if _, err := planWorkflow(steps); !errors.Is(err, ErrInvalidWorkflow) {
+			t.Errorf("%s: expected ErrInvalidWorkflow, got %v", name, err)
+		}
+	}
+}
+
+func TestWorkflowState(t *testing.T) {
+	tests := []struct {
+		states []JobState
+		want   JobState
+	}{
+		{[]JobState{JobStateScheduled, JobStateWaiting}, JobStateScheduled},
+		{[]JobState{JobStateInProgress, JobStateWaiting}, JobStateInProgress},
+		{[]JobState{JobStateSuccess, JobStateScheduled}, JobStateInProgress},
+		{[]JobState{JobStateFailure, JobStateInProgress}, JobStateInProgress},
+		{[]JobState{JobStateSuccess, JobStateSuccess}, JobStateSuccess},
+		{[]JobState{JobStateFailure, JobStateCancelled}, JobStateFailure},
+		{[]JobState{JobStateSuccess, JobStateCancelled}, JobStateCancelled},
+	}
+	for _, tt := range tests {
+		if got := workflowState(tt.states); got != tt.want {
+			t.Errorf("workflowState(%v) = %s, want %s", tt.states, got, tt.want)
+		}
+	}
+}